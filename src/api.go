@@ -243,6 +243,7 @@ func RegisterPluginAPIs(authRouter *auth.RouterDef) {
 	authRouter.HandleFunc("/api/plugins/enable", pluginManager.HandleEnablePlugin)
 	authRouter.HandleFunc("/api/plugins/disable", pluginManager.HandleDisablePlugin)
 	authRouter.HandleFunc("/api/plugins/icon", pluginManager.HandleLoadPluginIcon)
+	authRouter.HandleFunc("/api/plugins/options", pluginManager.HandlePluginOptions)
 }
 
 // Register the APIs for Auth functions, due to scoping issue some functions are defined here
@@ -351,6 +352,8 @@ func initAPIs(targetMux *http.ServeMux) {
 	RegisterACMEAndAutoRenewerAPIs(authRouter)
 	RegisterStaticWebServerAPIs(authRouter)
 	RegisterPluginAPIs(authRouter)
+	//Plugin event stream, authenticated with the event secret of the plugin instead of the login session
+	targetMux.HandleFunc("/api/plugins/events/stream", pluginManager.HandlePluginEventStream)
 
 	//Account Reset
 	targetMux.HandleFunc("/api/account/reset", HandleAdminAccountResetEmail)
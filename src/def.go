@@ -73,6 +73,7 @@ const (
 /* System Startup Flags */
 var (
 	webUIPort                  = flag.String("port", ":8000", "Management web interface listening port")
+	webUIPublicURL             = flag.String("public_url", "", "Externally visible URL of the management web interface (e.g. https://zoraxy.example.com:8000), used by plugins to build absolute links")
 	databaseBackend            = flag.String("db", "auto", "Database backend to use (leveldb, boltdb, auto) Note that fsdb will be used on unsupported platforms like RISCV")
 	noauth                     = flag.Bool("noauth", false, "Disable authentication for management interface")
 	showver                    = flag.Bool("version", false, "Show version of this server")
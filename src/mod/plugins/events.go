@@ -0,0 +1,215 @@
+package plugins
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
+
+/*
+	Events.go
+
+	This file implements the Zoraxy side of the plugin event stream.
+	Events emitted with EmitEvent are kept in a short history and pushed
+	to the plugins connected to SubscriptionStreamPath below the plugin
+	API URL announced in ConfigureSpec.ZoraxyAPIURL.
+
+	The plugins authenticate with their EventSecret as bearer token and
+	can only receive the events listed in their IntroSpect. A plugin that
+	reconnects with Last-Event-ID gets the missed events from the history
+	replayed before the live ones
+*/
+
+const (
+	eventHistorySize      = 256              //Number of past events kept for Last-Event-ID replay
+	eventListenerBuffer   = 64               //Events buffered per stream before a slow plugin is disconnected
+	eventStreamKeepAlive  = 30 * time.Second //Interval of the keep alive comments sent on idle streams
+	eventStreamRetryDelay = 3000             //Reconnect delay in ms suggested to the plugins
+)
+
+type streamEvent struct {
+	id    uint64
+	event zoraxyPlugin.SubscriptionEvent
+}
+
+type eventBus struct {
+	mu        sync.Mutex
+	lastID    uint64
+	history   []streamEvent
+	listeners map[chan streamEvent]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		listeners: map[chan streamEvent]struct{}{},
+	}
+}
+
+// publish adds the event to the history and pushes it to the listeners
+// Listeners that cannot keep up are closed, the plugin resumes from the history after reconnecting
+func (b *eventBus) publish(event zoraxyPlugin.SubscriptionEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	thisEvent := streamEvent{id: b.lastID, event: event}
+	b.history = append(b.history, thisEvent)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+	for listener := range b.listeners {
+		select {
+		case listener <- thisEvent:
+		default:
+			delete(b.listeners, listener)
+			close(listener)
+		}
+	}
+}
+
+// subscribe registers a new listener and returns the events after lastID kept in the history
+func (b *eventBus) subscribe(lastID uint64) (chan streamEvent, []streamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	listener := make(chan streamEvent, eventListenerBuffer)
+	b.listeners[listener] = struct{}{}
+	missed := []streamEvent{}
+	for _, thisEvent := range b.history {
+		if thisEvent.id > lastID {
+			missed = append(missed, thisEvent)
+		}
+	}
+	return listener, missed
+}
+
+func (b *eventBus) unsubscribe(listener chan streamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.listeners[listener]; ok {
+		delete(b.listeners, listener)
+		close(listener)
+	}
+}
+
+// EmitEvent sends an event to the plugins subscribed to it over the event stream
+func (m *Manager) EmitEvent(eventName string, eventSource string, payload string) {
+	m.eventBus.publish(zoraxyPlugin.SubscriptionEvent{
+		EventName:   eventName,
+		EventSource: eventSource,
+		Payload:     payload,
+	})
+}
+
+// getPluginByEventSecret returns the running plugin started with the given event secret
+func (m *Manager) getPluginByEventSecret(secret string) *Plugin {
+	var matchingPlugin *Plugin
+	if secret == "" {
+		return nil
+	}
+	m.LoadedPlugins.Range(func(key, value interface{}) bool {
+		thisPlugin := value.(*Plugin)
+		if thisPlugin.Enabled && subtle.ConstantTimeCompare([]byte(thisPlugin.eventSecret), []byte(secret)) == 1 {
+			matchingPlugin = thisPlugin
+			return false
+		}
+		return true
+	})
+	return matchingPlugin
+}
+
+// HandlePluginEventStream streams the subscribed events to a plugin over SSE
+// The plugin must authenticate with its event secret as bearer token
+func (m *Manager) HandlePluginEventStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	thisPlugin := m.getPluginByEventSecret(secret)
+	if !ok || thisPlugin == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	//Only the events declared in the plugin IntroSpect can be subscribed
+	subscribedEvents := []string{}
+	for _, eventName := range strings.Split(r.URL.Query().Get("events"), ",") {
+		if eventName == "" {
+			continue
+		}
+		if _, ok := thisPlugin.Spec.SubscriptionsEvents[eventName]; !ok {
+			http.Error(w, "event not subscribed by the plugin: "+eventName, http.StatusForbidden)
+			return
+		}
+		subscribedEvents = append(subscribedEvents, eventName)
+	}
+	if len(subscribedEvents) == 0 {
+		http.Error(w, "no events given", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var lastID uint64
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		lastID, _ = strconv.ParseUint(lastEventID, 10, 64)
+	}
+	listener, missedEvents := m.eventBus.subscribe(lastID)
+	defer m.eventBus.unsubscribe(listener)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("retry: " + strconv.Itoa(eventStreamRetryDelay) + "\n\n"))
+
+	writeEvent := func(thisEvent streamEvent) error {
+		if !slices.Contains(subscribedEvents, thisEvent.event.EventName) {
+			return nil
+		}
+		js, err := json.Marshal(thisEvent.event)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte("id: " + strconv.FormatUint(thisEvent.id, 10) + "\ndata: " + string(js) + "\n\n"))
+		return err
+	}
+	for _, thisEvent := range missedEvents {
+		if err := writeEvent(thisEvent); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case thisEvent, ok := <-listener:
+			if !ok {
+				//Disconnected for being too slow, the plugin will resume from the history
+				return
+			}
+			if err := writeEvent(thisEvent); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keep alive\n\n")); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
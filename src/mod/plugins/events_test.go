@@ -0,0 +1,136 @@
+package plugins
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
+
+func newTestEventManager() *Manager {
+	m := &Manager{eventBus: newEventBus()}
+	m.LoadedPlugins.Store("org.example.plugin", &Plugin{
+		Spec: &zoraxyPlugin.IntroSpect{
+			ID: "org.example.plugin",
+			SubscriptionsEvents: map[string]string{
+				zoraxyPlugin.EventName_ProxyRuleAdded:   "Sync the rules",
+				zoraxyPlugin.EventName_ProxyRuleRemoved: "Sync the rules",
+			},
+		},
+		Enabled:     true,
+		eventSecret: "secret",
+	})
+	return m
+}
+
+func TestHandlePluginEventStream(t *testing.T) {
+	m := newTestEventManager()
+	server := httptest.NewServer(http.HandlerFunc(m.HandlePluginEventStream))
+	defer server.Close()
+	streamURL := server.URL + "?events=" + zoraxyPlugin.EventName_ProxyRuleAdded
+
+	tests := []struct {
+		name   string
+		url    string
+		auth   string
+		status int
+	}{
+		{"no secret", streamURL, "", http.StatusUnauthorized},
+		{"wrong secret", streamURL, "Bearer wrong", http.StatusUnauthorized},
+		{"not subscribed", server.URL + "?events=" + zoraxyPlugin.EventName_CertRenewed, "Bearer secret", http.StatusForbidden},
+		{"no events", server.URL, "Bearer secret", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: expected %d, got %d", test.name, test.status, resp.StatusCode)
+		}
+	}
+
+	//Events emitted before connecting are replayed after the Last-Event-ID
+	m.EmitEvent(zoraxyPlugin.EventName_ProxyRuleAdded, "proxy", "a.example.com")
+	m.EmitEvent(zoraxyPlugin.EventName_ProxyRuleAdded, "proxy", "b.example.com")
+	m.EmitEvent(zoraxyPlugin.EventName_ProxyRuleUpdated, "proxy", "b.example.com")
+	req, _ := http.NewRequest(http.MethodGet, streamURL, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Last-Event-ID", "1")
+	ctx, cancel := context.WithCancel(context.Background())
+	req = req.WithContext(ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "retry: 3000\n\nid: 2\ndata: {\"event_name\":\"proxy_rule_added\",\"event_source\":\"proxy\",\"payload\":\"b.example.com\"}\n\n"
+	buf := make([]byte, len(expected))
+	_, err = io.ReadFull(resp.Body, buf)
+	cancel()
+	resp.Body.Close()
+	if err != nil || string(buf) != expected {
+		t.Errorf("Unexpected replay %q: %v", buf, err)
+	}
+}
+
+func TestPluginEventStreamClient(t *testing.T) {
+	m := newTestEventManager()
+	server := httptest.NewServer(http.HandlerFunc(m.HandlePluginEventStream))
+	defer server.Close()
+
+	client, err := zoraxyPlugin.NewZoraxyClient(&zoraxyPlugin.ConfigureSpec{ZoraxyAPIURL: server.URL, EventSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan zoraxyPlugin.SubscriptionEvent, 4)
+	go client.SubscribeStream(ctx, []string{zoraxyPlugin.EventName_ProxyRuleRemoved}, func(event zoraxyPlugin.SubscriptionEvent) {
+		received <- event
+	})
+
+	//Events emitted before the stream is connected are replayed from the history
+	m.EmitEvent(zoraxyPlugin.EventName_ProxyRuleAdded, "proxy", "a.example.com")
+	m.EmitEvent(zoraxyPlugin.EventName_ProxyRuleRemoved, "proxy", "a.example.com")
+	select {
+	case event := <-received:
+		if event.EventName != zoraxyPlugin.EventName_ProxyRuleRemoved || event.Payload != "a.example.com" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timeout waiting for the event")
+	}
+}
+
+func TestEventBusSlowListener(t *testing.T) {
+	bus := newEventBus()
+	listener, _ := bus.subscribe(0)
+	for i := 0; i < eventListenerBuffer+1; i++ {
+		bus.publish(zoraxyPlugin.SubscriptionEvent{EventName: zoraxyPlugin.EventName_CertRenewed})
+	}
+	count := 0
+	for range listener {
+		count++
+	}
+	if count != eventListenerBuffer {
+		t.Errorf("Expected the slow listener to be closed after %d events, got %d", eventListenerBuffer, count)
+	}
+	bus.unsubscribe(listener)
+
+	for i := 0; i < eventHistorySize; i++ {
+		bus.publish(zoraxyPlugin.SubscriptionEvent{EventName: zoraxyPlugin.EventName_CertRenewed})
+	}
+	_, missed := bus.subscribe(0)
+	if len(missed) != eventHistorySize || missed[0].id != eventListenerBuffer+2 {
+		t.Errorf("Expected the history to keep the last %d events, got %d starting at %d", eventHistorySize, len(missed), missed[0].id)
+	}
+}
@@ -1,15 +1,60 @@
 package plugins
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+
+	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
 
 /*
 	Forwarder.go
 
 	This file handles the dynamic proxy routing forwarding
 	request to plugin capture path that handles the matching
-	request path registered when the plugin started
+	request path registered when the plugin started.
+
+	When several plugins capture the same request, they are
+	returned in the order their capture handlers are invoked,
+	see zoraxy_plugin.CompareCapturePriority
 */
 
+// GetGlobalCapturePlugins returns the enabled plugins with a global capture path matching the request,
+// in the order their capture handlers are invoked, see ListEnabledPluginsByPriority
+func (m *Manager) GetGlobalCapturePlugins(r *http.Request) []*Plugin {
+	return filterCapturePlugins(m.ListEnabledPluginsByPriority(), r, func(spec *zoraxyPlugin.IntroSpect) []zoraxyPlugin.CaptureRule {
+		return spec.GlobalCapturePaths
+	})
+}
+
+// GetAlwaysCapturePlugins returns the given enabled plugins (e.g. the plugins enabled on a HTTP proxy rule)
+// with an always capture path matching the request, in the order their capture handlers are invoked
+func (m *Manager) GetAlwaysCapturePlugins(pluginIDs []string, r *http.Request) []*Plugin {
+	plugins := []*Plugin{}
+	for _, plugin := range m.ListEnabledPluginsByPriority() {
+		if slices.Contains(pluginIDs, plugin.Spec.ID) {
+			plugins = append(plugins, plugin)
+		}
+	}
+	return filterCapturePlugins(plugins, r, func(spec *zoraxyPlugin.IntroSpect) []zoraxyPlugin.CaptureRule {
+		return spec.AlwaysCapturePaths
+	})
+}
+
+// filterCapturePlugins keeps the plugins with a capture rule matching the request, preserving their order
+func filterCapturePlugins(plugins []*Plugin, r *http.Request, captureRules func(spec *zoraxyPlugin.IntroSpect) []zoraxyPlugin.CaptureRule) []*Plugin {
+	matched := []*Plugin{}
+	for _, plugin := range plugins {
+		for _, rule := range captureRules(plugin.Spec) {
+			if rule.MatchesRequest(r) {
+				matched = append(matched, plugin)
+				break
+			}
+		}
+	}
+	return matched
+}
+
 func (m *Manager) GetHandlerPlugins(w http.ResponseWriter, r *http.Request) {
 
 }
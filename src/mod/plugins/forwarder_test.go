@@ -0,0 +1,76 @@
+package plugins
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
+
+func newTestCaptureManager() *Manager {
+	m := &Manager{}
+	plugins := []*Plugin{
+		{Enabled: true, Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.logger", CapturePriority: zoraxyPlugin.CapturePriority_Low,
+			GlobalCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/", IncludeSubPaths: true}}}},
+		{Enabled: true, Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.b",
+			GlobalCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/api", IncludeSubPaths: true}},
+			AlwaysCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/app"}}}},
+		{Enabled: true, Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.a",
+			GlobalCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/api", IncludeSubPaths: true}},
+			AlwaysCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/app"}}}},
+		{Enabled: true, Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.waf", CapturePriority: zoraxyPlugin.CapturePriority_High,
+			GlobalCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/api", IncludeSubPaths: true}},
+			AlwaysCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/app"}}}},
+		{Enabled: false, Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.disabled", CapturePriority: zoraxyPlugin.CapturePriority_High,
+			GlobalCapturePaths: []zoraxyPlugin.CaptureRule{{CapturePath: "/api", IncludeSubPaths: true}}}},
+	}
+	for _, plugin := range plugins {
+		m.LoadedPlugins.Store(plugin.Spec.ID, plugin)
+	}
+	return m
+}
+
+func capturePluginIDs(plugins []*Plugin) string {
+	ids := []string{}
+	for _, plugin := range plugins {
+		ids = append(ids, plugin.Spec.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestListEnabledPluginsByPriority(t *testing.T) {
+	m := newTestCaptureManager()
+	//The order does not depend on the sync.Map iteration order
+	for i := 0; i < 10; i++ {
+		if got := capturePluginIDs(m.ListEnabledPluginsByPriority()); got != "org.example.waf,org.example.a,org.example.b,org.example.logger" {
+			t.Fatalf("Unexpected plugin order %s", got)
+		}
+	}
+}
+
+func TestGetCapturePlugins(t *testing.T) {
+	m := newTestCaptureManager()
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/v1", "org.example.waf,org.example.a,org.example.b,org.example.logger"},
+		{"/index.html", "org.example.logger"},
+	}
+	for _, test := range tests {
+		if got := capturePluginIDs(m.GetGlobalCapturePlugins(httptest.NewRequest(http.MethodGet, test.path, nil))); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.path, test.expected, got)
+		}
+	}
+
+	//Only the plugins enabled on the rule are considered for the always capture paths
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	if got := capturePluginIDs(m.GetAlwaysCapturePlugins([]string{"org.example.b", "org.example.waf", "org.example.disabled"}, req)); got != "org.example.waf,org.example.b" {
+		t.Errorf("Unexpected always capture plugins %s", got)
+	}
+	if got := m.GetAlwaysCapturePlugins([]string{"org.example.a"}, httptest.NewRequest(http.MethodGet, "/other", nil)); len(got) != 0 {
+		t.Errorf("Expected no plugin for an unmatched path, got %s", capturePluginIDs(got))
+	}
+}
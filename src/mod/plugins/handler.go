@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"imuslab.com/zoraxy/mod/utils"
@@ -46,6 +47,20 @@ func (m *Manager) HandleLoadPluginIcon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	//Use the icon declared in the plugin introspect if it is a data URI
+	if strings.HasPrefix(plugin.Spec.Icon, "data:") {
+		mimeType, iconContent, err := plugin.Spec.DecodeIcon()
+		if err == nil {
+			//SVG icons can carry scripts, never let them run in the Zoraxy origin
+			w.Header().Set("Content-Type", mimeType)
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(iconContent))
+			return
+		}
+		m.Log("Failed to decode icon of plugin "+plugin.Spec.ID, err)
+	}
+
 	//Check if the icon.png exists under plugin root directory
 	expectedIconPath := filepath.Join(plugin.RootDir, "icon.png")
 	if !utils.FileExists(expectedIconPath) {
@@ -87,3 +102,63 @@ func (m *Manager) HandleDisablePlugin(w http.ResponseWriter, r *http.Request) {
 
 	utils.SendOK(w)
 }
+
+// HandlePluginOptions handles the request to get (GET) or set (POST) the user defined options of a plugin
+func (m *Manager) HandlePluginOptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		pluginID, err := utils.GetPara(r, "plugin_id")
+		if err != nil {
+			utils.SendErrorResponse(w, "plugin_id not found")
+			return
+		}
+		options := m.GetPluginOptions(pluginID)
+		if thisPlugin, err := m.GetPluginByID(pluginID); err == nil {
+			//Never send the secret values back to the browser
+			options = maskSecretOptions(thisPlugin.Spec, options)
+		}
+		js, _ := json.Marshal(options)
+		utils.SendJSONResponse(w, string(js))
+		return
+	}
+
+	pluginID, err := utils.PostPara(r, "plugin_id")
+	if err != nil {
+		utils.SendErrorResponse(w, "plugin_id not found")
+		return
+	}
+	optionsJSON, err := utils.PostPara(r, "options")
+	if err != nil {
+		utils.SendErrorResponse(w, "options not found")
+		return
+	}
+	options := map[string]string{}
+	err = json.Unmarshal([]byte(optionsJSON), &options)
+	if err != nil {
+		utils.SendErrorResponse(w, "invalid options format")
+		return
+	}
+
+	if thisPlugin, err := m.GetPluginByID(pluginID); err == nil {
+		//Secret fields posted back unchanged keep their stored value
+		options = restoreSecretOptions(thisPlugin.Spec, options, m.GetPluginOptions(pluginID))
+		if err := thisPlugin.Spec.ValidateSettings(options); err != nil {
+			utils.SendErrorResponse(w, err.Error())
+			return
+		}
+	}
+
+	err = m.SetPluginOptions(pluginID, options)
+	if err != nil {
+		utils.SendErrorResponse(w, err.Error())
+		return
+	}
+
+	//Push the new options to the plugin if it is running
+	if thisPlugin, err := m.GetPluginByID(pluginID); err == nil && thisPlugin.Enabled {
+		if err := m.ReloadPluginConfig(pluginID); err != nil {
+			m.Log("Failed to reload plugin config, new options apply on next start", err)
+		}
+	}
+
+	utils.SendOK(w)
+}
@@ -0,0 +1,135 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"imuslab.com/zoraxy/mod/database"
+	"imuslab.com/zoraxy/mod/database/dbinc"
+	"imuslab.com/zoraxy/mod/info/logger"
+	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
+
+func newTestOptionsManager(t *testing.T) *Manager {
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db"), dbinc.BackendBoltDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	db.NewTable("plugin_options")
+
+	m := &Manager{Options: &ManagerOptions{Database: db}, eventBus: newEventBus()}
+	m.LoadedPlugins.Store("org.example.plugin", &Plugin{
+		Spec: &zoraxyPlugin.IntroSpect{
+			ID: "org.example.plugin",
+			SettingsSchema: []zoraxyPlugin.SettingField{
+				{Key: "endpoint", Label: "Endpoint", Type: zoraxyPlugin.SettingFieldType_String},
+				{Key: "api_key", Label: "API Key", Type: zoraxyPlugin.SettingFieldType_String, Secret: true},
+			},
+		},
+	})
+	return m
+}
+
+func getTestPluginOptions(t *testing.T, m *Manager) map[string]string {
+	rec := httptest.NewRecorder()
+	m.HandlePluginOptions(rec, httptest.NewRequest(http.MethodGet, "/api/plugins/options?plugin_id=org.example.plugin", nil))
+	options := map[string]string{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &options); err != nil {
+		t.Fatalf("Invalid options response %q: %v", rec.Body.String(), err)
+	}
+	return options
+}
+
+func postTestPluginOptions(t *testing.T, m *Manager, options map[string]string) {
+	js, _ := json.Marshal(options)
+	form := url.Values{"plugin_id": {"org.example.plugin"}, "options": {string(js)}}
+	req := httptest.NewRequest(http.MethodPost, "/api/plugins/options", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	m.HandlePluginOptions(rec, req)
+	if strings.Contains(rec.Body.String(), "error") {
+		t.Fatalf("Failed to save the options: %s", rec.Body.String())
+	}
+}
+
+func TestHandlePluginOptionsSecrets(t *testing.T) {
+	m := newTestOptionsManager(t)
+	postTestPluginOptions(t, m, map[string]string{"endpoint": "https://example.com", "api_key": "s3cr3t"})
+
+	//The secret values are masked in the response
+	options := getTestPluginOptions(t, m)
+	if options["endpoint"] != "https://example.com" || options["api_key"] != zoraxyPlugin.RedactedPlaceholder {
+		t.Errorf("Expected the secret to be masked, got %v", options)
+	}
+
+	//Posting the masked form back keeps the stored secret
+	options["endpoint"] = "https://example.org"
+	postTestPluginOptions(t, m, options)
+	stored := m.GetPluginOptions("org.example.plugin")
+	if stored["endpoint"] != "https://example.org" || stored["api_key"] != "s3cr3t" {
+		t.Errorf("Expected the stored secret to be kept, got %v", stored)
+	}
+
+	//A new secret value replaces the stored one, an empty one clears it
+	postTestPluginOptions(t, m, map[string]string{"endpoint": "https://example.org", "api_key": "n3w"})
+	if stored := m.GetPluginOptions("org.example.plugin"); stored["api_key"] != "n3w" {
+		t.Errorf("Expected the secret to be updated, got %v", stored)
+	}
+	postTestPluginOptions(t, m, map[string]string{"endpoint": "https://example.org", "api_key": ""})
+	if stored := m.GetPluginOptions("org.example.plugin"); stored["api_key"] != "" {
+		t.Errorf("Expected the secret to be cleared, got %v", stored)
+	}
+	if options := getTestPluginOptions(t, m); options["api_key"] != "" {
+		t.Errorf("Expected an empty secret not to be masked, got %v", options)
+	}
+}
+
+func TestHandleLoadPluginIcon(t *testing.T) {
+	fmtLogger, _ := logger.NewFmtLogger()
+	m := &Manager{Options: &ManagerOptions{Logger: fmtLogger}}
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+	rootDir := t.TempDir()
+	os.WriteFile(filepath.Join(rootDir, "icon.png"), []byte("icon.png on disk"), 0644)
+	plugins := map[string]*Plugin{
+		"org.example.svg":     {Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.svg", Icon: "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(svg)}},
+		"org.example.invalid": {RootDir: rootDir, Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.invalid", Icon: "data:text/html;base64," + base64.StdEncoding.EncodeToString(svg)}},
+		"org.example.none":    {RootDir: t.TempDir(), Spec: &zoraxyPlugin.IntroSpect{ID: "org.example.none"}},
+	}
+	for id, plugin := range plugins {
+		m.LoadedPlugins.Store(id, plugin)
+	}
+	loadIcon := func(pluginID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		m.HandleLoadPluginIcon(rec, httptest.NewRequest(http.MethodGet, "/api/plugins/icon?plugin_id="+pluginID, nil))
+		return rec
+	}
+
+	//Data URI icons are decoded and cannot run scripts in the Zoraxy origin
+	rec := loadIcon("org.example.svg")
+	if rec.Header().Get("Content-Type") != "image/svg+xml" || !bytes.Equal(rec.Body.Bytes(), svg) {
+		t.Errorf("Expected the decoded SVG icon, got %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if !strings.Contains(rec.Header().Get("Content-Security-Policy"), "sandbox") || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected the SVG icon to be sandboxed, got %v", rec.Header())
+	}
+
+	//Invalid data URIs fall back to the icon.png of the plugin folder
+	if rec := loadIcon("org.example.invalid"); rec.Body.String() != "icon.png on disk" {
+		t.Errorf("Expected the icon.png fallback, got %q", rec.Body.String())
+	}
+	if rec := loadIcon("org.example.none"); !bytes.Equal(rec.Body.Bytes(), noImg) {
+		t.Error("Expected the placeholder icon without any icon")
+	}
+	if rec := loadIcon("org.example.missing"); !strings.Contains(rec.Body.String(), "error") {
+		t.Errorf("Expected an error for an unknown plugin, got %q", rec.Body.String())
+	}
+}
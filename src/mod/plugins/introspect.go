@@ -37,9 +37,16 @@ func (m *Manager) LoadPluginSpec(pluginPath string) (*Plugin, error) {
 		return nil, err
 	}
 
+	//Reject plugins built against an incompatible wire protocol
+	err = zoraxyPlugin.CheckProtocolVersion(pluginSpec.ProtocolVersion)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", pluginSpec.ID, err)
+	}
+
 	return &Plugin{
-		Spec:    pluginSpec,
-		Enabled: false,
+		Spec:     pluginSpec,
+		Enabled:  false,
+		Warnings: pluginSpec.UIWarnings(),
 	}, nil
 }
 
@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
@@ -26,6 +27,11 @@ func (m *Manager) StartPlugin(pluginID string) error {
 
 	thisPlugin := plugin.(*Plugin)
 
+	//Warn about incompatible plugins running at the same time
+	for _, reason := range m.GetPluginConflicts(thisPlugin) {
+		m.Log("Plugin conflict: "+reason, nil)
+	}
+
 	//Get the plugin Entry point
 	pluginEntryPoint, err := m.GetPluginEntryPoint(thisPlugin.RootDir)
 	if err != nil {
@@ -39,15 +45,30 @@ func (m *Manager) StartPlugin(pluginID string) error {
 		return err
 	}
 
+	//Generate a new event secret for every plugin start
+	thisPlugin.eventSecret, err = generateEventSecret()
+	if err != nil {
+		return err
+	}
+
 	//Prepare plugin start configuration
-	pluginConfiguration := zoraxyPlugin.ConfigureSpec{
-		Port:         getRandomPortNumber(),
-		RuntimeConst: *m.Options.SystemConst,
+	pluginPort, portGranted := getPluginPortNumber(thisPlugin.Spec)
+	thisPlugin.portGranted = portGranted
+	pluginConfiguration, err := m.buildConfigureSpec(thisPlugin, pluginPort)
+	if err != nil {
+		return err
 	}
 	js, _ := json.Marshal(pluginConfiguration)
 
 	m.Log("Starting plugin "+thisPlugin.Spec.Name+" at :"+strconv.Itoa(pluginConfiguration.Port), nil)
-	cmd := exec.Command(absolutePath, "-configure="+string(js))
+	var cmd *exec.Cmd
+	if len(js) > zoraxyPlugin.ConfigureArgvMaxSize {
+		//Large payloads are piped to the plugin STDIN to avoid argv length limits
+		cmd = exec.Command(absolutePath, "-configure="+zoraxyPlugin.ConfigureStdinArg)
+		cmd.Stdin = bytes.NewReader(js)
+	} else {
+		cmd = exec.Command(absolutePath, "-configure="+string(js))
+	}
 	cmd.Dir = filepath.Dir(absolutePath)
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -138,9 +159,27 @@ func (m *Manager) handlePluginSTDOUT(pluginID string, line string) {
 		m.Log("[unknown:"+strconv.Itoa(processID)+"] "+line, err)
 		return
 	}
+	if heartbeat, ok := zoraxyPlugin.ParseHeartbeat(line); ok {
+		//Keep the heartbeat out of the log
+		thisPlugin.lastHeartbeat.Store(heartbeat.Timestamp)
+		return
+	}
+	if logSecrets := thisPlugin.logSecrets.Load(); logSecrets != nil {
+		line = zoraxyPlugin.RedactSecrets(line, *logSecrets)
+	}
 	m.Log("["+thisPlugin.Spec.Name+":"+strconv.Itoa(processID)+"] "+line, nil)
 }
 
+// LastHeartbeat returns the time of the last watchdog heartbeat of the plugin
+// The zero time is returned if the plugin does not run a watchdog
+func (p *Plugin) LastHeartbeat() time.Time {
+	timestamp := p.lastHeartbeat.Load()
+	if timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(timestamp, 0)
+}
+
 func (m *Manager) StopPlugin(pluginID string) error {
 	plugin, ok := m.LoadedPlugins.Load(pluginID)
 	if !ok {
@@ -153,7 +192,7 @@ func (m *Manager) StopPlugin(pluginID string) error {
 	//Make a GET request to plugin ui path /term to gracefully stop the plugin
 	if thisPlugin.uiProxy != nil {
 		requestURI := "http://127.0.0.1:" + strconv.Itoa(thisPlugin.AssignedPort) + "/" + thisPlugin.Spec.UIPath + "/term"
-		resp, err := http.Get(requestURI)
+		resp, err := sendSignedRequest(http.DefaultClient, http.MethodGet, requestURI, nil, thisPlugin.eventSecret)
 		if err != nil {
 			//Plugin do not support termination request, do it the hard way
 			m.Log("Plugin "+thisPlugin.Spec.ID+" termination request failed. Force shutting down", nil)
@@ -201,6 +240,40 @@ func (m *Manager) StopPlugin(pluginID string) error {
 	return nil
 }
 
+// ReloadPluginConfig pushes an updated configure spec to a running plugin
+// without restarting the plugin process
+func (m *Manager) ReloadPluginConfig(pluginID string) error {
+	thisPlugin, err := m.GetPluginByID(pluginID)
+	if err != nil {
+		return err
+	}
+	if !thisPlugin.Enabled || thisPlugin.uiProxy == nil {
+		return errors.New("plugin is not running")
+	}
+	pluginConfiguration, err := m.buildConfigureSpec(thisPlugin, thisPlugin.AssignedPort)
+	if err != nil {
+		return err
+	}
+	js, _ := json.Marshal(pluginConfiguration)
+
+	pluginUIRelPath := strings.TrimSuffix("/"+strings.TrimPrefix(thisPlugin.Spec.UIPath, "/"), "/")
+	requestURI := "http://127.0.0.1:" + strconv.Itoa(thisPlugin.AssignedPort) + pluginUIRelPath + "/reload"
+	resp, err := sendSignedRequest(http.DefaultClient, http.MethodPost, requestURI, js, thisPlugin.eventSecret)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New("plugin does not support config reload")
+	} else if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return errors.New("plugin rejected config reload: " + strings.TrimSpace(string(respBody)))
+	}
+
+	m.Log("Plugin "+thisPlugin.Spec.Name+" configuration reloaded", nil)
+	return nil
+}
+
 // Check if the plugin is still running
 func (m *Manager) PluginStillRunning(pluginID string) bool {
 	plugin, ok := m.LoadedPlugins.Load(pluginID)
@@ -224,3 +297,86 @@ func (m *Manager) BlockUntilAllProcessExited() {
 		return true
 	})
 }
+
+// CallPluginControl calls a JSON-RPC control method of a running plugin and returns its result
+func (m *Manager) CallPluginControl(pluginID string, method string, params interface{}) (json.RawMessage, error) {
+	thisPlugin, err := m.GetPluginByID(pluginID)
+	if err != nil {
+		return nil, err
+	}
+	if !thisPlugin.Enabled {
+		return nil, errors.New("plugin is not running")
+	}
+
+	js, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	requestURI := "http://127.0.0.1:" + strconv.Itoa(thisPlugin.AssignedPort) + zoraxyPlugin.ControlPath
+	resp, err := sendSignedRequest(&client, http.MethodPost, requestURI, js, thisPlugin.eventSecret)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.New("plugin does not support control methods")
+	}
+
+	rpcResp := struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, err
+	}
+	if rpcResp.Error != nil {
+		return nil, errors.New("plugin control method " + method + " failed: " + rpcResp.Error.Message)
+	}
+	return rpcResp.Result, nil
+}
+
+// buildConfigureSpec builds the configure spec sent to the plugin on start and on config reload,
+// and updates the secrets redacted from the plugin log with the current options
+func (m *Manager) buildConfigureSpec(thisPlugin *Plugin, port int) (*zoraxyPlugin.ConfigureSpec, error) {
+	dataDir, err := getPluginDataDir(thisPlugin)
+	if err != nil {
+		return nil, err
+	}
+	pluginConfiguration := &zoraxyPlugin.ConfigureSpec{
+		Port:            port,
+		PortGranted:     thisPlugin.portGranted,
+		RuntimeConst:    m.getPluginRuntimeConst(thisPlugin),
+		Options:         thisPlugin.Spec.SettingsWithDefaults(m.GetPluginOptions(thisPlugin.Spec.ID)),
+		EventSecret:     thisPlugin.eventSecret,
+		ProtocolVersion: zoraxyPlugin.ProtocolVersion,
+		DataDir:         dataDir,
+		ZoraxyAPIURL:    m.Options.ZoraxyAPIURL,
+	}
+	logSecrets := thisPlugin.Spec.SecretSettings(pluginConfiguration.Options)
+	thisPlugin.logSecrets.Store(&logSecrets)
+	return pluginConfiguration, nil
+}
+
+// sendSignedRequest sends a request to the plugin signed with its event secret,
+// so the plugin can tell the calls made by Zoraxy apart from the ones proxied from browsers
+func sendSignedRequest(client *http.Client, method string, requestURI string, body []byte, secret string) (*http.Response, error) {
+	req, err := http.NewRequest(method, requestURI, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	zoraxyPlugin.SignRequest(req, body, secret)
+	return client.Do(req)
+}
@@ -0,0 +1,64 @@
+package plugins
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
+
+func TestBuildConfigureSpec(t *testing.T) {
+	m := newTestOptionsManager(t)
+	m.Options.SystemConst = &zoraxyPlugin.RuntimeConstantValue{ZoraxyVersion: "3.2.0", ExternalBaseURL: "https://zoraxy.example.com/"}
+	m.Options.ZoraxyAPIURL = "http://127.0.0.1:8000/api/plugins"
+	postTestPluginOptions(t, m, map[string]string{"endpoint": "https://example.com", "api_key": "s3cr3t"})
+
+	thisPlugin, err := m.GetPluginByID("org.example.plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thisPlugin.RootDir = t.TempDir()
+	thisPlugin.eventSecret = "secret"
+	thisPlugin.portGranted = true
+
+	spec, err := m.buildConfigureSpec(thisPlugin, 8123)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if spec.Port != 8123 || !spec.PortGranted || spec.EventSecret != "secret" || spec.ProtocolVersion != zoraxyPlugin.ProtocolVersion || spec.ZoraxyAPIURL != m.Options.ZoraxyAPIURL {
+		t.Errorf("Unexpected configure spec %+v", spec)
+	}
+	if spec.DataDir != filepath.Join(thisPlugin.RootDir, "data") {
+		t.Errorf("Unexpected data dir %q", spec.DataDir)
+	}
+	if spec.RuntimeConst.ExternalBaseURL != "https://zoraxy.example.com/plugin.ui/org.example.plugin" {
+		t.Errorf("Unexpected external base URL %q", spec.RuntimeConst.ExternalBaseURL)
+	}
+	if spec.Options["api_key"] != "s3cr3t" {
+		t.Errorf("Expected the plugin to receive the stored secret, got %v", spec.Options)
+	}
+	if logSecrets := thisPlugin.logSecrets.Load(); logSecrets == nil || len(*logSecrets) != 1 || (*logSecrets)[0] != "s3cr3t" {
+		t.Errorf("Expected the secret option to be redacted from the plugin log, got %v", logSecrets)
+	}
+}
+
+func TestSendSignedRequest(t *testing.T) {
+	verified := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verified = zoraxyPlugin.VerifyRequestSignature(r, body, "secret")
+	}))
+	defer server.Close()
+
+	resp, err := sendSignedRequest(server.Client(), http.MethodPost, server.URL+"/ui/reload?x=1", []byte(`{"port":1}`), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !verified {
+		t.Error("Expected the plugin to verify the signed request")
+	}
+}
@@ -13,8 +13,10 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 
+	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
 	"imuslab.com/zoraxy/mod/utils"
 )
 
@@ -30,10 +32,12 @@ func NewPluginManager(options *ManagerOptions) *Manager {
 
 	//Create database table
 	options.Database.NewTable("plugins")
+	options.Database.NewTable("plugin_options")
 
 	return &Manager{
 		LoadedPlugins: sync.Map{},
 		Options:       options,
+		eventBus:      newEventBus(),
 	}
 }
 
@@ -110,6 +114,25 @@ func (m *Manager) GetPluginPreviousEnableState(pluginID string) bool {
 	return enableState
 }
 
+// GetPluginOptions returns the user defined options of a plugin
+func (m *Manager) GetPluginOptions(pluginID string) map[string]string {
+	options := map[string]string{}
+	err := m.Options.Database.Read("plugin_options", pluginID, &options)
+	if err != nil {
+		return map[string]string{}
+	}
+	return options
+}
+
+// SetPluginOptions sets the user defined options of a plugin
+// The options are sent to the plugin on next start or config reload
+func (m *Manager) SetPluginOptions(pluginID string, options map[string]string) error {
+	if _, err := m.GetPluginByID(pluginID); err != nil {
+		return err
+	}
+	return m.Options.Database.Write("plugin_options", pluginID, options)
+}
+
 // ListLoadedPlugins returns a list of loaded plugins
 func (m *Manager) ListLoadedPlugins() ([]*Plugin, error) {
 	var plugins []*Plugin = []*Plugin{}
@@ -121,6 +144,36 @@ func (m *Manager) ListLoadedPlugins() ([]*Plugin, error) {
 	return plugins, nil
 }
 
+// ListEnabledPluginsByPriority returns the enabled plugins in the order their capture handlers are invoked
+func (m *Manager) ListEnabledPluginsByPriority() []*Plugin {
+	plugins := []*Plugin{}
+	m.LoadedPlugins.Range(func(key, value interface{}) bool {
+		plugin := value.(*Plugin)
+		if plugin.Enabled {
+			plugins = append(plugins, plugin)
+		}
+		return true
+	})
+	sort.SliceStable(plugins, func(i, j int) bool {
+		return zoraxyPlugin.CompareCapturePriority(plugins[i].Spec, plugins[j].Spec) < 0
+	})
+	return plugins
+}
+
+// GetPluginConflicts returns the reasons the plugin conflicts with the other enabled plugins
+func (m *Manager) GetPluginConflicts(plugin *Plugin) []string {
+	conflicts := []string{}
+	for _, otherPlugin := range m.ListEnabledPluginsByPriority() {
+		if otherPlugin == plugin {
+			continue
+		}
+		if conflicted, reason := plugin.Spec.ConflictsWith(otherPlugin.Spec); conflicted {
+			conflicts = append(conflicts, reason)
+		}
+	}
+	return conflicts
+}
+
 // Terminate all plugins and exit
 func (m *Manager) Close() {
 	m.LoadedPlugins.Range(func(key, value interface{}) bool {
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os/exec"
 	"sync"
+	"sync/atomic"
 
 	"imuslab.com/zoraxy/mod/database"
 	"imuslab.com/zoraxy/mod/dynamicproxy/dpcore"
@@ -16,14 +17,21 @@ import (
 var noImg []byte
 
 type Plugin struct {
-	RootDir string                   //The root directory of the plugin
-	Spec    *zoraxyPlugin.IntroSpect //The plugin specification
-	Enabled bool                     //Whether the plugin is enabled
+	RootDir  string                   //The root directory of the plugin
+	Spec     *zoraxyPlugin.IntroSpect //The plugin specification
+	Enabled  bool                     //Whether the plugin is enabled
+	Warnings []zoraxyPlugin.UIWarning //Warnings about the plugin specification shown to the user
 
 	//Runtime
-	AssignedPort int                  //The assigned port for the plugin
-	uiProxy      *dpcore.ReverseProxy //The reverse proxy for the plugin UI
-	process      *exec.Cmd            //The process of the plugin
+	AssignedPort  int                  //The assigned port for the plugin
+	portGranted   bool                 //Whether the assigned port honors the port preference of the plugin
+	uiProxy       *dpcore.ReverseProxy //The reverse proxy for the plugin UI
+	process       *exec.Cmd            //The process of the plugin
+	eventSecret   string               //The secret used to sign the subscription events sent to the plugin
+	lastHeartbeat atomic.Int64         //Unix timestamp of the last watchdog heartbeat, 0 if the plugin does not send any
+
+	//Secret settings values redacted from the plugin log, see IntroSpect.SecretSettings
+	logSecrets atomic.Pointer[[]string]
 }
 
 type ManagerOptions struct {
@@ -32,9 +40,11 @@ type ManagerOptions struct {
 	Database     *database.Database
 	Logger       *logger.Logger
 	CSRFTokenGen func(*http.Request) string //The CSRF token generator function
+	ZoraxyAPIURL string                     //URL of the plugin API announced to the plugins, empty if not served
 }
 
 type Manager struct {
 	LoadedPlugins sync.Map //Storing *Plugin
 	Options       *ManagerOptions
+	eventBus      *eventBus
 }
@@ -1,11 +1,14 @@
 package plugins
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"math/rand"
+	mrand "math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"imuslab.com/zoraxy/mod/netutils"
 	zoraxyPlugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
@@ -54,29 +57,92 @@ func (m *Manager) Log(message string, err error) {
 
 // getRandomPortNumber generates a random port number between 49152 and 65535
 func getRandomPortNumber() int {
-	portNo := rand.Intn(65535-49152) + 49152
+	portNo := mrand.Intn(65535-49152) + 49152
 	//Check if the port is already in use
 	for netutils.CheckIfPortOccupied(portNo) {
-		portNo = rand.Intn(65535-49152) + 49152
+		portNo = mrand.Intn(65535-49152) + 49152
 	}
 	return portNo
 }
 
+// getPluginPortNumber returns the port for the plugin, honoring the port preference of its spec if possible
+// The returned bool is true if the port preference was granted
+func getPluginPortNumber(pluginSpec *zoraxyPlugin.IntroSpect) (int, bool) {
+	if pluginSpec.PreferredPort > 0 && !netutils.CheckIfPortOccupied(pluginSpec.PreferredPort) {
+		return pluginSpec.PreferredPort, true
+	}
+	if pluginSpec.PortRange == nil {
+		return getRandomPortNumber(), false
+	}
+	lo, hi := pluginSpec.PortRange[0], pluginSpec.PortRange[1]
+	if lo > 0 && hi >= lo {
+		//Start from a random offset so plugins sharing a range do not race for the same port
+		offset := mrand.Intn(hi - lo + 1)
+		for i := 0; i <= hi-lo; i++ {
+			portNo := lo + (offset+i)%(hi-lo+1)
+			if !netutils.CheckIfPortOccupied(portNo) {
+				return portNo, true
+			}
+		}
+	}
+	return getRandomPortNumber(), false
+}
+
 func validatePluginSpec(pluginSpec *zoraxyPlugin.IntroSpect) error {
-	if pluginSpec.Name == "" {
-		return errors.New("plugin name is empty")
+	return pluginSpec.Validate()
+}
+
+// maskSecretOptions returns a copy of the options with the values of the secret settings replaced by RedactedPlaceholder
+func maskSecretOptions(pluginSpec *zoraxyPlugin.IntroSpect, options map[string]string) map[string]string {
+	masked := make(map[string]string, len(options))
+	for key, value := range options {
+		masked[key] = value
 	}
-	if pluginSpec.Description == "" {
-		return errors.New("plugin description is empty")
+	for _, field := range pluginSpec.SettingsSchema {
+		if field.Secret && masked[field.Key] != "" {
+			masked[field.Key] = zoraxyPlugin.RedactedPlaceholder
+		}
 	}
-	if pluginSpec.Author == "" {
-		return errors.New("plugin author is empty")
+	return masked
+}
+
+// restoreSecretOptions puts back the stored values of the secret settings posted as RedactedPlaceholder
+func restoreSecretOptions(pluginSpec *zoraxyPlugin.IntroSpect, options map[string]string, storedOptions map[string]string) map[string]string {
+	for _, field := range pluginSpec.SettingsSchema {
+		if field.Secret && options[field.Key] == zoraxyPlugin.RedactedPlaceholder {
+			options[field.Key] = storedOptions[field.Key]
+		}
 	}
-	if pluginSpec.UIPath == "" {
-		return errors.New("plugin UI path is empty")
+	return options
+}
+
+// getPluginRuntimeConst returns the runtime constants sent to the given plugin
+func (m *Manager) getPluginRuntimeConst(plugin *Plugin) zoraxyPlugin.RuntimeConstantValue {
+	runtimeConst := *m.Options.SystemConst
+	if runtimeConst.ExternalBaseURL != "" {
+		//Point the base URL to the mount path of this plugin UI
+		runtimeConst.ExternalBaseURL = strings.TrimSuffix(runtimeConst.ExternalBaseURL, "/") + "/plugin.ui/" + plugin.Spec.ID
 	}
-	if pluginSpec.ID == "" {
-		return errors.New("plugin ID is empty")
+	return runtimeConst
+}
+
+// getPluginDataDir returns the data directory of the plugin, creating it if it does not exist
+func getPluginDataDir(plugin *Plugin) (string, error) {
+	dataDir, err := filepath.Abs(filepath.Join(plugin.RootDir, "data"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dataDir, 0775); err != nil {
+		return "", err
+	}
+	return dataDir, nil
+}
+
+// generateEventSecret generates a random secret for signing the subscription events of a plugin
+func generateEventSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
-	return nil
+	return hex.EncodeToString(buf), nil
 }
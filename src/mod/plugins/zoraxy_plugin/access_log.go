@@ -0,0 +1,152 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+	Access_log.go
+
+	This file provides access logging for the PluginUiRouter
+	in Combined Log Format or JSON (one object per line).
+	The X-Zoraxy-Request-ID of the request is logged when present
+*/
+
+type AccessLogFormat int
+
+const (
+	AccessLogFormat_Combined AccessLogFormat = 0 //Apache / nginx combined log format, with the duration appended in milliseconds
+	AccessLogFormat_JSON     AccessLogFormat = 1 //One JSON object per line
+)
+
+type accessLogEntry struct {
+	Time       string `json:"time"`
+	RemoteAddr string `json:"remote_addr"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Proto      string `json:"proto"`
+	Status     int    `json:"status"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	Referer    string `json:"referer"`
+	UserAgent  string `json:"user_agent"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+// statusResponseWriter wraps a http.ResponseWriter to capture the response status and size
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// accessLogMu serializes writes of log lines shared across routers using the same writer
+var accessLogMu sync.Mutex
+
+// WithAccessLog enables access logging of every request served by this router
+// Call this before Handler(), pass a nil writer to disable access logging
+func (p *PluginUiRouter) WithAccessLog(w io.Writer, format AccessLogFormat) *PluginUiRouter {
+	p.accessLogWriter = w
+	p.accessLogFormat = format
+	return p
+}
+
+func (p *PluginUiRouter) accessLogMiddleware(next http.Handler) http.Handler {
+	if p.accessLogWriter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		p.writeAccessLog(r, sw, startTime)
+	})
+}
+
+func (p *PluginUiRouter) writeAccessLog(r *http.Request, sw *statusResponseWriter, startTime time.Time) {
+	remoteAddr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	entry := accessLogEntry{
+		Time:       startTime.Format(time.RFC3339),
+		RemoteAddr: remoteAddr,
+		Method:     r.Method,
+		Path:       r.RequestURI,
+		Proto:      r.Proto,
+		Status:     sw.Status(),
+		Bytes:      sw.bytes,
+		DurationMs: time.Since(startTime).Milliseconds(),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		RequestID:  RequestID(r),
+	}
+
+	var line string
+	if p.accessLogFormat == AccessLogFormat_JSON {
+		js, _ := json.Marshal(entry)
+		line = string(js) + "\n"
+	} else {
+		line = fmt.Sprintf("%s - - [%s] %q %d %d %q %q %dms",
+			entry.RemoteAddr,
+			startTime.Format("02/Jan/2006:15:04:05 -0700"),
+			entry.Method+" "+entry.Path+" "+entry.Proto,
+			entry.Status,
+			entry.Bytes,
+			entry.Referer,
+			entry.UserAgent,
+			entry.DurationMs,
+		)
+		if entry.RequestID != "" {
+			line += " " + strconv.Quote(entry.RequestID)
+		}
+		line += "\n"
+	}
+
+	accessLogMu.Lock()
+	defer accessLogMu.Unlock()
+	io.WriteString(p.accessLogWriter, line)
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer supports it
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Status returns the response status, 200 if nothing has been written yet
+func (w *statusResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
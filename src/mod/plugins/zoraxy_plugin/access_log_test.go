@@ -0,0 +1,77 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogStatusAndBytes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ui/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("hello"))
+		w.Write([]byte(" world"))
+	})
+
+	var logs strings.Builder
+	router := newTestUiRouter().WithAccessLog(&logs, AccessLogFormat_JSON)
+	logged := router.accessLogMiddleware(handler)
+	req := httptest.NewRequest(http.MethodGet, "/ui/index.html?a=1", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	logged.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry accessLogEntry
+	if err := json.Unmarshal([]byte(logs.String()), &entry); err != nil {
+		t.Fatalf("Expected one JSON object per line, got %q: %v", logs.String(), err)
+	}
+	if entry.Status != http.StatusOK || entry.Bytes != 11 || entry.Method != http.MethodGet || entry.Path != "/ui/index.html?a=1" || entry.UserAgent != "test-agent" || entry.RemoteAddr != "192.0.2.1" {
+		t.Errorf("Unexpected access log entry %+v", entry)
+	}
+
+	logs.Reset()
+	router.WithAccessLog(&logs, AccessLogFormat_Combined)
+	logged = router.accessLogMiddleware(handler)
+	logged.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ui/missing", nil))
+	if !strings.HasPrefix(logs.String(), "192.0.2.1 - - [") || !strings.Contains(logs.String(), `] "GET /ui/missing HTTP/1.1" 404 10 `) || !strings.HasSuffix(logs.String(), "ms\n") {
+		t.Errorf("Unexpected combined log line %q", logs.String())
+	}
+
+	//A nil writer disables access logging
+	logs.Reset()
+	router.WithAccessLog(nil, AccessLogFormat_JSON)
+	router.accessLogMiddleware(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	if logs.Len() != 0 {
+		t.Errorf("Expected nothing to be logged, got %q", logs.String())
+	}
+}
+
+func TestStatusResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := &statusResponseWriter{ResponseWriter: rec}
+	if sw.Status() != http.StatusOK {
+		t.Errorf("Expected 200 before anything is written, got %d", sw.Status())
+	}
+
+	//The first status code is kept
+	sw.WriteHeader(http.StatusAccepted)
+	sw.WriteHeader(http.StatusInternalServerError)
+	var flusher http.Flusher = sw
+	flusher.Flush()
+	if !rec.Flushed {
+		t.Error("Expected Flush to reach the underlying writer")
+	}
+	if sw.Status() != http.StatusAccepted {
+		t.Errorf("Expected 202, got %d", sw.Status())
+	}
+	if http.NewResponseController(sw).Flush() != nil {
+		t.Error("Expected the response controller to flush through Unwrap")
+	}
+
+	//Writers without http.Flusher are left alone
+	(&statusResponseWriter{ResponseWriter: noFlushResponseWriter{httptest.NewRecorder()}}).Flush()
+}
@@ -0,0 +1,89 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+	Backend_proxy.go
+
+	This file provides a reverse proxy for utility plugins that front
+	a locally running backend process (e.g. a web app started by the plugin)
+
+	Example:
+	proxy, err := zoraxy_plugin.NewBackendProxy("http://127.0.0.1:3000", zoraxy_plugin.ProxyOptions{
+		StripPrefix: "/app",
+	})
+	http.Handle("/app/", proxy)
+*/
+
+type ProxyOptions struct {
+	StripPrefix   string        //Path prefix removed before forwarding (e.g. /app), leave empty to forward the path as is
+	PreserveHost  bool          //Forward the original Host header instead of the backend host
+	FlushInterval time.Duration //Flush interval of the response body, negative to flush immediately (e.g. for streaming backends)
+	Transport     http.RoundTripper
+	ErrorHandler  func(w http.ResponseWriter, r *http.Request, err error) //Optional, default logs the error and responds with 502
+}
+
+// NewBackendProxy creates a reverse proxy to the backend at targetURL
+// X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto are set from the incoming request
+func NewBackendProxy(targetURL string, opts ProxyOptions) (http.Handler, error) {
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, errors.New("backend proxy target must be a http:// or https:// URL")
+	}
+	if target.Host == "" {
+		return nil, errors.New("backend proxy target host is empty")
+	}
+	stripPrefix := strings.TrimSuffix(opts.StripPrefix, "/")
+
+	errorHandler := opts.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			fmt.Println("[backend proxy] " + r.Method + " " + r.URL.Path + " to " + target.Host + " failed: " + err.Error())
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		}
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if stripPrefix != "" {
+				pr.Out.URL.Path = stripURLPathPrefix(pr.Out.URL.Path, stripPrefix)
+				pr.Out.URL.RawPath = stripURLPathPrefix(pr.Out.URL.RawPath, stripPrefix)
+			}
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			if opts.PreserveHost {
+				pr.Out.Host = pr.In.Host
+			}
+		},
+		FlushInterval: opts.FlushInterval,
+		Transport:     opts.Transport,
+		ErrorHandler:  errorHandler,
+	}
+	return proxy, nil
+}
+
+// stripURLPathPrefix removes the prefix from the path on a segment boundary
+func stripURLPathPrefix(urlPath string, prefix string) string {
+	if urlPath == "" {
+		return urlPath
+	}
+	rest, ok := stripHandlerPrefix(urlPath, prefix)
+	if !ok {
+		return urlPath
+	}
+	if rest == "" {
+		return "/"
+	}
+	return rest
+}
@@ -0,0 +1,38 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBackendProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI() + " " + r.Header.Get("X-Forwarded-Host")))
+	}))
+	defer backend.Close()
+
+	proxy, err := NewBackendProxy(backend.URL, ProxyOptions{StripPrefix: "/app/"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"/app/page?x=1": "/page?x=1 plugin.example.com",
+		"/app":          "/ plugin.example.com",
+		"/apple":        "/apple plugin.example.com",
+	}
+	for uri, expected := range tests {
+		req := httptest.NewRequest("GET", uri, nil)
+		req.Host = "plugin.example.com"
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if rec.Body.String() != expected {
+			t.Errorf("%s: expected %q, got %q", uri, expected, rec.Body.String())
+		}
+	}
+
+	if _, err := NewBackendProxy("127.0.0.1:3000", ProxyOptions{}); err == nil {
+		t.Error("Expected an error for a target without scheme")
+	}
+}
@@ -0,0 +1,67 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Capture_deadline.go
+
+	This file propagates the upstream timeout of a captured request.
+	Zoraxy sets X-Zoraxy-Deadline to the time (in Unix milliseconds)
+	after which the client no longer waits for the response, and the
+	middleware turns it into the deadline of the request context so
+	slow downstream work can be cancelled
+
+	Example:
+	http.Handle("/capture", CaptureDeadlineMiddleware(handler))
+
+	func handler(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", backendURL, nil)
+		...
+	}
+*/
+
+const DeadlineHeader = "X-Zoraxy-Deadline"
+
+// CaptureDeadlineMiddleware sets the deadline of the request context from the X-Zoraxy-Deadline header
+// Requests whose deadline has already passed are answered with 504 without calling next
+// Requests without a valid header are passed through unchanged
+func CaptureDeadlineMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := ParseDeadlineHeader(r.Header)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !time.Now().Before(deadline) {
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			return
+		}
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ParseDeadlineHeader returns the deadline set in the X-Zoraxy-Deadline header
+func ParseDeadlineHeader(header http.Header) (time.Time, bool) {
+	value := strings.TrimSpace(header.Get(DeadlineHeader))
+	if value == "" {
+		return time.Time{}, false
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || millis <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(millis), true
+}
+
+// SetDeadlineHeader sets the X-Zoraxy-Deadline header to the given deadline
+func SetDeadlineHeader(header http.Header, deadline time.Time) {
+	header.Set(DeadlineHeader, strconv.FormatInt(deadline.UnixMilli(), 10))
+}
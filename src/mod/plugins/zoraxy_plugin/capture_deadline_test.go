@@ -0,0 +1,48 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCaptureDeadlineMiddleware(t *testing.T) {
+	var gotDeadline time.Time
+	var hasDeadline bool
+	handler := CaptureDeadlineMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotDeadline, hasDeadline = r.Context().Deadline()
+	}))
+
+	deadline := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	req := httptest.NewRequest("GET", "/capture", nil)
+	SetDeadlineHeader(req.Header, deadline)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !hasDeadline || !gotDeadline.Equal(deadline) {
+		t.Errorf("Expected deadline %v, got %v (%v)", deadline, gotDeadline, hasDeadline)
+	}
+
+	//Invalid or missing headers leave the context untouched
+	for _, value := range []string{"", "soon", "-1"} {
+		hasDeadline = false
+		req = httptest.NewRequest("GET", "/capture", nil)
+		req.Header.Set(DeadlineHeader, value)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if hasDeadline {
+			t.Errorf("Expected no deadline for header %q", value)
+		}
+	}
+
+	//Expired deadlines are rejected without calling the handler
+	called := false
+	expiredHandler := CaptureDeadlineMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	req = httptest.NewRequest("GET", "/capture", nil)
+	SetDeadlineHeader(req.Header, time.Now().Add(-time.Second))
+	rec := httptest.NewRecorder()
+	expiredHandler.ServeHTTP(rec, req)
+	if called || rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 without calling the handler, got %d (called: %v)", rec.Code, called)
+	}
+}
@@ -0,0 +1,68 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/*
+	Capture_directive.go
+
+	Besides capturing the request (CAPTURED) or passing it through
+	unchanged (UNHANDLED), a capture handler can ask Zoraxy to continue
+	handling the request with a rewritten path, or to redirect the client.
+
+	The directive is sent with ControlStatusCode_REWRITE and an empty body:
+	- X-Zoraxy-Rewrite-Path: the new path (and optional query) of the request
+	- X-Zoraxy-Redirect-Status: optional, if set to 301 / 302 / 307 / 308
+	  Zoraxy redirects the client to the new path instead of rewriting it internally
+
+	Precedence: the control status code decides the mode. CAPTURED always wins
+	as the plugin has already written the response, REWRITE is only honored
+	if no body is written, and ERROR / UNHANDLED ignore any directive headers
+*/
+
+const (
+	ControlStatusCode_REWRITE ControlStatusCode = 286 //Ask Zoraxy to process the traffic with a rewritten path or redirect the client
+)
+
+const (
+	DirectiveHeader_RewritePath    = "X-Zoraxy-Rewrite-Path"
+	DirectiveHeader_RedirectStatus = "X-Zoraxy-Redirect-Status"
+)
+
+// WriteRewriteDirective asks Zoraxy to continue handling the request with the new path
+func WriteRewriteDirective(w http.ResponseWriter, newPath string) error {
+	if !strings.HasPrefix(newPath, "/") {
+		return errors.New("rewrite path must start with /")
+	}
+	w.Header().Set(DirectiveHeader_RewritePath, newPath)
+	w.WriteHeader(int(ControlStatusCode_REWRITE))
+	return nil
+}
+
+// WriteRedirectDirective asks Zoraxy to redirect the client to the location with the given status code
+// The location can be a path (e.g. /new-path) or an absolute http(s) URL
+func WriteRedirectDirective(w http.ResponseWriter, location string, statusCode int) error {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return errors.New("redirect status code must be 301, 302, 307 or 308")
+	}
+	if location == "" {
+		return errors.New("redirect location cannot be empty")
+	}
+	if !strings.HasPrefix(location, "/") || strings.HasPrefix(location, "//") {
+		locationURL, err := url.Parse(location)
+		if err != nil || (locationURL.Scheme != "http" && locationURL.Scheme != "https") || locationURL.Host == "" {
+			return errors.New("redirect location must be a path starting with / or an absolute http(s) URL")
+		}
+	}
+	w.Header().Set(DirectiveHeader_RewritePath, location)
+	w.Header().Set(DirectiveHeader_RedirectStatus, strconv.Itoa(statusCode))
+	w.WriteHeader(int(ControlStatusCode_REWRITE))
+	return nil
+}
@@ -0,0 +1,77 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestWriteRewriteDirective(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := WriteRewriteDirective(rec, "/new-path?a=1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rec.Code != 286 {
+		t.Errorf("Expected status 286, got %d", rec.Code)
+	}
+	if rec.Header().Get(DirectiveHeader_RewritePath) != "/new-path?a=1" || rec.Header().Get(DirectiveHeader_RedirectStatus) != "" {
+		t.Errorf("Unexpected directive headers %v", rec.Header())
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %q", rec.Body.String())
+	}
+
+	for _, newPath := range []string{"", "new-path", "https://example.com/"} {
+		rec := httptest.NewRecorder()
+		if err := WriteRewriteDirective(rec, newPath); err == nil {
+			t.Errorf("%q: expected an error", newPath)
+		}
+		if rec.Code != http.StatusOK || rec.Header().Get(DirectiveHeader_RewritePath) != "" {
+			t.Errorf("%q: expected nothing to be written, got %d %v", newPath, rec.Code, rec.Header())
+		}
+	}
+}
+
+func TestWriteRedirectDirective(t *testing.T) {
+	tests := []struct {
+		location   string
+		statusCode int
+		valid      bool
+	}{
+		{"/new-path", http.StatusMovedPermanently, true},
+		{"/new-path?a=1", http.StatusFound, true},
+		{"https://example.com/login", http.StatusTemporaryRedirect, true},
+		{"http://example.com", http.StatusPermanentRedirect, true},
+		{"/new-path", http.StatusOK, false},
+		{"/new-path", http.StatusSeeOther, false},
+		{"/new-path", http.StatusNotModified, false},
+		{"/new-path", int(ControlStatusCode_REWRITE), false},
+		{"", http.StatusFound, false},
+		{"new-path", http.StatusFound, false},
+		{"//example.com/path", http.StatusFound, false},
+		{"javascript:alert(1)", http.StatusFound, false},
+		{"ftp://example.com/file", http.StatusFound, false},
+		{"https://", http.StatusFound, false},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		err := WriteRedirectDirective(rec, test.location, test.statusCode)
+		if (err == nil) != test.valid {
+			t.Errorf("%q %d: expected valid %v, got %v", test.location, test.statusCode, test.valid, err)
+			continue
+		}
+		if !test.valid {
+			if rec.Code != http.StatusOK || len(rec.Header()) != 0 {
+				t.Errorf("%q %d: expected nothing to be written, got %d %v", test.location, test.statusCode, rec.Code, rec.Header())
+			}
+			continue
+		}
+		if rec.Code != int(ControlStatusCode_REWRITE) {
+			t.Errorf("%q %d: expected status %d, got %d", test.location, test.statusCode, ControlStatusCode_REWRITE, rec.Code)
+		}
+		if rec.Header().Get(DirectiveHeader_RewritePath) != test.location || rec.Header().Get(DirectiveHeader_RedirectStatus) != strconv.Itoa(test.statusCode) {
+			t.Errorf("%q %d: unexpected directive headers %v", test.location, test.statusCode, rec.Header())
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+/*
+	Capture_header_match.go
+
+	This file implements the HeaderMatch conditions of a CaptureRule, so a
+	plugin only captures requests with (or without) specific headers. All
+	conditions of a rule must match. The value of a condition is one of
+
+	""           the header must be absent or empty
+	"re:<regex>" a value of the header must match the regular expression
+	"<glob>"     a value of the header must match the glob, where * matches
+	             any characters and ? matches a single character
+
+	Example (only capture unauthenticated requests):
+	zoraxy_plugin.CaptureRule{
+		CapturePath:     "/",
+		IncludeSubPaths: true,
+		HeaderMatch:     map[string]string{"Authorization": ""},
+	}
+*/
+
+const HeaderMatchRegexPrefix = "re:" //Prefix of HeaderMatch patterns that are regular expressions instead of globs
+
+var headerMatchCache sync.Map //pattern -> *regexp.Regexp
+
+// MatchesRequest checks if both the request path and the HeaderMatch conditions of the rule match the request
+func (r CaptureRule) MatchesRequest(req *http.Request) bool {
+	return r.Matches(req.URL.Path) && r.MatchesHeaders(req.Header)
+}
+
+// MatchesHeaders checks if the request headers satisfy all HeaderMatch conditions of the rule
+// A rule without HeaderMatch matches any headers, an invalid pattern never matches
+func (r CaptureRule) MatchesHeaders(header http.Header) bool {
+	for name, pattern := range r.HeaderMatch {
+		values := header.Values(name)
+		if pattern == "" {
+			if strings.Join(values, "") != "" {
+				return false
+			}
+			continue
+		}
+		re, err := compileHeaderMatch(pattern)
+		if err != nil {
+			return false
+		}
+		matched := false
+		for _, value := range values {
+			if re.MatchString(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// validateHeaderMatch checks that the header names are not empty and the patterns compile
+func (r CaptureRule) validateHeaderMatch() error {
+	for name, pattern := range r.HeaderMatch {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("capture rule " + r.CapturePath + " has an empty header match name")
+		}
+		if pattern == "" {
+			continue
+		}
+		if _, err := compileHeaderMatch(pattern); err != nil {
+			return errors.New("capture rule " + r.CapturePath + " has an invalid header match pattern for " + name + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// compileHeaderMatch compiles the regex or glob pattern, compiled patterns are cached as capture rules are matched per request
+func compileHeaderMatch(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := headerMatchCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	var expr string
+	if strings.HasPrefix(pattern, HeaderMatchRegexPrefix) {
+		expr = strings.TrimPrefix(pattern, HeaderMatchRegexPrefix)
+	} else {
+		//Convert the glob into an anchored regular expression
+		var sb strings.Builder
+		sb.WriteString("^")
+		for _, c := range pattern {
+			switch c {
+			case '*':
+				sb.WriteString(".*")
+			case '?':
+				sb.WriteString(".")
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		sb.WriteString("$")
+		expr = sb.String()
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	headerMatchCache.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,47 @@
+package zoraxy_plugin
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+/*
+	Capture_paths.go
+
+	This file checks the capture rules of an IntroSpect for duplicates and
+	overlaps, as the behavior of a request path captured by both the global
+	and the always capture ingress is ambiguous. Paths are compared case
+	insensitively and without trailing slashes, so /App/ and /app overlap
+*/
+
+// normalizedCaptureRule returns a copy of the rule with the capture path normalized for comparison
+func normalizedCaptureRule(rule CaptureRule) CaptureRule {
+	rule.CapturePath = "/" + strings.ToLower(strings.Trim(rule.CapturePath, "/"))
+	return rule
+}
+
+// validateCapturePaths rejects duplicate rules within a capture list and overlapping rules across the lists
+func (i *IntroSpect) validateCapturePaths() error {
+	for listName, rules := range map[string][]CaptureRule{"global capture path": i.GlobalCapturePaths, "always capture path": i.AlwaysCapturePaths} {
+		for a := 0; a < len(rules); a++ {
+			for b := a + 1; b < len(rules); b++ {
+				ruleA, ruleB := normalizedCaptureRule(rules[a]), normalizedCaptureRule(rules[b])
+				//The same path with different header conditions is a valid configuration
+				if ruleA.CapturePath == ruleB.CapturePath && maps.Equal(ruleA.HeaderMatch, ruleB.HeaderMatch) {
+					return fmt.Errorf("duplicated %s %q and %q", listName, rules[a].CapturePath, rules[b].CapturePath)
+				}
+			}
+		}
+	}
+
+	for _, globalRule := range i.GlobalCapturePaths {
+		for _, alwaysRule := range i.AlwaysCapturePaths {
+			ruleA, ruleB := normalizedCaptureRule(globalRule), normalizedCaptureRule(alwaysRule)
+			if ruleA.Matches(ruleB.CapturePath) || ruleB.Matches(ruleA.CapturePath) {
+				return fmt.Errorf("global capture path %q overlaps with always capture path %q", globalRule.CapturePath, alwaysRule.CapturePath)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package zoraxy_plugin
+
+import (
+	"cmp"
+	"slices"
+)
+
+/*
+	Capture_priority.go
+
+	This file defines the order Zoraxy invokes plugins capturing the
+	same request in. Plugins with a higher IntroSpect.CapturePriority
+	are invoked first, plugins without a priority use
+	CapturePriority_Default and ties are broken by plugin ID, so the
+	order of chained plugins stays the same across restarts
+
+	Example:
+	spec, err := NewIntroSpect("com.example.waf", "My WAF").
+		WithCapturePriority(zoraxy_plugin.CapturePriority_High).
+		Build()
+*/
+
+const (
+	CapturePriority_Low     = -100 //Invoked after plugins without a priority, e.g. response decorators
+	CapturePriority_Default = 0    //Priority of plugins that do not declare one
+	CapturePriority_High    = 100  //Invoked before plugins without a priority, e.g. firewalls and auth gates
+)
+
+// CompareCapturePriority returns a negative number if a is invoked before b, a positive number
+// if b is invoked before a and zero if both are the same plugin, use it with slices.SortFunc
+func CompareCapturePriority(a, b *IntroSpect) int {
+	if c := cmp.Compare(b.CapturePriority, a.CapturePriority); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// SortByCapturePriority sorts the plugins in the order Zoraxy invokes them
+func SortByCapturePriority(specs []*IntroSpect) {
+	slices.SortStableFunc(specs, CompareCapturePriority)
+}
@@ -0,0 +1,85 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+/*
+	Capture_recover.go
+
+	This file provides a recover middleware for capture handlers, so a
+	panicking handler answers ControlStatusCode_ERROR (580) and Zoraxy
+	continues handling the request, instead of crashing the plugin process.
+
+	The panic and its stack trace are printed to STDOUT, which Zoraxy
+	writes to its log. Set an ErrorResponder to customize the error body
+	(e.g. a JSON problem details document), it must write the ERROR status
+	code so Zoraxy knows the plugin did not handle the request
+
+	Example:
+	http.Handle("/g_handler", zoraxy_plugin.CaptureRecoverMiddleware(myCaptureHandler, nil))
+*/
+
+// ErrorResponder writes the response of a capture handler that failed with err
+type ErrorResponder func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultErrorResponder writes ControlStatusCode_ERROR with the error message as plain text body
+func DefaultErrorResponder(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(int(ControlStatusCode_ERROR))
+	w.Write([]byte(err.Error()))
+}
+
+// CaptureRecoverMiddleware recovers panics of the capture handler and responds with the ErrorResponder
+// If responder is nil, DefaultErrorResponder is used
+func CaptureRecoverMiddleware(next http.Handler, responder ErrorResponder) http.Handler {
+	if responder == nil {
+		responder = DefaultErrorResponder
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				//Let net/http abort the connection as requested
+				panic(recovered)
+			}
+			fmt.Println(logPrefix(r) + "[capture] panic while handling " + r.Method + " " + r.RequestURI + ": " + fmt.Sprint(recovered) + "\n" + string(debug.Stack()))
+			if sw.status != 0 {
+				//The response has been partially written, nothing else can be sent
+				return
+			}
+			responder(sw, r, errors.New("plugin capture handler panicked"))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// HandleCaptureFunc adapts a capture handler that returns an error into a http.Handler
+// A returned error is logged and answered with the ErrorResponder if nothing has been written yet.
+// Panics are recovered as in CaptureRecoverMiddleware. If responder is nil, DefaultErrorResponder is used
+// The request context carries the X-Zoraxy-Deadline deadline, see CaptureDeadlineMiddleware,
+// and the request ID, see RequestIDMiddleware
+func HandleCaptureFunc(fn func(w http.ResponseWriter, r *http.Request) error, responder ErrorResponder) http.Handler {
+	if responder == nil {
+		responder = DefaultErrorResponder
+	}
+	return RequestIDMiddleware(CaptureRecoverMiddleware(CaptureDeadlineMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+		fmt.Println(logPrefix(r) + "[capture] error while handling " + r.Method + " " + r.RequestURI + ": " + err.Error())
+		if sw, ok := w.(*statusResponseWriter); ok && sw.status != 0 {
+			return
+		}
+		responder(w, r, err)
+	})), responder))
+}
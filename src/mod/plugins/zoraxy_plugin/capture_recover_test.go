@@ -0,0 +1,44 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCaptureRecoverMiddleware(t *testing.T) {
+	handler := CaptureRecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/g_handler", nil))
+	if rec.Code != int(ControlStatusCode_ERROR) {
+		t.Errorf("Expected status 580, got %d", rec.Code)
+	}
+
+	//A custom responder sets the error body
+	handler = HandleCaptureFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("upstream unreachable")
+	}, func(w http.ResponseWriter, r *http.Request, err error) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(int(ControlStatusCode_ERROR))
+		w.Write([]byte(`{"title":"` + err.Error() + `"}`))
+	})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/g_handler", nil))
+	if rec.Code != int(ControlStatusCode_ERROR) || rec.Body.String() != `{"title":"upstream unreachable"}` {
+		t.Errorf("Expected the custom error response, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	//A handler that already responded is left untouched
+	handler = HandleCaptureFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(int(ControlStatusCode_CAPTURED))
+		return errors.New("late error")
+	}, nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/g_handler", nil))
+	if rec.Code != int(ControlStatusCode_CAPTURED) {
+		t.Errorf("Expected status 280, got %d", rec.Code)
+	}
+}
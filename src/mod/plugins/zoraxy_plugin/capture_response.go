@@ -0,0 +1,75 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"strconv"
+)
+
+/*
+	Capture_response.go
+
+	This file provides a response model for capture handlers that fully
+	handle a request, so headers (including repeated ones like Set-Cookie)
+	and the body are written in the order the status code protocol expects:
+	headers first, then ControlStatusCode_CAPTURED, then the body.
+
+	The real status code for the client is sent in X-Zoraxy-Status-Code,
+	as the HTTP status line is taken by the control status code
+
+	Example:
+	resp := zoraxy_plugin.NewDynamicCaptureResponse(http.StatusOK, []byte("hello"))
+	resp.SetCookie(&http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+	resp.Header.Add("Link", "</style.css>; rel=preload")
+	resp.Write(w)
+*/
+
+const (
+	DirectiveHeader_StatusCode = "X-Zoraxy-Status-Code"
+)
+
+type DynamicCaptureResponse struct {
+	StatusCode int         //The status code sent to the client, default 200
+	Header     http.Header //Headers sent to the client, repeated headers (e.g. Set-Cookie) are sent as separate lines
+	Body       []byte
+}
+
+// NewDynamicCaptureResponse creates a DynamicCaptureResponse with the given client status code and body
+func NewDynamicCaptureResponse(statusCode int, body []byte) *DynamicCaptureResponse {
+	return &DynamicCaptureResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{},
+		Body:       body,
+	}
+}
+
+// SetCookie adds a Set-Cookie header to the response, invalid cookies are dropped
+func (resp *DynamicCaptureResponse) SetCookie(cookie *http.Cookie) {
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	if v := cookie.String(); v != "" {
+		resp.Header.Add("Set-Cookie", v)
+	}
+}
+
+// Write writes the response to the client with ControlStatusCode_CAPTURED
+func (resp *DynamicCaptureResponse) Write(w http.ResponseWriter) error {
+	header := w.Header()
+	for key, values := range resp.Header {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	statusCode := resp.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	header.Set(DirectiveHeader_StatusCode, strconv.Itoa(statusCode))
+	if header.Get("Content-Type") == "" && len(resp.Body) > 0 {
+		header.Set("Content-Type", http.DetectContentType(resp.Body))
+	}
+	header.Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.WriteHeader(int(ControlStatusCode_CAPTURED))
+	_, err := w.Write(resp.Body)
+	return err
+}
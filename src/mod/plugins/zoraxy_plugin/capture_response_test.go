@@ -0,0 +1,36 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDynamicCaptureResponseHeaders(t *testing.T) {
+	resp := NewDynamicCaptureResponse(http.StatusCreated, []byte("created"))
+	resp.SetCookie(&http.Cookie{Name: "a", Value: "1"})
+	resp.SetCookie(&http.Cookie{Name: "b", Value: "2", HttpOnly: true})
+	resp.Header.Add("Vary", "Accept")
+	resp.Header.Add("Vary", "Cookie")
+
+	rec := httptest.NewRecorder()
+	if err := resp.Write(rec); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rec.Code != int(ControlStatusCode_CAPTURED) {
+		t.Errorf("Expected status 280, got %d", rec.Code)
+	}
+	if got := rec.Header().Get(DirectiveHeader_StatusCode); got != "201" {
+		t.Errorf("Expected client status 201, got %q", got)
+	}
+	cookies := rec.Header().Values("Set-Cookie")
+	if len(cookies) != 2 || cookies[0] != "a=1" || cookies[1] != "b=2; HttpOnly" {
+		t.Errorf("Expected two Set-Cookie headers, got %v", cookies)
+	}
+	if len(rec.Header().Values("Vary")) != 2 {
+		t.Errorf("Expected repeated Vary headers, got %v", rec.Header().Values("Vary"))
+	}
+	if rec.Body.String() != "created" {
+		t.Errorf("Unexpected body %q", rec.Body.String())
+	}
+}
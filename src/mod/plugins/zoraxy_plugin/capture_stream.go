@@ -0,0 +1,84 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+/*
+	Capture_stream.go
+
+	This file provides a streaming writer for capture handlers that
+	need to keep the connection open and push data to the client
+	(e.g. server-sent events or long polling)
+
+	Streaming responses are always sent with ControlStatusCode_CAPTURED,
+	as once the first byte is flushed the plugin has taken over the request
+	and Zoraxy can no longer fall back to its own handling
+*/
+
+type CaptureStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ctx     context.Context
+}
+
+// NewCaptureStream writes the captured status code with the given content type
+// and returns a CaptureStream that flushes every write to the client
+// Returns an error if the underlying ResponseWriter does not support flushing
+func NewCaptureStream(w http.ResponseWriter, r *http.Request, contentType string) (*CaptureStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("response writer does not support streaming")
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(int(ControlStatusCode_CAPTURED))
+	flusher.Flush()
+
+	return &CaptureStream{
+		w:       w,
+		flusher: flusher,
+		ctx:     r.Context(),
+	}, nil
+}
+
+// Write writes the data to the client and flushes it immediately
+// Returns the context error if the client has disconnected
+func (s *CaptureStream) Write(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := s.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	s.flusher.Flush()
+	return n, nil
+}
+
+// WriteEvent writes a server-sent event to the client
+// The event name can be empty, in which case the default "message" event is used by the browser
+func (s *CaptureStream) WriteEvent(event string, data string) error {
+	payload := ""
+	if event != "" {
+		payload += "event: " + event + "\n"
+	}
+	for _, line := range strings.Split(data, "\n") {
+		payload += "data: " + line + "\n"
+	}
+	_, err := s.Write([]byte(payload + "\n"))
+	return err
+}
+
+// Done returns a channel that is closed when the client disconnects
+func (s *CaptureStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
@@ -0,0 +1,73 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// noFlushResponseWriter hides the http.Flusher of the wrapped writer
+type noFlushResponseWriter struct {
+	http.ResponseWriter
+}
+
+func TestNewCaptureStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stream, err := NewCaptureStream(rec, httptest.NewRequest(http.MethodGet, "/events", nil), "text/event-stream")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	//The captured status code is flushed before any event is written
+	if rec.Code != int(ControlStatusCode_CAPTURED) || !rec.Flushed || rec.Body.Len() != 0 {
+		t.Errorf("Expected %d to be flushed before the first event, got %d (flushed %v)", ControlStatusCode_CAPTURED, rec.Code, rec.Flushed)
+	}
+	if rec.Header().Get("Content-Type") != "text/event-stream" || rec.Header().Get("Cache-Control") != "no-cache" || rec.Header().Get("X-Accel-Buffering") != "no" {
+		t.Errorf("Unexpected stream headers %v", rec.Header())
+	}
+
+	if err := stream.WriteEvent("update", "line 1\nline 2"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := stream.WriteEvent("", "hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "event: update\ndata: line 1\ndata: line 2\n\ndata: hello\n\n"
+	if rec.Body.String() != expected {
+		t.Errorf("Expected SSE framing %q, got %q", expected, rec.Body.String())
+	}
+
+	//The default content type is used if none is given
+	rec = httptest.NewRecorder()
+	if _, err := NewCaptureStream(rec, httptest.NewRequest(http.MethodGet, "/", nil), ""); err != nil || rec.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("Expected the default content type, got %q: %v", rec.Header().Get("Content-Type"), err)
+	}
+}
+
+func TestNewCaptureStreamWithoutFlusher(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if _, err := NewCaptureStream(noFlushResponseWriter{rec}, httptest.NewRequest(http.MethodGet, "/", nil), "text/event-stream"); err == nil {
+		t.Fatal("Expected an error for a writer without http.Flusher")
+	}
+	//Nothing is written so the handler can still answer the request
+	if rec.Body.Len() != 0 || len(rec.Header()) != 0 {
+		t.Errorf("Expected nothing to be written, got headers %v", rec.Header())
+	}
+}
+
+func TestCaptureStreamClientDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+	stream, err := NewCaptureStream(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cancel()
+	<-stream.Done()
+	if err := stream.WriteEvent("", "late"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled after the client disconnected, got %v", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected nothing to be written after the disconnect, got %q", rec.Body.String())
+	}
+}
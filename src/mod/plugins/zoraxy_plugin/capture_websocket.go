@@ -0,0 +1,82 @@
+package zoraxy_plugin
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+	Capture_websocket.go
+
+	WebSocket upgrades do not fit the request / response model of the
+	capture protocol, as the connection outlives the HTTP exchange.
+	A capture handler receiving an upgrade request (see IsWebSocketUpgrade)
+	has three options:
+
+	- UNHANDLED (284): Zoraxy performs the upgrade to the upstream of the
+	  matching proxy rule as if the plugin was not there
+	- UPGRADE (287): Zoraxy performs the upgrade itself and pipes the bytes
+	  bidirectionally to the target in X-Zoraxy-Upgrade-Target, or to the
+	  upstream of the proxy rule if the header is empty. The body is ignored
+	- Switching Protocols (101): the plugin completes the handshake itself,
+	  e.g. with HijackWebSocket, and Zoraxy pipes the client connection to
+	  the plugin until either side closes it. This counts as CAPTURED
+
+	Any other status code (including CAPTURED with a normal body) rejects the
+	upgrade, and the response is sent to the client as a plain HTTP response
+*/
+
+const (
+	ControlStatusCode_UPGRADE ControlStatusCode = 287 //Ask Zoraxy to perform the WebSocket upgrade and pipe the connection to the upgrade target
+)
+
+const (
+	DirectiveHeader_UpgradeTarget = "X-Zoraxy-Upgrade-Target"
+)
+
+// IsWebSocketUpgrade checks if the request is a WebSocket upgrade request
+func IsWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteUpgradeDirective asks Zoraxy to perform the WebSocket upgrade and pipe the connection to target
+// The target must be a ws:// or wss:// URL, or empty to use the upstream of the matching proxy rule
+func WriteUpgradeDirective(w http.ResponseWriter, target string) error {
+	if target != "" {
+		targetURL, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+		if targetURL.Scheme != "ws" && targetURL.Scheme != "wss" {
+			return errors.New("upgrade target must be a ws:// or wss:// URL")
+		}
+		w.Header().Set(DirectiveHeader_UpgradeTarget, target)
+	}
+	w.WriteHeader(int(ControlStatusCode_UPGRADE))
+	return nil
+}
+
+// HijackWebSocket takes over the connection of a WebSocket upgrade request
+// so the plugin can complete the handshake and speak the WebSocket protocol itself
+// The caller is responsible for writing the 101 Switching Protocols response and closing the connection
+func HijackWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	if !IsWebSocketUpgrade(r) {
+		return nil, nil, errors.New("request is not a WebSocket upgrade request")
+	}
+	//Use the response controller so wrapped writers (e.g. CaptureMiddleware) can still be hijacked
+	return http.NewResponseController(w).Hijack()
+}
@@ -0,0 +1,126 @@
+package zoraxy_plugin
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		name       string
+		upgrade    string
+		connection []string
+		expected   bool
+	}{
+		{"websocket upgrade", "websocket", []string{"Upgrade"}, true},
+		{"case insensitive", "WebSocket", []string{"upgrade"}, true},
+		{"connection token list", "websocket", []string{"keep-alive, Upgrade"}, true},
+		{"multiple connection headers", "websocket", []string{"keep-alive", "Upgrade"}, true},
+		{"missing connection upgrade", "websocket", []string{"keep-alive"}, false},
+		{"missing upgrade header", "", []string{"Upgrade"}, false},
+		{"other protocol", "h2c", []string{"Upgrade"}, false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if test.upgrade != "" {
+			req.Header.Set("Upgrade", test.upgrade)
+		}
+		for _, value := range test.connection {
+			req.Header.Add("Connection", value)
+		}
+		if got := IsWebSocketUpgrade(req); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestWriteUpgradeDirective(t *testing.T) {
+	tests := []struct {
+		target string
+		valid  bool
+	}{
+		{"", true},
+		{"ws://127.0.0.1:8080/ws", true},
+		{"wss://example.com/ws", true},
+		{"http://example.com/ws", false},
+		{"127.0.0.1:8080", false},
+		{"ws://[::1", false},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		err := WriteUpgradeDirective(rec, test.target)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: expected valid %v, got %v", test.target, test.valid, err)
+			continue
+		}
+		if !test.valid {
+			if rec.Code != http.StatusOK || rec.Header().Get(DirectiveHeader_UpgradeTarget) != "" {
+				t.Errorf("%q: expected nothing to be written, got %d %v", test.target, rec.Code, rec.Header())
+			}
+			continue
+		}
+		if rec.Code != int(ControlStatusCode_UPGRADE) {
+			t.Errorf("%q: expected status %d, got %d", test.target, ControlStatusCode_UPGRADE, rec.Code)
+		}
+		if got := rec.Header().Get(DirectiveHeader_UpgradeTarget); got != test.target {
+			t.Errorf("%q: unexpected upgrade target header %q", test.target, got)
+		}
+	}
+}
+
+func TestHijackWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Hijack through a wrapped writer, as the middlewares of the package do
+		conn, rw, err := HijackWebSocket(&statusResponseWriter{ResponseWriter: w}, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		line, _ := rw.ReadString('\n')
+		rw.WriteString("echo " + line)
+		rw.Flush()
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\nhello\n"))
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	if line, _ := reader.ReadString('\n'); line != "echo hello\n" {
+		t.Errorf("Expected the hijacked connection to echo, got %q", line)
+	}
+
+	//Plain requests are not hijacked
+	resp, err = http.Get(server.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a plain request, got %d", resp.StatusCode)
+	}
+
+	//Writers that do not support hijacking return an error
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	if _, _, err := HijackWebSocket(httptest.NewRecorder(), req); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Expected a not supported error, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+/*
+	Client_ip.go
+
+	This file provides IPv4 / IPv6 aware client IP parsing and CIDR
+	matching for allowlist, blocklist or geoblock style router plugins.
+
+	IPv4-mapped IPv6 addresses (e.g. ::ffff:192.0.2.1) are unmapped so
+	they match IPv4 ranges, and IPv6 zones are dropped before matching
+*/
+
+// ParseClientIP returns the parsed IP of the client, see GetClientIP for the lookup order
+func ParseClientIP(r *http.Request) (netip.Addr, error) {
+	return parseIPAddr(GetClientIP(r))
+}
+
+type CIDRSet struct {
+	prefixes []netip.Prefix
+}
+
+// NewCIDRSet compiles the CIDR ranges (e.g. 10.0.0.0/8, 2001:db8::/32) into a CIDRSet
+// Single IP addresses are accepted and treated as /32 or /128 ranges
+func NewCIDRSet(cidrs []string) (*CIDRSet, error) {
+	set := &CIDRSet{prefixes: make([]netip.Prefix, 0, len(cidrs))}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			addr, err := parseIPAddr(cidr)
+			if err != nil {
+				return nil, err
+			}
+			set.prefixes = append(set.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, errors.New("invalid CIDR range: " + cidr)
+		}
+		if prefix.Addr().Is4In6() {
+			//e.g. ::ffff:10.0.0.0/104 is matched as 10.0.0.0/8
+			if prefix.Bits() < 96 {
+				return nil, errors.New("invalid IPv4-mapped CIDR range: " + cidr)
+			}
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		set.prefixes = append(set.prefixes, prefix.Masked())
+	}
+	return set, nil
+}
+
+// Contains checks if the address is in any range of the set
+func (s *CIDRSet) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range s.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsRequest checks if the client IP of the request is in any range of the set
+// A request without a valid client IP never matches
+func (s *CIDRSet) ContainsRequest(r *http.Request) bool {
+	addr, err := ParseClientIP(r)
+	if err != nil {
+		return false
+	}
+	return s.Contains(addr)
+}
+
+// parseIPAddr parses an IP address, accepting the [::1] bracket form and zones
+func parseIPAddr(ip string) (netip.Addr, error) {
+	ip = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(ip), "["), "]")
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Addr{}, errors.New("invalid IP address: " + ip)
+	}
+	return addr.Unmap().WithZone(""), nil
+}
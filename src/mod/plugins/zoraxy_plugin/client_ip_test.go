@@ -0,0 +1,62 @@
+package zoraxy_plugin
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestParseClientIP(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.1":        "192.0.2.1",
+		"::ffff:192.0.2.1": "192.0.2.1",
+		"[2001:db8::1]":    "2001:db8::1",
+		"fe80::1%eth0":     "fe80::1",
+	}
+	for header, expected := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Real-Ip", header)
+		addr, err := ParseClientIP(req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", header, err)
+		}
+		if addr.String() != expected {
+			t.Errorf("%s: expected %s, got %s", header, expected, addr)
+		}
+	}
+
+	//Fallback to the remote address
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[2001:db8::2]:40000"
+	if addr, err := ParseClientIP(req); err != nil || addr.String() != "2001:db8::2" {
+		t.Errorf("Expected 2001:db8::2 from the remote address, got %s %v", addr, err)
+	}
+}
+
+func TestCIDRSet(t *testing.T) {
+	set, err := NewCIDRSet([]string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.10", "::ffff:172.16.0.0/108"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tests := map[string]bool{
+		"10.1.2.3":          true,
+		"::ffff:10.1.2.3":   true,
+		"11.0.0.1":          false,
+		"2001:db8:1::1":     true,
+		"2001:db9::1":       false,
+		"192.0.2.10":        true,
+		"192.0.2.11":        false,
+		"172.16.5.5":        true,
+		"fe80::1%eth0":      false,
+		"2001:db8::1%wlan0": true,
+	}
+	for ip, expected := range tests {
+		if got := set.Contains(netip.MustParseAddr(ip)); got != expected {
+			t.Errorf("%s: expected %v, got %v", ip, expected, got)
+		}
+	}
+
+	if _, err := NewCIDRSet([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected an error for an invalid CIDR range")
+	}
+}
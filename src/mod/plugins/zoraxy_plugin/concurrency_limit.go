@@ -0,0 +1,128 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	Concurrency_limit.go
+
+	This file provides a limiter for the number of requests handled at the
+	same time, for plugins wrapping a backend that cannot handle bursts.
+
+	Requests over the limit wait up to QueueTimeout for a free slot (0
+	rejects them immediately). Rejected UI requests answer 503 Service
+	Unavailable with a Retry-After header. Rejected capture requests answer
+	ControlStatusCode_CAPTURED with the 503 status code for the client, so
+	the request is not passed to the upstream unchecked
+
+	Example:
+	limiter := zoraxy_plugin.NewConcurrencyLimiter(8)
+	limiter.QueueTimeout = 2 * time.Second
+	limiter.Metrics = metrics
+	http.Handle("/ui/", limiter.Middleware(uiRouter.Handler()))
+	http.Handle("/g_handler", limiter.CaptureMiddleware(captureHandler))
+*/
+
+type ConcurrencyLimiter struct {
+	QueueTimeout time.Duration    //Max duration a request waits for a free slot, 0 to reject immediately
+	RetryAfter   time.Duration    //Retry-After sent with the 503 response, default 1s
+	Metrics      *MetricsRegistry //If set, rejections are counted in Metric_ConcurrencyRejected
+
+	slots    chan struct{}
+	inFlight atomic.Int64
+}
+
+// NewConcurrencyLimiter creates a limiter allowing max requests at the same time, max below 1 is treated as 1
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	if max < 1 {
+		max = 1
+	}
+	return &ConcurrencyLimiter{
+		RetryAfter: time.Second,
+		slots:      make(chan struct{}, max),
+	}
+}
+
+// Acquire waits up to QueueTimeout for a free slot and returns true if the request may proceed
+// Call Release once the request is handled. The wait ends early if the request context is cancelled
+func (l *ConcurrencyLimiter) Acquire(r *http.Request) bool {
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Add(1)
+		return true
+	default:
+	}
+	if l.QueueTimeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(l.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Add(1)
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// Release frees the slot taken by Acquire
+func (l *ConcurrencyLimiter) Release() {
+	l.inFlight.Add(-1)
+	<-l.slots
+}
+
+// InFlight returns the number of requests currently holding a slot
+func (l *ConcurrencyLimiter) InFlight() int {
+	return int(l.inFlight.Load())
+}
+
+// Middleware limits the concurrency of UI or API handlers, rejected requests answer 503 Service Unavailable
+func (l *ConcurrencyLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.Acquire(r) {
+			l.reject("ui")
+			w.Header().Set("Retry-After", l.retryAfterSeconds())
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		defer l.Release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CaptureMiddleware limits the concurrency of capture handlers, rejected requests are captured with a 503 response
+func (l *ConcurrencyLimiter) CaptureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.Acquire(r) {
+			l.reject("capture")
+			resp := NewDynamicCaptureResponse(http.StatusServiceUnavailable, []byte("Service Unavailable\n"))
+			resp.Header.Set("Content-Type", "text/plain; charset=utf-8")
+			resp.Header.Set("Retry-After", l.retryAfterSeconds())
+			resp.Write(w)
+			return
+		}
+		defer l.Release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *ConcurrencyLimiter) reject(handler string) {
+	if l.Metrics != nil {
+		l.Metrics.Inc(Metric_ConcurrencyRejected, map[string]string{"handler": handler})
+	}
+}
+
+func (l *ConcurrencyLimiter) retryAfterSeconds() string {
+	seconds := int((l.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
@@ -0,0 +1,63 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	limiter.Metrics = NewMetricsRegistry("org.example.test")
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	ui := limiter.Middleware(slow)
+	capture := limiter.CaptureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ui.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ui/", nil))
+	}()
+	<-started
+	if limiter.InFlight() != 1 {
+		t.Errorf("Expected 1 request in flight, got %d", limiter.InFlight())
+	}
+
+	rec := httptest.NewRecorder()
+	ui.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 503 with Retry-After, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	capture.ServeHTTP(rec, httptest.NewRequest("GET", "/g_handler", nil))
+	if rec.Code != int(ControlStatusCode_CAPTURED) || rec.Header().Get(DirectiveHeader_StatusCode) != "503" {
+		t.Errorf("Expected a captured 503, got %d %q", rec.Code, rec.Header().Get(DirectiveHeader_StatusCode))
+	}
+	if limiter.Metrics.get(Metric_ConcurrencyRejected, map[string]string{"handler": "ui"}) != 1 || limiter.Metrics.get(Metric_ConcurrencyRejected, map[string]string{"handler": "capture"}) != 1 {
+		t.Error("Expected the rejections to be counted")
+	}
+
+	//Queued requests proceed once the slot is released
+	limiter.QueueTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	rec = httptest.NewRecorder()
+	capture.ServeHTTP(rec, httptest.NewRequest("GET", "/g_handler", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the queued request to be handled, got %d", rec.Code)
+	}
+	wg.Wait()
+	if limiter.InFlight() != 0 {
+		t.Errorf("Expected no request in flight, got %d", limiter.InFlight())
+	}
+}
@@ -0,0 +1,234 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Config_diff.go
+
+	This file computes a structured diff between two ConfigureSpec
+	so a plugin receiving a new configuration knows exactly which
+	fields changed. The options marked as Secret in the settings schema
+	are redacted, and so are the fields whose name looks like a secret
+	(e.g. event_secret) as a fallback for options without a schema
+
+	Example:
+	recorder := &zoraxy_plugin.ConfigDiffRecorder{Spec: pluginSpec}
+	uiRouter.RegisterConfigDiffHandler(recorder, nil)
+	uiRouter.RegisterConfigReloadHandler(func(newSpec zoraxy_plugin.ConfigureSpec) error {
+		recorder.Record(currentSpec, &newSpec)
+		currentSpec = &newSpec
+		return nil
+	}, nil)
+*/
+
+const redactedValue = "[redacted]"
+
+// Field names containing any of these words are treated as secrets
+var secretFieldKeywords = []string{"secret", "token", "password", "passwd", "apikey", "api_key", "private"}
+
+type ConfigChange struct {
+	Field    string `json:"field"`     //JSON path of the changed field, e.g. runtime_const.zoraxy_version
+	OldValue string `json:"old_value"` //Value before the change, empty if the field was added
+	NewValue string `json:"new_value"` //Value after the change, empty if the field was removed
+}
+
+// ConfigDiffPath is the path of the config diff endpoint relative to the UI path of the plugin
+const ConfigDiffPath = "/config_diff"
+
+type ConfigDiffRecorder struct {
+	Spec        *IntroSpect //IntroSpect of the plugin, the options marked as Secret in its settings schema are redacted
+	Output      io.Writer   //Where the changes are logged with the secrets redacted, default os.Stdout
+	mu          sync.RWMutex
+	lastChanges []ConfigChange
+	lastUpdate  time.Time
+}
+
+// DiffConfigureSpec returns the list of changed fields between the old and new ConfigureSpec
+// The changes are sorted by field name. A nil spec is treated as an empty spec
+// Only the fields whose name looks like a secret are redacted, use IntroSpect.DiffConfigureSpec
+// to also redact the options marked as Secret in the settings schema
+func DiffConfigureSpec(oldSpec *ConfigureSpec, newSpec *ConfigureSpec) []ConfigChange {
+	return diffConfigureSpec(oldSpec, newSpec, nil)
+}
+
+// DiffConfigureSpec returns the list of changed fields between the old and new ConfigureSpec,
+// redacting the options marked as Secret in the settings schema of the plugin
+func (i *IntroSpect) DiffConfigureSpec(oldSpec *ConfigureSpec, newSpec *ConfigureSpec) []ConfigChange {
+	return diffConfigureSpec(oldSpec, newSpec, i.SettingsSchema)
+}
+
+func diffConfigureSpec(oldSpec *ConfigureSpec, newSpec *ConfigureSpec, schema []SettingField) []ConfigChange {
+	secretFields := map[string]bool{}
+	for _, field := range schema {
+		if field.Secret {
+			secretFields["options."+field.Key] = true
+		}
+	}
+
+	oldFields := flattenConfigureSpec(oldSpec)
+	newFields := flattenConfigureSpec(newSpec)
+
+	changes := []ConfigChange{}
+	for field, newValue := range newFields {
+		oldValue, ok := oldFields[field]
+		if ok && oldValue == newValue {
+			continue
+		}
+		changes = append(changes, redactConfigChange(ConfigChange{Field: field, OldValue: oldValue, NewValue: newValue}, secretFields))
+	}
+	for field, oldValue := range oldFields {
+		if _, ok := newFields[field]; !ok {
+			changes = append(changes, redactConfigChange(ConfigChange{Field: field, OldValue: oldValue}, secretFields))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// HasChanged returns true if the given field (or any of its sub-fields) is in the change list
+func HasChanged(changes []ConfigChange, field string) bool {
+	for _, change := range changes {
+		if change.Field == field || strings.HasPrefix(change.Field, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Record computes the diff between the old and new ConfigureSpec,
+// logs it to STDOUT (forwarded to the Zoraxy log) and keeps it as the most recent diff
+// The secret option values of both specs are redacted from the log even if they show up in other fields
+func (d *ConfigDiffRecorder) Record(oldSpec *ConfigureSpec, newSpec *ConfigureSpec) []ConfigChange {
+	spec := d.Spec
+	if spec == nil {
+		spec = &IntroSpect{}
+	}
+	changes := spec.DiffConfigureSpec(oldSpec, newSpec)
+	d.mu.Lock()
+	d.lastChanges = changes
+	d.lastUpdate = time.Now()
+	d.mu.Unlock()
+
+	output := d.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	logger := NewRedactingWriter(output)
+	for _, thisSpec := range []*ConfigureSpec{oldSpec, newSpec} {
+		if thisSpec != nil {
+			logger.AddSecrets(thisSpec.EventSecret)
+			logger.AddSecrets(spec.SecretSettings(thisSpec.Options)...)
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintln(logger, "Configuration reloaded with no changes")
+		return changes
+	}
+	for _, change := range changes {
+		fmt.Fprintln(logger, "Configuration changed: "+change.Field+" "+change.OldValue+" -> "+change.NewValue)
+	}
+	return changes
+}
+
+// LastChanges returns the most recent recorded diff and the time it was recorded
+func (d *ConfigDiffRecorder) LastChanges() ([]ConfigChange, time.Time) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.lastChanges, d.lastUpdate
+}
+
+// ServeHTTP serves the most recent recorded diff as JSON
+func (d *ConfigDiffRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	changes, updateTime := d.LastChanges()
+	if changes == nil {
+		changes = []ConfigChange{}
+	}
+	js, _ := json.Marshal(struct {
+		Changes   []ConfigChange `json:"changes"`
+		UpdatedAt int64          `json:"updated_at"`
+	}{
+		Changes:   changes,
+		UpdatedAt: updateTime.Unix(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// RegisterConfigDiffHandler serves the most recent diff of the recorder at ConfigDiffPath below the UI path
+// if mux is nil, the handler will be registered to http.DefaultServeMux
+func (p *PluginUiRouter) RegisterConfigDiffHandler(recorder *ConfigDiffRecorder, mux *http.ServeMux) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(p.HandlerPrefix+ConfigDiffPath, recorder)
+}
+
+// flattenConfigureSpec converts the ConfigureSpec into a map of JSON path to JSON encoded value
+func flattenConfigureSpec(spec *ConfigureSpec) map[string]string {
+	results := map[string]string{}
+	if spec == nil {
+		return results
+	}
+	js, err := json.Marshal(spec)
+	if err != nil {
+		return results
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(js, &tree); err != nil {
+		return results
+	}
+	flattenConfigValue("", tree, results)
+	return results
+}
+
+func flattenConfigValue(prefix string, value interface{}, results map[string]string) {
+	if object, ok := value.(map[string]interface{}); ok && len(object) > 0 {
+		for key, child := range object {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenConfigValue(key, child, results)
+		}
+		return
+	}
+	js, _ := json.Marshal(value)
+	results[prefix] = string(js)
+}
+
+// redactConfigChange redacts the values of the fields in secretFields or whose name looks like a secret
+func redactConfigChange(change ConfigChange, secretFields map[string]bool) ConfigChange {
+	if !secretFields[change.Field] && !isSecretFieldName(change.Field) {
+		return change
+	}
+	if change.OldValue != "" {
+		change.OldValue = redactedValue
+	}
+	if change.NewValue != "" {
+		change.NewValue = redactedValue
+	}
+	return change
+}
+
+func isSecretFieldName(field string) bool {
+	fieldName := strings.ToLower(field)
+	for _, keyword := range secretFieldKeywords {
+		if strings.Contains(fieldName, keyword) {
+			return true
+		}
+	}
+	return false
+}
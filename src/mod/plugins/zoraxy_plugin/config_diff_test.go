@@ -0,0 +1,106 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiffConfigureSpec(t *testing.T) {
+	oldSpec := &ConfigureSpec{
+		Port:        8080,
+		EventSecret: "old-secret",
+		Options:     map[string]string{"endpoint": "https://example.com", "debug": "true"},
+	}
+	newSpec := &ConfigureSpec{
+		Port:        8081,
+		EventSecret: "new-secret",
+		Options:     map[string]string{"endpoint": "https://example.com", "mode": "fast"},
+	}
+
+	changes := DiffConfigureSpec(oldSpec, newSpec)
+	fields := []string{}
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	if strings.Join(fields, ",") != "event_secret,options.debug,options.mode,port" {
+		t.Fatalf("Unexpected changed fields %v", fields)
+	}
+	if changes[0].OldValue != redactedValue || changes[0].NewValue != redactedValue {
+		t.Errorf("Expected the event secret to be redacted by name, got %+v", changes[0])
+	}
+	if changes[1].OldValue != `"true"` || changes[1].NewValue != "" {
+		t.Errorf("Expected the removed option to keep its old value only, got %+v", changes[1])
+	}
+	if changes[2].OldValue != "" || changes[2].NewValue != `"fast"` {
+		t.Errorf("Expected the added option to have a new value only, got %+v", changes[2])
+	}
+	if !HasChanged(changes, "options") || !HasChanged(changes, "port") || HasChanged(changes, "options.endpoint") {
+		t.Error("Unexpected HasChanged result")
+	}
+	if len(DiffConfigureSpec(newSpec, newSpec)) != 0 {
+		t.Error("Expected no changes between identical specs")
+	}
+}
+
+func TestDiffConfigureSpecSchemaSecret(t *testing.T) {
+	spec := &IntroSpect{SettingsSchema: []SettingField{
+		{Key: "dsn", Label: "Database", Type: SettingFieldType_String, Secret: true},
+		{Key: "endpoint", Label: "Endpoint", Type: SettingFieldType_String},
+	}}
+	oldSpec := &ConfigureSpec{Options: map[string]string{"dsn": "postgres://user:hunter2@db", "endpoint": "a"}}
+	newSpec := &ConfigureSpec{Options: map[string]string{"dsn": "postgres://user:t0psecret@db", "endpoint": "b"}}
+
+	//The option name does not look like a secret, only the schema marks it
+	if changes := DiffConfigureSpec(oldSpec, newSpec); changes[0].NewValue == redactedValue {
+		t.Errorf("Expected the keyword fallback not to redact dsn, got %+v", changes[0])
+	}
+	changes := spec.DiffConfigureSpec(oldSpec, newSpec)
+	if len(changes) != 2 || changes[0].Field != "options.dsn" || changes[0].OldValue != redactedValue || changes[0].NewValue != redactedValue {
+		t.Errorf("Expected the secret option to be redacted, got %+v", changes)
+	}
+	if changes[1].NewValue != `"b"` {
+		t.Errorf("Expected the plain option to be kept, got %+v", changes[1])
+	}
+}
+
+func TestConfigDiffRecorder(t *testing.T) {
+	var logs bytes.Buffer
+	recorder := &ConfigDiffRecorder{
+		Spec: &IntroSpect{SettingsSchema: []SettingField{
+			{Key: "dsn", Label: "Database", Type: SettingFieldType_String, Secret: true},
+		}},
+		Output: &logs,
+	}
+	//The secret shows up in a non secret field too, the log must not reveal it
+	oldSpec := &ConfigureSpec{Options: map[string]string{"dsn": "hunter2", "note": "a"}}
+	newSpec := &ConfigureSpec{Options: map[string]string{"dsn": "t0psecret", "note": "was hunter2"}}
+	recorder.Record(oldSpec, newSpec)
+	if strings.Contains(logs.String(), "hunter2") || strings.Contains(logs.String(), "t0psecret") {
+		t.Errorf("Expected the secrets to be redacted from the log, got %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), "Configuration changed: options.note") {
+		t.Errorf("Expected the change to be logged, got %q", logs.String())
+	}
+
+	mux := http.NewServeMux()
+	newTestUiRouter().RegisterConfigDiffHandler(recorder, mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui"+ConfigDiffPath, nil))
+	response := struct {
+		Changes   []ConfigChange `json:"changes"`
+		UpdatedAt int64          `json:"updated_at"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Changes) != 2 || response.Changes[0].NewValue != redactedValue || response.UpdatedAt == 0 {
+		t.Errorf("Unexpected config diff response %s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "t0psecret") {
+		t.Errorf("Expected the secret to be redacted from the response, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,93 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+	Configure_args.go
+
+	This file parses the -configure payload from argv. If the JSON payload
+	contains spaces and was not quoted as a single argument (e.g. a start
+	script with an unquoted $CONFIG), the shell splits it across several
+	argv elements and only the first chunk is seen by the flag.
+
+	The truncated chunk is joined with the following arguments until the
+	payload is valid JSON again. The chunks are joined with a single space,
+	so whitespace inside JSON strings may differ from the original payload.
+	If the payload cannot be completed, ErrConfigureSpecTruncated is returned
+	with a hint to quote the argument
+*/
+
+// ErrConfigureSpecTruncated is returned when the -configure payload is incomplete JSON, usually due to missing argv quoting
+var ErrConfigureSpecTruncated = errors.New("configure spec JSON is truncated, quote the -configure payload as a single argument (e.g. -configure='{...}')")
+
+// parseConfigureArg parses the -configure payload in args[0], joining the following args if it was split
+// consumed is the number of args used for the payload
+func parseConfigureArg(args []string) (configSpec *ConfigureSpec, consumed int, err error) {
+	if len(args) == 0 {
+		return nil, 0, ErrConfigureSpecTruncated
+	}
+	payload := args[0]
+	for consumed = 1; ; consumed++ {
+		configSpec, err = unmarshalConfigureArg(payload)
+		if !errors.Is(err, ErrConfigureSpecTruncated) {
+			break
+		}
+		if consumed >= len(args) {
+			return nil, consumed, err
+		}
+		payload += " " + args[consumed]
+	}
+	if err != nil {
+		if consumed > 1 {
+			//The first chunk was truncated, the following args do not complete it
+			return nil, consumed, fmt.Errorf("%w: %v", ErrConfigureSpecTruncated, err)
+		}
+		return nil, consumed, err
+	}
+	return configSpec, consumed, nil
+}
+
+// readConfigureArg parses the -configure payload in args[0] as parseConfigureArg and logs a split payload
+func readConfigureArg(args []string) (*ConfigureSpec, error) {
+	configSpec, consumed, err := parseConfigureArg(args)
+	if err == nil && consumed > 1 {
+		fmt.Println("[configure] the -configure payload was split across " + strconv.Itoa(consumed) + " arguments, quote it as a single argument")
+	}
+	return configSpec, err
+}
+
+// unmarshalConfigureArg decodes the payload, returning ErrConfigureSpecTruncated if the JSON ends early
+func unmarshalConfigureArg(payload string) (*ConfigureSpec, error) {
+	var configSpec ConfigureSpec
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	if err := decoder.Decode(&configSpec); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrConfigureSpecTruncated
+		}
+		return nil, fmt.Errorf("invalid configure spec: %w", err)
+	}
+	if decoder.More() {
+		return nil, errors.New("invalid configure spec: unexpected data after the JSON payload")
+	}
+	return &configSpec, nil
+}
+
+// configureArgLength returns the number of args used by the split form -configure payload starting at args[0]
+func configureArgLength(args []string) int {
+	if len(args) > 0 && args[0] == ConfigureStdinArg {
+		return 1
+	}
+	_, consumed, err := parseConfigureArg(args)
+	if err != nil {
+		//Invalid payload, only the first arg is skipped
+		return 1
+	}
+	return consumed
+}
@@ -0,0 +1,79 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"strings"
+)
+
+/*
+	Conflicts.go
+
+	This file checks if two plugins are incompatible when enabled together.
+	A plugin declares conflicts in IntroSpect.Conflicts as either
+	- the ID of another plugin (e.g. com.example.otherplugin), or
+	- a capture path glob starting with / (e.g. /api/*), matched with path.Match
+	  against the global capture paths of the other plugin
+
+	Two plugins that globally capture the same path also conflict,
+	even if neither of them declares it
+*/
+
+// ConflictsWith checks if the plugin conflicts with the other plugin, the reason is returned for display
+func (i *IntroSpect) ConflictsWith(other *IntroSpect) (bool, string) {
+	if other == nil || other.ID == i.ID {
+		return false, ""
+	}
+	if slices.Contains(i.Conflicts, other.ID) {
+		return true, i.Name + " declares a conflict with " + other.Name
+	}
+	if slices.Contains(other.Conflicts, i.ID) {
+		return true, other.Name + " declares a conflict with " + i.Name
+	}
+	if capturePath, ok := matchConflictGlobs(i.Conflicts, other.GlobalCapturePaths); ok {
+		return true, i.Name + " conflicts with " + other.Name + " capturing " + capturePath
+	}
+	if capturePath, ok := matchConflictGlobs(other.Conflicts, i.GlobalCapturePaths); ok {
+		return true, other.Name + " conflicts with " + i.Name + " capturing " + capturePath
+	}
+	for _, rule := range i.GlobalCapturePaths {
+		for _, otherRule := range other.GlobalCapturePaths {
+			if rule.Matches(otherRule.CapturePath) || otherRule.Matches(rule.CapturePath) {
+				return true, i.Name + " and " + other.Name + " both globally capture " + otherRule.CapturePath
+			}
+		}
+	}
+	return false, ""
+}
+
+// matchConflictGlobs returns the first capture path matching one of the capture path globs
+func matchConflictGlobs(conflicts []string, rules []CaptureRule) (string, bool) {
+	for _, glob := range conflicts {
+		if !strings.HasPrefix(glob, "/") {
+			//Plugin ID
+			continue
+		}
+		for _, rule := range rules {
+			if matched, err := path.Match(glob, rule.CapturePath); err == nil && matched {
+				return rule.CapturePath, true
+			}
+		}
+	}
+	return "", false
+}
+
+// validateConflicts checks that the capture path globs are valid
+func (i *IntroSpect) validateConflicts() error {
+	for _, conflict := range i.Conflicts {
+		if conflict == "" {
+			return errors.New("plugin conflict entry is empty")
+		}
+		if strings.HasPrefix(conflict, "/") {
+			if _, err := path.Match(conflict, ""); err != nil {
+				return errors.New("invalid plugin conflict capture path glob: " + conflict)
+			}
+		}
+	}
+	return nil
+}
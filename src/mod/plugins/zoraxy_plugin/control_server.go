@@ -0,0 +1,197 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+/*
+	Control_server.go
+
+	This file provides a JSON-RPC 2.0 control channel for commands sent
+	by Zoraxy to the plugin (e.g. "drain connections now" or "return
+	current stats"), served at ControlPath on the plugin web server.
+
+	Register the methods and mount the server on your mux:
+	zoraxy_plugin.RegisterControlMethod("stats", func(params json.RawMessage) (any, error) {
+		return map[string]int{"active": active}, nil
+	})
+	zoraxy_plugin.ServeControl(nil)
+
+	Errors returned by a method are sent as JSON-RPC errors with code
+	ControlErrorCode_MethodError, requests without an id are treated as
+	notifications and get no response, batch requests are supported
+*/
+
+const ControlPath = "/__control"
+
+const (
+	ControlErrorCode_ParseError     = -32700
+	ControlErrorCode_InvalidRequest = -32600
+	ControlErrorCode_MethodNotFound = -32601
+	ControlErrorCode_InternalError  = -32603
+	ControlErrorCode_MethodError    = -32000 //Error returned by the registered method
+)
+
+type ControlMethod func(params json.RawMessage) (any, error)
+
+type ControlServer struct {
+	mu      sync.RWMutex
+	methods map[string]ControlMethod
+}
+
+type controlRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type controlError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type controlResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *controlError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// DefaultControlServer is the ControlServer used by RegisterControlMethod and ServeControl
+var DefaultControlServer = NewControlServer()
+
+// NewControlServer creates a new ControlServer without any methods
+func NewControlServer() *ControlServer {
+	return &ControlServer{
+		methods: map[string]ControlMethod{},
+	}
+}
+
+// RegisterControlMethod registers a method to the DefaultControlServer
+func RegisterControlMethod(name string, fn ControlMethod) {
+	DefaultControlServer.RegisterControlMethod(name, fn)
+}
+
+// ServeControl mounts the DefaultControlServer at ControlPath, only accepting the calls signed by Zoraxy
+// If mux is nil, the handler will be registered to http.DefaultServeMux
+func ServeControl(mux *http.ServeMux) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(ControlPath, RequireZoraxy(DefaultControlServer))
+}
+
+// RegisterControlMethod registers a method, registering an existing name replaces it
+func (c *ControlServer) RegisterControlMethod(name string, fn ControlMethod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.methods[name] = fn
+}
+
+// ServeHTTP handles the JSON-RPC 2.0 requests sent by Zoraxy
+func (c *ControlServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		writeControlResponse(w, controlResponse{
+			JSONRPC: "2.0",
+			Error:   &controlError{Code: ControlErrorCode_ParseError, Message: "parse error"},
+			ID:      json.RawMessage("null"),
+		})
+		return
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		//Batch request
+		var requests []json.RawMessage
+		if err := json.Unmarshal(trimmed, &requests); err != nil || len(requests) == 0 {
+			writeControlResponse(w, invalidControlRequest())
+			return
+		}
+		responses := []controlResponse{}
+		for _, request := range requests {
+			if resp, ok := c.dispatch(request); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeControlResponse(w, responses)
+		return
+	}
+
+	resp, ok := c.dispatch(trimmed)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeControlResponse(w, resp)
+}
+
+// dispatch calls the method of a single request, returns false for notifications
+func (c *ControlServer) dispatch(raw json.RawMessage) (controlResponse, bool) {
+	var req controlRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return invalidControlRequest(), true
+	}
+	isNotification := len(req.ID) == 0
+	resp := controlResponse{JSONRPC: "2.0", ID: req.ID}
+
+	c.mu.RLock()
+	fn, ok := c.methods[req.Method]
+	c.mu.RUnlock()
+	if !ok {
+		resp.Error = &controlError{Code: ControlErrorCode_MethodNotFound, Message: "method not found: " + req.Method}
+		return resp, !isNotification
+	}
+
+	result, err := callControlMethod(fn, req.Params)
+	if err != nil {
+		resp.Error = err
+		return resp, !isNotification
+	}
+	if result == nil {
+		//JSON-RPC requires either a result or an error
+		result = json.RawMessage("null")
+	}
+	resp.Result = result
+	return resp, !isNotification
+}
+
+// callControlMethod calls the method and converts errors and panics into JSON-RPC errors
+func callControlMethod(fn ControlMethod, params json.RawMessage) (result any, rpcErr *controlError) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = nil
+			rpcErr = &controlError{Code: ControlErrorCode_InternalError, Message: "internal error"}
+		}
+	}()
+	result, err := fn(params)
+	if err != nil {
+		return nil, &controlError{Code: ControlErrorCode_MethodError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+func invalidControlRequest() controlResponse {
+	return controlResponse{
+		JSONRPC: "2.0",
+		Error:   &controlError{Code: ControlErrorCode_InvalidRequest, Message: "invalid request"},
+		ID:      json.RawMessage("null"),
+	}
+}
+
+func writeControlResponse(w http.ResponseWriter, resp any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
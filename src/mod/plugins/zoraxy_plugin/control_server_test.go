@@ -0,0 +1,49 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestControlServer(t *testing.T) {
+	server := NewControlServer()
+	server.RegisterControlMethod("add", func(params json.RawMessage) (any, error) {
+		var numbers []int
+		if err := json.Unmarshal(params, &numbers); err != nil {
+			return nil, errors.New("params must be a list of numbers")
+		}
+		sum := 0
+		for _, n := range numbers {
+			sum += n
+		}
+		return sum, nil
+	})
+
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`, `{"jsonrpc":"2.0","result":3,"id":1}`},
+		{`{"jsonrpc":"2.0","method":"add","params":"x","id":"a"}`, `{"jsonrpc":"2.0","error":{"code":-32000,"message":"params must be a list of numbers"},"id":"a"}`},
+		{`{"jsonrpc":"2.0","method":"drain","id":2}`, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found: drain"},"id":2}`},
+		{`{"method":"add","id":3}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}`},
+		{`{`, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`},
+		{`[{"jsonrpc":"2.0","method":"add","params":[1],"id":1},{"jsonrpc":"2.0","method":"add","params":[2]}]`, `[{"jsonrpc":"2.0","result":1,"id":1}]`},
+		{`{"jsonrpc":"2.0","method":"add","params":[1]}`, ``},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", ControlPath, strings.NewReader(test.body))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if got := strings.TrimSpace(rec.Body.String()); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.body, test.expected, got)
+		}
+		if test.expected == "" && rec.Code != http.StatusNoContent {
+			t.Errorf("%s: expected status 204 for a notification, got %d", test.body, rec.Code)
+		}
+	}
+}
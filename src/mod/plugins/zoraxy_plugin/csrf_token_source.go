@@ -0,0 +1,103 @@
+package zoraxy_plugin
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+/*
+	Csrf_token_source.go
+
+	This file defines where the UI router gets the CSRF token that
+	replaces {{.csrfToken}} in HTML pages. By default the token set
+	by Zoraxy in the X-Zoraxy-Csrf header is used, plugins with their
+	own CSRF scheme (or tests that need a deterministic token) can
+	supply another source with WithCSRFTokenSource
+
+	Example:
+	uiRouter.WithCSRFTokenSource(CSRFTokenSourceFunc(func(w http.ResponseWriter, r *http.Request) (string, error) {
+		return "test-token", nil
+	}))
+*/
+
+const (
+	CSRFTokenHeader          = "X-Zoraxy-Csrf"
+	DefaultCSRFCookieName    = "zoraxy_plugin_csrf"
+	missingCSRFTokenFallback = "missing-csrf-token"
+)
+
+type CSRFTokenSource interface {
+	// Token returns the CSRF token for the request, the response writer can be used to set a cookie
+	Token(w http.ResponseWriter, r *http.Request) (string, error)
+}
+
+// CSRFTokenSourceFunc adapts a function to a CSRFTokenSource
+type CSRFTokenSourceFunc func(w http.ResponseWriter, r *http.Request) (string, error)
+
+func (f CSRFTokenSourceFunc) Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	return f(w, r)
+}
+
+// HeaderCSRFTokenSource reads the token set by Zoraxy in the X-Zoraxy-Csrf header (default)
+type HeaderCSRFTokenSource struct{}
+
+func (HeaderCSRFTokenSource) Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	csrfToken := r.Header.Get(CSRFTokenHeader)
+	if csrfToken == "" {
+		csrfToken = missingCSRFTokenFallback
+	}
+	return csrfToken, nil
+}
+
+// CookieCSRFTokenSource reads the token from a cookie, generating and setting a new one if absent
+// Use this for double submit cookie schemes where the plugin validates the token itself
+type CookieCSRFTokenSource struct {
+	CookieName string //Name of the cookie, default zoraxy_plugin_csrf
+}
+
+func (c CookieCSRFTokenSource) Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	cookieName := c.CookieName
+	if cookieName == "" {
+		cookieName = DefaultCSRFCookieName
+	}
+	if cookie, err := r.Cookie(cookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// WithCSRFTokenSource sets the source of the CSRF token injected into HTML pages
+// Call this before Handler(), pass nil to use the X-Zoraxy-Csrf header
+func (p *PluginUiRouter) WithCSRFTokenSource(source CSRFTokenSource) *PluginUiRouter {
+	p.csrfTokenSource = source
+	return p
+}
+
+// getCSRFToken returns the CSRF token of the request, or responds with a 500 and returns false on failure
+func (p *PluginUiRouter) getCSRFToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	source := p.csrfTokenSource
+	if source == nil {
+		source = HeaderCSRFTokenSource{}
+	}
+	csrfToken, err := source.Token(w, r)
+	if err != nil {
+		fmt.Println("[" + p.PluginID + "] UI router failed to get the CSRF token: " + err.Error())
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return "", false
+	}
+	return csrfToken, true
+}
@@ -0,0 +1,181 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+/*
+	Csrf_validator.go
+
+	This file provides a single CSRF verification path for every state
+	changing endpoint of the plugin, so the UI API, the control channel
+	and any reload style endpoint reachable from a browser are checked
+	the same way. The token sent in the X-CSRF-Token header is compared
+	in constant time against the token of the CSRFTokenSource.
+
+	The tokens Zoraxy sets in X-Zoraxy-Csrf are masked per request and
+	already verified by Zoraxy before the request is proxied, so the
+	validator needs a source the plugin can check itself. It defaults
+	to CookieCSRFTokenSource, use the same source for the UI router so
+	the token injected into the HTML pages is the one being checked
+
+	Zoraxy itself calls the control channel, the terminate and the update
+	endpoints from the loopback interface without a browser session, so
+	these calls carry no CSRF token. Zoraxy signs them instead with the
+	EventSecret of the ConfigureSpec in the X-Zoraxy-Signature header (see
+	SignRequest). RequireZoraxy only accepts such signed calls and is already
+	applied by ServeControl, RegisterTerminateHandler, RegisterConfigReloadHandler
+	and RegisterUpdateHandler. A plugin that has not received an EventSecret
+	(e.g. started manually for development) rejects all of them.
+	A request with a valid Zoraxy signature also passes the CSRF check
+
+	Example:
+	uiRouter.WithCSRFTokenSource(zoraxy_plugin.CookieCSRFTokenSource{})
+	http.Handle("/ui/api/save", uiRouter.RequireCSRF(saveHandler))
+	http.Handle("/ui/api/resync", zoraxy_plugin.RequireZoraxy(resyncHandler))
+*/
+
+const CSRFRequestHeader = "X-CSRF-Token"
+
+var (
+	ErrCSRFTokenMissing = errors.New("csrf token missing")
+	ErrCSRFTokenInvalid = errors.New("csrf token invalid")
+)
+
+// zoraxyEventSecret is the EventSecret of the received ConfigureSpec, used to verify the calls made by Zoraxy
+var zoraxyEventSecret atomic.Pointer[string]
+
+// setZoraxyEventSecret stores the secret Zoraxy signs its calls to the plugin with
+func setZoraxyEventSecret(secret string) {
+	zoraxyEventSecret.Store(&secret)
+}
+
+// receivedEventSecret returns the EventSecret of the received ConfigureSpec, empty if none was received
+func receivedEventSecret() string {
+	if secret := zoraxyEventSecret.Load(); secret != nil {
+		return *secret
+	}
+	return ""
+}
+
+// errRequestBodyTooLarge is returned by verifyZoraxySignature if the body is too large to be verified
+var errRequestBodyTooLarge = errors.New("request body too large")
+
+// verifyZoraxySignature checks the X-Zoraxy-Signature of the request against the received EventSecret,
+// see VerifyRequestSignature. The request body is restored so the next handler can read it as usual
+func verifyZoraxySignature(r *http.Request, maxBodySize int64) (bool, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return false, err
+	}
+	if int64(len(body)) > maxBodySize {
+		return false, errRequestBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return VerifyRequestSignature(r, body, receivedEventSecret()), nil
+}
+
+// RequireZoraxy only lets through requests signed by Zoraxy with the EventSecret of the received ConfigureSpec
+// If the plugin has not received an EventSecret (e.g. it was started manually for development), all requests are rejected
+func RequireZoraxy(next http.Handler) http.Handler {
+	return requireZoraxyWithLimit(maxEventBodySize, next)
+}
+
+// requireZoraxyWithLimit is RequireZoraxy for endpoints accepting bodies larger than maxEventBodySize
+func requireZoraxyWithLimit(maxBodySize int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		valid, err := verifyZoraxySignature(r, maxBodySize)
+		if errors.Is(err, errRequestBodyTooLarge) {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if !valid {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type CSRFValidator struct {
+	Source CSRFTokenSource //Source of the expected token, nil for CookieCSRFTokenSource
+	Header string          //Request header carrying the submitted token, default X-CSRF-Token
+}
+
+// DefaultCSRFValidator is the CSRFValidator used by RequireCSRF
+var DefaultCSRFValidator = &CSRFValidator{}
+
+// RequireCSRF rejects state changing requests without a valid CSRF token using the DefaultCSRFValidator
+func RequireCSRF(next http.Handler) http.Handler {
+	return DefaultCSRFValidator.Middleware(next)
+}
+
+// CSRFValidator returns a validator checking against the CSRF token source of the router
+func (p *PluginUiRouter) CSRFValidator() *CSRFValidator {
+	return &CSRFValidator{Source: p.csrfTokenSource}
+}
+
+// RequireCSRF rejects state changing requests without the CSRF token injected into the UI pages
+// Only works with a token source the plugin can verify, see WithCSRFTokenSource
+func (p *PluginUiRouter) RequireCSRF(next http.Handler) http.Handler {
+	return p.CSRFValidator().Middleware(next)
+}
+
+// Verify checks the CSRF token of the request, safe methods (GET, HEAD, OPTIONS, TRACE) always pass
+// and so do requests signed by Zoraxy, see RequireZoraxy
+// The response writer is passed to the token source, which may set a cookie
+func (v *CSRFValidator) Verify(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+	if r.Header.Get(EventSignatureHeader) != "" {
+		if valid, err := verifyZoraxySignature(r, maxEventBodySize); err == nil && valid {
+			return nil
+		}
+	}
+
+	headerName := v.Header
+	if headerName == "" {
+		headerName = CSRFRequestHeader
+	}
+	givenToken := r.Header.Get(headerName)
+	if givenToken == "" {
+		return ErrCSRFTokenMissing
+	}
+
+	source := v.Source
+	if source == nil {
+		source = CookieCSRFTokenSource{}
+	}
+	expectedToken, err := source.Token(w, r)
+	if err != nil {
+		return fmt.Errorf("failed to get the expected csrf token: %w", err)
+	}
+	if expectedToken == "" || expectedToken == missingCSRFTokenFallback || !SecureCompare(givenToken, expectedToken) {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+// Middleware responds with a 403 to requests failing Verify
+func (v *CSRFValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(w, r); err != nil {
+			if !errors.Is(err, ErrCSRFTokenMissing) && !errors.Is(err, ErrCSRFTokenInvalid) {
+				fmt.Println("[csrf] " + err.Error())
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
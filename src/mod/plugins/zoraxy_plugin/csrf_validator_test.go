@@ -0,0 +1,155 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSRFValidator(t *testing.T) {
+	handler := RequireCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method string
+		cookie string
+		header string
+		status int
+	}{
+		{http.MethodGet, "", "", http.StatusNoContent},
+		{http.MethodPost, "token", "token", http.StatusNoContent},
+		{http.MethodPost, "token", "", http.StatusForbidden},
+		{http.MethodPost, "token", "other", http.StatusForbidden},
+		{http.MethodDelete, "", "token", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/api", nil)
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: DefaultCSRFCookieName, Value: test.cookie})
+		}
+		if test.header != "" {
+			req.Header.Set(CSRFRequestHeader, test.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s cookie %q header %q: expected %d, got %d", test.method, test.cookie, test.header, test.status, rec.Code)
+		}
+	}
+
+	//The router validator checks against the token source of the router
+	router := newTestUiRouter().WithCSRFTokenSource(CSRFTokenSourceFunc(func(w http.ResponseWriter, r *http.Request) (string, error) {
+		return "router-token", nil
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/ui/api", nil)
+	req.Header.Set(CSRFRequestHeader, "router-token")
+	if err := router.CSRFValidator().Verify(httptest.NewRecorder(), req); err != nil {
+		t.Errorf("Expected the router token to pass, got %v", err)
+	}
+
+	//The masked header tokens of Zoraxy cannot be verified by the plugin
+	validator := &CSRFValidator{Source: HeaderCSRFTokenSource{}}
+	req = httptest.NewRequest(http.MethodPost, "/ui/api", nil)
+	req.Header.Set(CSRFRequestHeader, missingCSRFTokenFallback)
+	if err := validator.Verify(httptest.NewRecorder(), req); !errors.Is(err, ErrCSRFTokenInvalid) {
+		t.Errorf("Expected the missing token fallback to be rejected, got %v", err)
+	}
+}
+
+// newSignedTestRequest creates a request signed by Zoraxy with secret, see SignRequest
+func newSignedTestRequest(method string, target string, body string, secret string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	SignRequest(req, []byte(body), secret)
+	return req
+}
+
+func TestRequireZoraxy(t *testing.T) {
+	handler := RequireZoraxy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"method":"ping"}` && len(body) != 0 {
+			t.Errorf("Expected the body to be restored, got %q", body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(func() { setZoraxyEventSecret("") })
+	serve := func(req *http.Request) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	//Without a received EventSecret every call is rejected
+	setZoraxyEventSecret("")
+	if code := serve(httptest.NewRequest(http.MethodPost, ControlPath, strings.NewReader("{}"))); code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned calls to be rejected without a secret, got %d", code)
+	}
+	if code := serve(newSignedTestRequest(http.MethodPost, ControlPath, "{}", "")); code != http.StatusUnauthorized {
+		t.Errorf("Expected calls signed with an empty secret to be rejected, got %d", code)
+	}
+
+	setZoraxyEventSecret("secret")
+	ping := `{"method":"ping"}`
+	if code := serve(newSignedTestRequest(http.MethodPost, ControlPath, ping, "secret")); code != http.StatusNoContent {
+		t.Errorf("Expected the signed call to pass, got %d", code)
+	}
+	if code := serve(newSignedTestRequest(http.MethodGet, "/ui/term", "", "secret")); code != http.StatusNoContent {
+		t.Errorf("Expected the signed empty body call to pass, got %d", code)
+	}
+	if code := serve(httptest.NewRequest(http.MethodPost, ControlPath, strings.NewReader(ping))); code != http.StatusUnauthorized {
+		t.Errorf("Expected the unsigned call to be rejected, got %d", code)
+	}
+	if code := serve(newSignedTestRequest(http.MethodPost, ControlPath, ping, "other")); code != http.StatusUnauthorized {
+		t.Errorf("Expected the call signed with another secret to be rejected, got %d", code)
+	}
+
+	//The signature only covers the call it was made for
+	signed := newSignedTestRequest(http.MethodGet, "/ui/term", "", "secret")
+	replays := map[string]*http.Request{
+		"other body":   httptest.NewRequest(http.MethodGet, "/ui/term", strings.NewReader("{}")),
+		"other method": httptest.NewRequest(http.MethodPost, "/ui/term", nil),
+		"other path":   httptest.NewRequest(http.MethodGet, "/ui/update", nil),
+		"other query":  httptest.NewRequest(http.MethodGet, "/ui/term?force=1", nil),
+	}
+	for name, req := range replays {
+		req.Header = signed.Header.Clone()
+		if code := serve(req); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected the replayed signature to be rejected, got %d", name, code)
+		}
+	}
+
+	//Stale or future timestamps are rejected
+	for _, offset := range []time.Duration{-RequestSignatureMaxAge - time.Minute, RequestSignatureMaxAge + time.Minute} {
+		req := httptest.NewRequest(http.MethodGet, "/ui/term", nil)
+		timestamp := strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+		req.Header.Set(RequestTimestampHeader, timestamp)
+		req.Header.Set(EventSignatureHeader, requestSignature(http.MethodGet, "/ui/term", timestamp, nil, "secret"))
+		if code := serve(req); code != http.StatusUnauthorized {
+			t.Errorf("timestamp offset %v: expected 401, got %d", offset, code)
+		}
+	}
+	req := newSignedTestRequest(http.MethodGet, "/ui/term", "", "secret")
+	req.Header.Del(RequestTimestampHeader)
+	if code := serve(req); code != http.StatusUnauthorized {
+		t.Errorf("Expected a call without timestamp to be rejected, got %d", code)
+	}
+
+	//A call signed by Zoraxy passes the CSRF check without a token
+	if err := DefaultCSRFValidator.Verify(httptest.NewRecorder(), newSignedTestRequest(http.MethodPost, "/api", "{}", "secret")); err != nil {
+		t.Errorf("Expected the signed call to pass the CSRF check, got %v", err)
+	}
+	if err := DefaultCSRFValidator.Verify(httptest.NewRecorder(), newSignedTestRequest(http.MethodPost, "/api", "{}", "other")); !errors.Is(err, ErrCSRFTokenMissing) {
+		t.Errorf("Expected the invalid signature to fall back to the CSRF check, got %v", err)
+	}
+	//An empty body POST signed for GET /term does not pass the CSRF check
+	req = httptest.NewRequest(http.MethodPost, "/api/delete", nil)
+	req.Header = newSignedTestRequest(http.MethodGet, "/ui/term", "", "secret").Header
+	if err := DefaultCSRFValidator.Verify(httptest.NewRecorder(), req); !errors.Is(err, ErrCSRFTokenMissing) {
+		t.Errorf("Expected the replayed signature to fall back to the CSRF check, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+	Dir_server.go
+
+	This file provides a hardened file server for serving a folder on disk,
+	e.g. for a static web server utility plugin. Unlike the embed.FS based
+	PluginUiRouter, the files are streamed from disk by http.FileServer, so
+	range requests, If-Modified-Since and large files are handled efficiently
+
+	Requests are resolved inside the root directory only. Symlinks pointing
+	outside of the root, dotfiles (unless ShowDotFiles) and directory listings
+	(unless EnableDirectoryListing) answer 404 Not Found
+
+	Example:
+	handler, err := zoraxy_plugin.NewDirServer("./www", zoraxy_plugin.DirServerOptions{})
+	if err != nil {
+		panic(err)
+	}
+	http.Handle("/files/", http.StripPrefix("/files", handler))
+*/
+
+type DirServerOptions struct {
+	EnableDirectoryListing bool //List the contents of directories without an index.html
+	ShowDotFiles           bool //Serve and list files and directories starting with a dot (e.g. .env, .git)
+}
+
+type dirServerFs struct {
+	root    string //Absolute root directory with symlinks resolved
+	options DirServerOptions
+}
+
+// NewDirServer creates a file handler serving the root directory on disk
+func NewDirServer(root string, opts DirServerOptions) (http.Handler, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(resolvedRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("dir server root is not a directory: " + root)
+	}
+
+	fileServer := http.FileServer(&dirServerFs{root: resolvedRoot, options: opts})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		fileServer.ServeHTTP(w, r)
+	}), nil
+}
+
+// Open implements http.FileSystem, all rejected paths answer fs.ErrNotExist so their existence is not leaked
+func (d *dirServerFs) Open(name string) (http.File, error) {
+	if !isSafeFsRequestPath(name) {
+		return nil, fs.ErrNotExist
+	}
+	name = path.Clean("/" + name)
+	if !d.options.ShowDotFiles && hasDotSegment(name) {
+		return nil, fs.ErrNotExist
+	}
+
+	//Resolve symlinks and reject targets outside of the root
+	resolved, err := filepath.EvalSymlinks(filepath.Join(d.root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+	if resolved != d.root && !strings.HasPrefix(resolved, d.root+string(filepath.Separator)) {
+		return nil, fs.ErrNotExist
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fs.ErrNotExist
+	}
+	if info.IsDir() && !d.options.EnableDirectoryListing {
+		//Only directories with an index.html can be served
+		index, err := d.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, fs.ErrNotExist
+		}
+		index.Close()
+	}
+	return &dirServerFile{File: f, showDotFiles: d.options.ShowDotFiles}, nil
+}
+
+// dirServerFile hides dotfiles from directory listings
+type dirServerFile struct {
+	http.File
+	showDotFiles bool
+}
+
+func (f *dirServerFile) Readdir(count int) ([]fs.FileInfo, error) {
+	entries, err := f.File.Readdir(count)
+	if f.showDotFiles {
+		return entries, err
+	}
+	filtered := entries[:0]
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, err
+}
+
+// hasDotSegment checks if any segment of the slash separated path starts with a dot
+func hasDotSegment(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestDirServer(t *testing.T, opts DirServerOptions) http.Handler {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(root, "large.bin"), []byte("0123456789"), 0644)
+	os.WriteFile(filepath.Join(root, ".env"), []byte("SECRET=1"), 0644)
+	os.Mkdir(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "docs", "readme.txt"), []byte("docs"), 0644)
+	os.Mkdir(filepath.Join(root, "site"), 0755)
+	os.WriteFile(filepath.Join(root, "site", "index.html"), []byte("<h1>site</h1>"), 0644)
+	os.WriteFile(filepath.Join(outside, "passwd"), []byte("root:x"), 0644)
+	if err := os.Symlink(filepath.Join(outside, "passwd"), filepath.Join(root, "escape")); err != nil {
+		t.Skip("symlinks not supported: " + err.Error())
+	}
+	os.Symlink(filepath.Join(root, "large.bin"), filepath.Join(root, "inside"))
+
+	handler, err := NewDirServer(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func TestDirServer(t *testing.T) {
+	handler := newTestDirServer(t, DirServerOptions{})
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/large.bin", http.StatusOK, "0123456789"},
+		{"/inside", http.StatusOK, "0123456789"},
+		{"/escape", http.StatusNotFound, ""},
+		{"/.env", http.StatusNotFound, ""},
+		{"/docs/", http.StatusNotFound, ""},
+		{"/docs/readme.txt", http.StatusOK, "docs"},
+		{"/site/", http.StatusOK, "<h1>site</h1>"},
+		{"/../../etc/passwd", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.URL.Path = test.path
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status || (test.body != "" && rec.Body.String() != test.body) {
+			t.Errorf("%s: expected %d %q, got %d %q", test.path, test.status, test.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	//Range requests
+	req := httptest.NewRequest("GET", "/large.bin", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "2345" {
+		t.Errorf("Expected 206 with bytes 2-5, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/large.bin", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestDirServerListing(t *testing.T) {
+	handler := newTestDirServer(t, DirServerOptions{EnableDirectoryListing: true})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "large.bin") {
+		t.Fatalf("Expected a directory listing, got %d %q", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), ".env") {
+		t.Error("Expected dotfiles to be hidden from the listing")
+	}
+
+	handler = newTestDirServer(t, DirServerOptions{EnableDirectoryListing: true, ShowDotFiles: true})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), ".env") {
+		t.Error("Expected dotfiles in the listing with ShowDotFiles")
+	}
+}
+
+func TestNewDirServerMissingRoot(t *testing.T) {
+	if _, err := NewDirServer(filepath.Join(t.TempDir(), "missing"), DirServerOptions{}); err == nil {
+		t.Error("Expected an error for a missing root")
+	}
+}
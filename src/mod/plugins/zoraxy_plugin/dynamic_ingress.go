@@ -0,0 +1,93 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+/*
+	Dynamic_ingress.go
+
+	This file defines the dynamic capture ingress pairs of a plugin.
+	For dynamic capture Zoraxy first sends the request headers to the
+	capture (sniff) ingress, which answers with ControlStatusCode_CAPTURED
+	if the plugin wants the request, then forwards the request to the
+	handle ingress of the same pair.
+
+	A plugin can declare several named pairs for distinct concerns
+	(e.g. auth and transform) in DynamicIngresses. The single pair form
+	(DynamicCaptureIngress / DynamicHandleIngress) is still supported and
+	is reported by DynamicIngressList as the pair named "default"
+
+	Example:
+	spec.DynamicIngresses = []DynamicIngress{
+		{Name: "auth", CaptureIngress: "/d_auth_sniff", HandleIngress: "/d_auth"},
+	}
+	RegisterDynamicIngress(spec, "auth", authSniffHandler, authHandler, nil)
+*/
+
+const DefaultDynamicIngressName = "default"
+
+type DynamicIngress struct {
+	Name           string `json:"name"`            //Name of the ingress pair, unique within the plugin
+	CaptureIngress string `json:"capture_ingress"` //Ingress deciding if the request is captured (e.g. /d_sniff)
+	HandleIngress  string `json:"handle_ingress"`  //Ingress handling the captured request (e.g. /d_handler)
+}
+
+// DynamicIngressList returns all dynamic ingress pairs declared by the plugin,
+// including the single pair form as the pair named "default"
+func (i *IntroSpect) DynamicIngressList() []DynamicIngress {
+	pairs := []DynamicIngress{}
+	if i.DynamicCaptureIngress != "" || i.DynamicHandleIngress != "" {
+		pairs = append(pairs, DynamicIngress{
+			Name:           DefaultDynamicIngressName,
+			CaptureIngress: i.DynamicCaptureIngress,
+			HandleIngress:  i.DynamicHandleIngress,
+		})
+	}
+	return append(pairs, i.DynamicIngresses...)
+}
+
+// GetDynamicIngress returns the dynamic ingress pair with the given name
+func (i *IntroSpect) GetDynamicIngress(name string) (DynamicIngress, bool) {
+	for _, pair := range i.DynamicIngressList() {
+		if pair.Name == name {
+			return pair, true
+		}
+	}
+	return DynamicIngress{}, false
+}
+
+// validateDynamicIngresses checks that every pair is named uniquely and has both ingress paths
+func (i *IntroSpect) validateDynamicIngresses() error {
+	names := map[string]bool{}
+	for _, pair := range i.DynamicIngressList() {
+		if pair.Name == "" {
+			return errors.New("plugin dynamic ingress name is empty")
+		}
+		if names[pair.Name] {
+			return errors.New("duplicated plugin dynamic ingress name: " + pair.Name)
+		}
+		names[pair.Name] = true
+		if !strings.HasPrefix(pair.CaptureIngress, "/") || !strings.HasPrefix(pair.HandleIngress, "/") {
+			return errors.New("plugin dynamic ingress " + pair.Name + " must declare both capture and handle ingress starting with /")
+		}
+	}
+	return nil
+}
+
+// RegisterDynamicIngress registers the sniff and handle handlers on the paths of the named dynamic ingress pair
+// if mux is nil, the handlers will be registered to http.DefaultServeMux
+func RegisterDynamicIngress(spec *IntroSpect, name string, sniffHandler http.Handler, handleHandler http.Handler, mux *http.ServeMux) error {
+	pair, ok := spec.GetDynamicIngress(name)
+	if !ok {
+		return errors.New("dynamic ingress " + name + " is not declared in the intro spect")
+	}
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(pair.CaptureIngress, sniffHandler)
+	mux.Handle(pair.HandleIngress, handleHandler)
+	return nil
+}
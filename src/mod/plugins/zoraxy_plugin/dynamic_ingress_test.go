@@ -0,0 +1,63 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDynamicIngresses(t *testing.T) {
+	spec := &IntroSpect{
+		ID:                    "org.example.test",
+		Name:                  "Test",
+		Author:                "foobar",
+		Description:           "Test plugin",
+		UIPath:                "/ui",
+		DynamicCaptureIngress: "/d_sniff",
+		DynamicHandleIngress:  "/d_handler",
+		DynamicIngresses: []DynamicIngress{
+			{Name: "auth", CaptureIngress: "/d_auth_sniff", HandleIngress: "/d_auth"},
+		},
+	}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pairs := spec.DynamicIngressList()
+	if len(pairs) != 2 || pairs[0].Name != DefaultDynamicIngressName || pairs[0].HandleIngress != "/d_handler" {
+		t.Errorf("Expected the single pair form as the default pair, got %+v", pairs)
+	}
+	if modes := spec.CaptureModes(); len(modes) != 1 || modes[0] != CaptureMode_Dynamic {
+		t.Errorf("Expected the dynamic capture mode, got %v", modes)
+	}
+
+	mux := http.NewServeMux()
+	for _, name := range []string{DefaultDynamicIngressName, "auth"} {
+		name := name
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+		if err := RegisterDynamicIngress(spec, name, handler, handler, mux); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	for target, expected := range map[string]string{"/d_sniff": "default", "/d_handler": "default", "/d_auth_sniff": "auth", "/d_auth": "auth"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Body.String() != expected {
+			t.Errorf("%s: expected the %s handler, got %q", target, expected, rec.Body.String())
+		}
+	}
+	if err := RegisterDynamicIngress(spec, "transform", nil, nil, mux); err == nil {
+		t.Error("Expected an error for an undeclared ingress")
+	}
+
+	//Duplicated names and incomplete pairs are rejected
+	spec.DynamicIngresses = append(spec.DynamicIngresses, DynamicIngress{Name: "auth", CaptureIngress: "/a", HandleIngress: "/b"})
+	if err := spec.Validate(); err == nil {
+		t.Error("Expected an error for a duplicated ingress name")
+	}
+	spec.DynamicIngresses = []DynamicIngress{{Name: "transform", CaptureIngress: "/t_sniff"}}
+	if err := spec.Validate(); err == nil {
+		t.Error("Expected an error for a missing handle ingress")
+	}
+}
@@ -0,0 +1,89 @@
+package zoraxy_plugin
+
+import (
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/*
+	Embed_audit.go
+
+	This file checks the embedded UI filesystem for files that were most
+	likely included by an overly broad go:embed pattern, such as Go
+	sources, source maps, private keys, dotfiles and large binaries.
+	They leak information about the plugin or bloat the binary, run the
+	audit in a test so they are caught before the plugin is released
+
+	Example:
+	func TestEmbedFS(t *testing.T) {
+		for _, finding := range zoraxy_plugin.AuditEmbedFS(content) {
+			t.Error(finding)
+		}
+	}
+*/
+
+// EmbedAuditMaxFileSize is the size above which AuditEmbedFS flags an embedded file
+const EmbedAuditMaxFileSize = 5 << 20
+
+// embedAuditSuffixes are the suffixes of files that are rarely meant to be served
+var embedAuditSuffixes = map[string]string{
+	".go":    "Go source file",
+	".map":   "source map",
+	".ts":    "TypeScript source file",
+	".tsx":   "TypeScript source file",
+	".pem":   "certificate or private key",
+	".key":   "private key",
+	".p12":   "certificate bundle",
+	".env":   "environment file",
+	".sql":   "database dump",
+	".db":    "database file",
+	".log":   "log file",
+	".bak":   "backup file",
+	".swp":   "editor swap file",
+	".exe":   "executable",
+	".mod":   "Go module file",
+	".sum":   "Go checksum file",
+	".orig":  "merge leftover",
+	".zip":   "archive",
+	".tar":   "archive",
+	".local": "local configuration",
+}
+
+// AuditEmbedFS walks the filesystem and returns a finding for every likely unintended file
+// Every finding is in "path: reason" format, an empty result means nothing was flagged
+func AuditEmbedFS(fsys fs.FS) []string {
+	findings := []string{}
+	fs.WalkDir(fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			findings = append(findings, filePath+": unreadable: "+err.Error())
+			return nil
+		}
+		if filePath == "." {
+			return nil
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") && name != ".well-known" {
+			if d.IsDir() {
+				findings = append(findings, filePath+": hidden directory")
+				return fs.SkipDir
+			}
+			findings = append(findings, filePath+": dotfile")
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if reason, ok := embedAuditSuffixes[strings.ToLower(path.Ext(name))]; ok && !strings.HasSuffix(name, ".d.ts") {
+			findings = append(findings, filePath+": "+reason)
+		} else if strings.HasSuffix(name, "~") {
+			findings = append(findings, filePath+": editor backup file")
+		}
+		if info, err := d.Info(); err == nil && info.Size() > EmbedAuditMaxFileSize {
+			findings = append(findings, filePath+": large file ("+strconv.FormatInt(info.Size()>>20, 10)+" MiB)")
+		}
+		return nil
+	})
+	return findings
+}
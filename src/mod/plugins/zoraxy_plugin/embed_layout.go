@@ -0,0 +1,61 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+/*
+	Embed_layout.go
+
+	This file checks if the embed.FS layout matches the TargetFsPrefix
+	given to the PluginUiRouter, so a wrong prefix can be reported at
+	startup instead of showing up as a 404 when the UI is opened.
+
+	Build your plugin with -tags zoraxy_plugin_debug to make
+	NewPluginEmbedUIRouter panic if the layout check fails
+*/
+
+// CheckEmbedLayout verifies that the targetFsPrefix exists in the targetFs
+// and that an index.html can be found under it
+func CheckEmbedLayout(targetFs fs.FS, targetFsPrefix string) error {
+	if targetFs == nil {
+		return errors.New("target fs is nil")
+	}
+	fsRoot := strings.Trim(targetFsPrefix, "/")
+	if fsRoot == "" {
+		fsRoot = "."
+	}
+
+	info, err := fs.Stat(targetFs, fsRoot)
+	if err != nil {
+		return errors.New("target fs prefix " + targetFsPrefix + " not found in embed.FS, top level entries are: " + listFsRootEntries(targetFs) +
+			". Check your //go:embed directive and make sure the prefix matches the embedded folder (e.g. //go:embed web/* with prefix /web)")
+	}
+	if !info.IsDir() {
+		return errors.New("target fs prefix " + targetFsPrefix + " is a file, it should be the folder that contains your UI files")
+	}
+
+	if _, err := fs.Stat(targetFs, path.Join(fsRoot, "index.html")); err != nil {
+		return errors.New("index.html not found under target fs prefix " + targetFsPrefix + ", the plugin UI will return 404 when opened from Zoraxy")
+	}
+	return nil
+}
+
+func listFsRootEntries(targetFs fs.FS) string {
+	entries, err := fs.ReadDir(targetFs, ".")
+	if err != nil || len(entries) == 0 {
+		return "(empty)"
+	}
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,6 @@
+//go:build zoraxy_plugin_debug
+
+package zoraxy_plugin
+
+// Debug build, verify the embed.FS layout when the UI router is created
+const debugBuild = true
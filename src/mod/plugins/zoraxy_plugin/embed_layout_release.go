@@ -0,0 +1,5 @@
+//go:build !zoraxy_plugin_debug
+
+package zoraxy_plugin
+
+const debugBuild = false
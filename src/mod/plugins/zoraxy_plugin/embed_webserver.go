@@ -1,23 +1,63 @@
 package zoraxy_plugin
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
+	"mime"
 	"net/http"
 	"net/url"
-	"os"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type PluginUiRouter struct {
-	PluginID       string    //The ID of the plugin
-	TargetFs       *embed.FS //The embed.FS where the UI files are stored
-	TargetFsPrefix string    //The prefix of the embed.FS where the UI files are stored, e.g. /web
-	HandlerPrefix  string    //The prefix of the handler used to route this router, e.g. /ui
+	PluginID       string            //The ID of the plugin
+	TargetFs       *embed.FS         //The embed.FS where the UI files are stored
+	TargetFsPrefix string            //The prefix of the embed.FS where the UI files are stored, e.g. /web
+	HandlerPrefix  string            //The prefix of the handler used to route this router, e.g. /ui
+	MimeOverrides  map[string]string //Content types keyed by file extension (e.g. .wasm), takes priority over the built-in defaults
+	NotFoundFile   string            //The page relative to TargetFsPrefix (e.g. 404.html) served for missing files, empty for plain text
+	ErrorFile      string            //The page relative to TargetFsPrefix (e.g. 50x.html) served for internal errors, empty for plain text
+	SPAFallback    bool              //Serve the root index.html for missing paths without a file extension, for client side routing
+	FrameAncestors []string          //Origins allowed to frame the UI (CSP frame-ancestors), default 'self' for the Zoraxy panel
+	HSTS           string            //Strict-Transport-Security header sent when the UI is served over HTTPS, empty to disable
 
-	terminateHandler func() //The handler to be called when the plugin is terminated
+	subFs               fs.FS                             //The sub filesystem of TargetFs rooted at TargetFsPrefix
+	subFsErr            error                             //The error returned when creating subFs, served as a 500 if set
+	cspPolicy           string                            //The Content-Security-Policy of HTML pages, empty to disable
+	locales             map[string]map[string]string      //The translation bundles keyed by locale, nil to disable i18n
+	localeFallback      string                            //The locale used if no bundle matches the request
+	basicAuthRealm      string                            //The realm of the basic auth challenge
+	basicAuthVerify     func(user, pass string) bool      //The basic auth credential check, nil to disable basic auth
+	csrfTokenSource     CSRFTokenSource                   //The source of the CSRF token injected into HTML pages, nil for the X-Zoraxy-Csrf header
+	accessLogWriter     io.Writer                         //The writer to write access logs to, nil to disable access log
+	accessLogFormat     AccessLogFormat                   //The format of the access log
+	metrics             *MetricsRegistry                  //The metrics registry to count UI requests, nil to disable
+	etagCache           sync.Map                          //Cache of static asset ETags, keyed by request path
+	appFiles            map[string]uiAppFile              //The favicon and web manifest keyed by path, see WithFavicon
+	rootHandlers        map[string]http.Handler           //The handlers of the root keyed by media type, see WithRootHandlers
+	terminateHandler    func()                            //The handler to be called when the plugin is terminated
+	configReloadHandler func(newSpec ConfigureSpec) error //The handler to be called when Zoraxy pushes an updated ConfigureSpec
+	updateHandler       func(newBinaryPath string) error  //The handler to be called when Zoraxy stages a new plugin binary
+}
+
+// defaultMimeOverrides are content types the system MIME database often gets wrong or misses
+var defaultMimeOverrides = map[string]string{
+	".wasm":        "application/wasm",
+	".mjs":         "text/javascript; charset=utf-8",
+	".webmanifest": "application/manifest+json",
 }
 
 // NewPluginEmbedUIRouter creates a new PluginUiRouter with embed.FS
@@ -39,43 +79,122 @@ func NewPluginEmbedUIRouter(pluginID string, targetFs *embed.FS, targetFsPrefix
 	}
 	handlerPrefix = strings.TrimSuffix(handlerPrefix, "/")
 
-	//Return the PluginUiRouter
-	return &PluginUiRouter{
+	//Fail fast on a wrong embed layout in debug builds
+	if debugBuild {
+		if err := CheckEmbedLayout(targetFs, targetFsPrefix); err != nil {
+			panic("zoraxy_plugin: " + err.Error())
+		}
+	}
+
+	router := &PluginUiRouter{
 		PluginID:       pluginID,
 		TargetFs:       targetFs,
 		TargetFsPrefix: targetFsPrefix,
 		HandlerPrefix:  handlerPrefix,
 	}
+
+	//The prefix is fixed, so create the sub filesystem once and report a failure only once
+	fsRoot := strings.TrimPrefix(targetFsPrefix, "/")
+	if fsRoot == "" {
+		fsRoot = "."
+	}
+	router.subFs, router.subFsErr = fs.Sub(*targetFs, fsRoot)
+	if router.subFsErr != nil {
+		fmt.Println("[" + pluginID + "] UI router failed to open target fs prefix " + targetFsPrefix + ": " + router.subFsErr.Error())
+	}
+
+	//Return the PluginUiRouter
+	return router
 }
 
-func (p *PluginUiRouter) populateCSRFToken(r *http.Request, fsHandler http.Handler) http.Handler {
-	//Get the CSRF token from header
-	csrfToken := r.Header.Get("X-Zoraxy-Csrf")
-	if csrfToken == "" {
-		csrfToken = "missing-csrf-token"
+// Err returns the error encountered while creating the router, if any
+// A router with an error responds to every request with a 500
+func (p *PluginUiRouter) Err() error {
+	return p.subFsErr
+}
+
+// AssertMatchesIntroSpect checks if the HandlerPrefix of the router matches the UIPath of the intro spect
+// Zoraxy proxies the plugin UI to UIPath, a router mounted elsewhere breaks relative links and assets
+func (p *PluginUiRouter) AssertMatchesIntroSpect(spec *IntroSpect) error {
+	uiPath := strings.TrimSuffix("/"+strings.TrimPrefix(spec.UIPath, "/"), "/")
+	if uiPath != p.HandlerPrefix {
+		return fmt.Errorf("UI router handler prefix %q does not match the intro spect UI path %q, Zoraxy proxies the plugin UI to %q", p.HandlerPrefix, spec.UIPath, uiPath+"/")
 	}
+	return nil
+}
 
+func (p *PluginUiRouter) populateCSRFToken(r *http.Request, fsHandler http.Handler) http.Handler {
 	//Return the middleware
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Check if the request is for an HTML file
 		if strings.HasSuffix(r.URL.Path, "/") {
 			// Redirect to the index.html
-			http.Redirect(w, r, r.URL.Path+"index.html", http.StatusFound)
+			// Use a relative location so the redirect stays under the handler prefix
+			// no matter where this router (or Zoraxy) mounted it
+			writeRedirect(w, "index.html", http.StatusFound)
 			return
 		}
+		if p.SPAFallback && path.Ext(r.URL.Path) == "" && !p.fsPathExists(r.URL.Path) {
+			//Client side route of a single page app, serve the app shell instead of a 404
+			//Paths with an extension are assets (e.g. /static/app.js), they still 404 if missing
+			spaURL := *r.URL
+			spaURL.Path = "/index.html"
+			spaURL.RawPath = ""
+			r.URL = &spaURL
+		}
+
+		//Set the content type before the file server sniffs it
+		if contentType, ok := p.lookupMimeOverride(r.URL.Path); ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+
 		if strings.HasSuffix(r.URL.Path, ".html") {
+			//Reject traversal attempts before touching the FS, do not rely on the FS implementation
+			if !isSafeFsRequestPath(r.URL.Path) {
+				http.Error(w, "invalid URL path", http.StatusBadRequest)
+				return
+			}
+			csrfToken, ok := p.getCSRFToken(w, r)
+			if !ok {
+				return
+			}
+
 			//Read the target file from embed.FS
 			targetFilePath := strings.TrimPrefix(r.URL.Path, "/")
 			targetFilePath = p.TargetFsPrefix + "/" + targetFilePath
 			targetFilePath = strings.TrimPrefix(targetFilePath, "/")
 			targetFileContent, err := fs.ReadFile(*p.TargetFs, targetFilePath)
 			if err != nil {
-				http.Error(w, "File not found", http.StatusNotFound)
+				p.serveErrorPage(w, r, http.StatusNotFound, csrfToken)
 				return
 			}
-			body := string(targetFileContent)
-			body = strings.ReplaceAll(body, "{{.csrfToken}}", csrfToken)
-			http.ServeContent(w, r, r.URL.Path, time.Now(), strings.NewReader(body))
+			body, err := p.templateHTML(w, r, string(targetFileContent), csrfToken)
+			if err != nil {
+				p.serveErrorPage(w, r, http.StatusInternalServerError, csrfToken)
+				return
+			}
+			//The templated body differs per CSRF token, so the ETag is computed after substitution.
+			//Ranges are also resolved against the substituted body, and a stale If-Range
+			//(e.g. a resumed download with another token) falls back to the full body
+			w.Header().Set("ETag", contentETag([]byte(body)))
+			http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader(body))
+			return
+		}
+
+		//Serve a precompressed sibling (e.g. app.js.br) if the client accepts its encoding
+		if p.servePrecompressed(w, r) {
+			return
+		}
+
+		//Embedded files have no modtime, use a content hash ETag for conditional requests
+		etag, ok := p.staticAssetETag(r.URL.Path)
+		if ok {
+			w.Header().Set("ETag", etag)
+		} else if p.NotFoundFile != "" && !p.fsPathExists(r.URL.Path) {
+			//Serve the custom 404 page instead of the plain text response of the file server
+			if csrfToken, ok := p.getCSRFToken(w, r); ok {
+				p.serveErrorPage(w, r, http.StatusNotFound, csrfToken)
+			}
 			return
 		}
 
@@ -85,44 +204,332 @@ func (p *PluginUiRouter) populateCSRFToken(r *http.Request, fsHandler http.Handl
 
 }
 
+// writeRedirect responds with a redirect without body
+// The explicit Content-Length keeps HEAD responses identical to GET, net/http only adds it to GET responses
+func writeRedirect(w http.ResponseWriter, location string, statusCode int) {
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(statusCode)
+}
+
 // GetHttpHandler returns the http.Handler for the PluginUiRouter
 func (p *PluginUiRouter) Handler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	uiHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Work on a shallow copy so other routers / middlewares still see the original request
+		outreq := new(http.Request)
+		*outreq = *r
+		r = outreq
+
 		//Remove the plugin UI handler path prefix
-		rewrittenURL := r.RequestURI
-		rewrittenURL = strings.TrimPrefix(rewrittenURL, p.HandlerPrefix)
-		rewrittenURL = strings.ReplaceAll(rewrittenURL, "//", "/")
+		rewrittenURL, ok := stripHandlerPrefix(r.RequestURI, p.HandlerPrefix)
+		if !ok {
+			//The router is mounted on a path that does not match its handler prefix
+			fmt.Println("[" + p.PluginID + "] UI router with handler prefix " + p.HandlerPrefix + " received request " + r.RequestURI + " outside of its prefix, check the http.Handle path of this router")
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		if rewrittenURL == "" || strings.HasPrefix(rewrittenURL, "?") || strings.HasPrefix(rewrittenURL, "#") {
+			//Canonicalize /ui to /ui/ so relative asset links resolve under the prefix
+			//Use a relative location so the redirect survives the Zoraxy plugin UI path rewrite
+			writeRedirect(w, path.Base(p.HandlerPrefix)+"/"+rewrittenURL, http.StatusMovedPermanently)
+			return
+		}
+		rewrittenURL = collapseURISlashes(rewrittenURL)
 		r.URL, _ = url.Parse(rewrittenURL)
 		r.RequestURI = rewrittenURL
 
+		//Serve the favicon and web manifest set with WithFavicon / WithWebManifest
+		if p.serveAppFile(w, r) {
+			return
+		}
+
+		//Serve API clients at the root with the handlers set with WithRootHandlers
+		if p.serveNegotiatedRoot(w, r) {
+			return
+		}
+
 		//Serve the file from the embed.FS
-		subFS, err := fs.Sub(*p.TargetFs, strings.TrimPrefix(p.TargetFsPrefix, "/"))
-		if err != nil {
-			fmt.Println(err.Error())
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if p.subFsErr != nil {
+			//Already logged when the router was created
+			http.Error(w, "Plugin UI misconfigured: invalid target fs prefix "+p.TargetFsPrefix, http.StatusInternalServerError)
 			return
 		}
 
 		// Replace {{csrf_token}} with the actual CSRF token and serve the file
-		p.populateCSRFToken(r, http.FileServer(http.FS(subFS))).ServeHTTP(w, r)
+		p.populateCSRFToken(r, http.FileServer(http.FS(p.subFs))).ServeHTTP(w, r)
 	})
+
+	var handler http.Handler = uiHandler
+	if p.basicAuthVerify != nil {
+		handler = p.basicAuthMiddleware(handler)
+	}
+	handler = p.uiRecoverMiddleware(p.frameOptionsMiddleware(handler))
+	if p.metrics != nil {
+		handler = p.metrics.UIMiddleware(handler)
+	}
+	return p.accessLogMiddleware(handler)
+}
+
+// DefaultCSP allows inline scripts only with the per response nonce, see WithCSP
+const DefaultCSP = "default-src 'self'; script-src 'self' 'nonce-{{.cspNonce}}'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; object-src 'none'; base-uri 'self'"
+
+// WithCSP sets the Content-Security-Policy header of the HTML pages served by this router
+// A random nonce is generated for every response and replaces {{.cspNonce}} in both the policy
+// and the HTML page, e.g. <script nonce="{{.cspNonce}}">. Call this before Handler()
+func (p *PluginUiRouter) WithCSP(policy string) *PluginUiRouter {
+	p.cspPolicy = policy
+	return p
+}
+
+// generateCSPNonce generates a base64 encoded random nonce for the Content-Security-Policy
+func generateCSPNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// WithMetrics counts the requests served by this router in the given metrics registry
+// Call this before Handler()
+func (p *PluginUiRouter) WithMetrics(metrics *MetricsRegistry) *PluginUiRouter {
+	p.metrics = metrics
+	return p
+}
+
+// lookupMimeOverride returns the overridden content type for the extension of the request path
+func (p *PluginUiRouter) lookupMimeOverride(requestPath string) (string, bool) {
+	ext := strings.ToLower(path.Ext(requestPath))
+	if ext == "" {
+		return "", false
+	}
+	if contentType, ok := p.MimeOverrides[ext]; ok {
+		return contentType, true
+	}
+	contentType, ok := defaultMimeOverrides[ext]
+	return contentType, ok
+}
+
+// precompressedEncodings are the sibling file extensions checked by servePrecompressed, in order of preference
+var precompressedEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// servePrecompressed serves the precompressed sibling of the requested file if one exists
+// and the client accepts its encoding, returns false if the request is not handled
+func (p *PluginUiRouter) servePrecompressed(w http.ResponseWriter, r *http.Request) bool {
+	if p.subFs == nil || !isSafeFsRequestPath(r.URL.Path) {
+		return false
+	}
+	filePath := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if filePath == "" || filePath == "." {
+		return false
+	}
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	varySet := false
+	for _, candidate := range precompressedEncodings {
+		content, err := fs.ReadFile(p.subFs, filePath+candidate.ext)
+		if err != nil {
+			continue
+		}
+		if !varySet {
+			//The response depends on Accept-Encoding once a sibling exists
+			w.Header().Add("Vary", "Accept-Encoding")
+			varySet = true
+		}
+		if !acceptsEncoding(acceptEncoding, candidate.encoding) {
+			continue
+		}
+		if w.Header().Get("Content-Type") == "" {
+			contentType := mime.TypeByExtension(path.Ext(filePath))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("Content-Encoding", candidate.encoding)
+		w.Header().Set("ETag", contentETag(content))
+		http.ServeContent(&fullContentLengthWriter{ResponseWriter: w, length: len(content)}, r, filePath, time.Time{}, bytes.NewReader(content))
+		return true
+	}
+	return false
+}
+
+// fullContentLengthWriter sets the Content-Length of full (200) responses
+// http.ServeContent omits it once Content-Encoding is set, so HEAD responses would lack the length GET responses get
+type fullContentLengthWriter struct {
+	http.ResponseWriter
+	length int
+}
+
+func (w *fullContentLengthWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusOK && w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// acceptsEncoding checks if the Accept-Encoding header accepts the encoding with a non zero quality
+func acceptsEncoding(acceptEncoding string, encoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		params = strings.ReplaceAll(params, " ", "")
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			if quality, err := strconv.ParseFloat(q, 64); err == nil && quality == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// staticAssetETag returns the ETag of the embedded file at the request path
+// The content of embed.FS is immutable per build, so the result is cached
+func (p *PluginUiRouter) staticAssetETag(requestPath string) (string, bool) {
+	if cached, ok := p.etagCache.Load(requestPath); ok {
+		return cached.(string), true
+	}
+	if !isSafeFsRequestPath(requestPath) {
+		return "", false
+	}
+	targetFilePath := strings.TrimPrefix(p.TargetFsPrefix+"/"+strings.TrimPrefix(requestPath, "/"), "/")
+	content, err := fs.ReadFile(*p.TargetFs, targetFilePath)
+	if err != nil {
+		//Not found or a directory, let the file server handle it
+		return "", false
+	}
+	etag := contentETag(content)
+	p.etagCache.Store(requestPath, etag)
+	return etag, true
+}
+
+// contentETag returns a strong ETag derived from the content hash
+func contentETag(content []byte) string {
+	hash := sha256.Sum256(content)
+	return "\"" + hex.EncodeToString(hash[:16]) + "\""
+}
+
+// isSafeFsRequestPath checks if the request path can be safely joined with the target fs prefix
+// Paths with parent directory segments, backslashes or NUL bytes are rejected
+func isSafeFsRequestPath(requestPath string) bool {
+	if strings.ContainsAny(requestPath, "\\\x00") {
+		return false
+	}
+	for _, segment := range strings.Split(requestPath, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// stripHandlerPrefix removes the handler prefix from the request URI on a path segment boundary
+// so a router mounted at /ui does not strip requests for a sibling router mounted at /ui2
+// ok is false if the request URI is not under the handler prefix
+func stripHandlerPrefix(requestURI string, handlerPrefix string) (string, bool) {
+	if handlerPrefix == "" {
+		return requestURI, true
+	}
+	if !strings.HasPrefix(requestURI, handlerPrefix) {
+		return requestURI, false
+	}
+	rest := requestURI[len(handlerPrefix):]
+	if rest != "" && !strings.HasPrefix(rest, "/") && !strings.HasPrefix(rest, "?") && !strings.HasPrefix(rest, "#") {
+		return requestURI, false
+	}
+	return rest, true
+}
+
+// collapseURISlashes collapses repeated slashes in the path portion of a request URI
+// The query string and fragment (if any) are reattached untouched, so values like
+// ?next=https://example.com are not mangled
+func collapseURISlashes(requestURI string) string {
+	uriPath := requestURI
+	suffix := ""
+	if idx := strings.IndexAny(requestURI, "?#"); idx != -1 {
+		uriPath = requestURI[:idx]
+		suffix = requestURI[idx:]
+	}
+	for strings.Contains(uriPath, "//") {
+		uriPath = strings.ReplaceAll(uriPath, "//", "/")
+	}
+	return uriPath + suffix
 }
 
 // RegisterTerminateHandler registers the terminate handler for the PluginUiRouter
-// The terminate handler will be called when the plugin is terminated from Zoraxy plugin manager
+// The terminate handler will be called when the plugin is terminated from Zoraxy plugin manager,
+// requests not signed by Zoraxy are rejected, see RequireZoraxy
 // if mux is nil, the handler will be registered to http.DefaultServeMux
 func (p *PluginUiRouter) RegisterTerminateHandler(termFunc func(), mux *http.ServeMux) {
 	p.terminateHandler = termFunc
 	if mux == nil {
 		mux = http.DefaultServeMux
 	}
-	mux.HandleFunc(p.HandlerPrefix+"/term", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle(p.HandlerPrefix+"/term", RequireZoraxy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		p.terminateHandler()
 		w.WriteHeader(http.StatusOK)
 		go func() {
 			//Make sure the response is sent before the plugin is terminated
+			//The workers include the graceful shutdown of the plugin web server,
+			//which waits for this request to complete
 			time.Sleep(100 * time.Millisecond)
-			os.Exit(0)
+			ctx, cancel := context.WithTimeout(context.Background(), WorkerStopTimeout)
+			if err := StopWorkers(ctx); err != nil {
+				fmt.Println("Failed to stop workers: " + err.Error())
+			}
+			cancel()
+			ExitFunc(0)
 		}()
-	})
+	})))
+}
+
+// ConfigReloadMaxBodySize is the max size of a reloaded configure spec, read by RegisterConfigReloadHandler.
+// Large specs are piped to STDIN on start without a size limit, so the reload accepts much more than an event body
+var ConfigReloadMaxBodySize int64 = 64 << 20
+
+// RegisterConfigReloadHandler registers the config reload handler for the PluginUiRouter
+// Zoraxy will POST the updated ConfigureSpec to the reload endpoint when the plugin settings changed,
+// the reload is reported as successful to Zoraxy if the handler returns nil.
+// Requests not signed by Zoraxy and specs of an unsupported protocol version are rejected, see RequireZoraxy
+// if mux is nil, the handler will be registered to http.DefaultServeMux
+func (p *PluginUiRouter) RegisterConfigReloadHandler(reloadFunc func(newSpec ConfigureSpec) error, mux *http.ServeMux) {
+	p.configReloadHandler = reloadFunc
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(p.HandlerPrefix+"/reload", requireZoraxyWithLimit(ConfigReloadMaxBodySize, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		//The body size is limited by requireZoraxyWithLimit
+		var newSpec ConfigureSpec
+		if err := json.NewDecoder(r.Body).Decode(&newSpec); err != nil {
+			http.Error(w, "Invalid configure spec: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := CheckProtocolVersion(newSpec.ProtocolVersion); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := p.configReloadHandler(newSpec); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if newSpec.EventSecret != "" {
+			//Keep verifying the calls of Zoraxy if the secret was rotated
+			setZoraxyEventSecret(newSpec.EventSecret)
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
 }
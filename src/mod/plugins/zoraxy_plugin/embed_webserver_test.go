@@ -0,0 +1,900 @@
+package zoraxy_plugin
+
+import (
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+//go:embed testdata/*
+var testWebFs embed.FS
+
+func newTestUiRouter() *PluginUiRouter {
+	return NewPluginEmbedUIRouter("org.example.test", &testWebFs, "/testdata/web", "/ui")
+}
+
+func TestCollapseURISlashes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/page.html", "/page.html"},
+		{"//page.html", "/page.html"},
+		{"/static///app.js", "/static/app.js"},
+		{"/page.html?next=https://example.com", "/page.html?next=https://example.com"},
+		{"//page.html?next=https://example.com//a", "/page.html?next=https://example.com//a"},
+		{"/a%2F%2Fb.html", "/a%2F%2Fb.html"},
+		{"/a%2F%2F/b.html?x=%2F%2F", "/a%2F%2F/b.html?x=%2F%2F"},
+		{"//page.html#section//2", "/page.html#section//2"},
+		{"//page.html?a=//b#frag//c", "/page.html?a=//b#frag//c"},
+	}
+
+	for _, test := range tests {
+		result := collapseURISlashes(test.input)
+		if result != test.expected {
+			t.Errorf("collapseURISlashes(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestHandlerPreservesQueryString(t *testing.T) {
+	router := newTestUiRouter()
+	var seenQuery string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.Handler().ServeHTTP(w, r)
+		seenQuery = r.URL.Query().Get("next")
+	})
+
+	req := httptest.NewRequest("GET", "/ui//page.html?next=https://example.com/x", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if seenQuery != "https://example.com/x" {
+		t.Errorf("Expected query value to be preserved, got %q", seenQuery)
+	}
+	if !strings.Contains(rec.Body.String(), "Sub Page") {
+		t.Errorf("Expected page.html to be served, got %q", rec.Body.String())
+	}
+}
+
+func TestCheckEmbedLayout(t *testing.T) {
+	if err := CheckEmbedLayout(testWebFs, "/testdata/web"); err != nil {
+		t.Errorf("Expected valid layout, got %v", err)
+	}
+	if err := CheckEmbedLayout(testWebFs, "/web"); err == nil {
+		t.Error("Expected error for missing prefix")
+	}
+	if err := CheckEmbedLayout(testWebFs, "/testdata/web/static"); err == nil {
+		t.Error("Expected error for prefix without index.html")
+	}
+	if err := CheckEmbedLayout(testWebFs, "/testdata/web/index.html"); err == nil {
+		t.Error("Expected error for prefix pointing to a file")
+	}
+}
+
+func TestMultipleUiRouters(t *testing.T) {
+	adminRouter := NewPluginEmbedUIRouter("org.example.test", &testWebFs, "/testdata/web", "/ui")
+	widgetRouter := NewPluginEmbedUIRouter("org.example.test", &testWebFs, "/testdata/widget", "/ui/widget")
+	siblingRouter := NewPluginEmbedUIRouter("org.example.test", &testWebFs, "/testdata/widget", "/ui2")
+
+	mux := http.NewServeMux()
+	mux.Handle("/ui/", adminRouter.Handler())
+	mux.Handle("/ui/widget/", widgetRouter.Handler())
+	mux.Handle("/ui2/", siblingRouter.Handler())
+
+	tests := []struct {
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"/ui/index.html", http.StatusOK, "Index Page"},
+		{"/ui/static/app.js", http.StatusOK, "app loaded"},
+		{"/ui/static/widget.js", http.StatusNotFound, ""},
+		{"/ui/widget/index.html", http.StatusOK, "Widget Page"},
+		{"/ui/widget/static/widget.js", http.StatusOK, "widget loaded"},
+		{"/ui/widget/static/app.js", http.StatusNotFound, ""},
+		{"/ui2/index.html", http.StatusOK, "Widget Page"},
+		{"/ui2/static/widget.js", http.StatusOK, "widget loaded"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != test.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.expectedCode, rec.Code)
+			continue
+		}
+		if !strings.Contains(rec.Body.String(), test.expectedBody) {
+			t.Errorf("%s: expected body to contain %q, got %q", test.path, test.expectedBody, rec.Body.String())
+		}
+	}
+
+	//Index redirects must stay under the router that handled the request
+	for _, path := range []string{"/ui/", "/ui/widget/", "/ui2/"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != "index.html" {
+			t.Errorf("%s: expected relative redirect to index.html, got %d %q", path, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
+
+func TestStripHandlerPrefix(t *testing.T) {
+	tests := []struct {
+		uri      string
+		prefix   string
+		expected string
+		ok       bool
+	}{
+		{"/ui/page.html", "/ui", "/page.html", true},
+		{"/ui", "/ui", "", true},
+		{"/ui?x=1", "/ui", "?x=1", true},
+		{"/ui2/page.html", "/ui", "/ui2/page.html", false},
+		{"/other/page.html", "/ui", "/other/page.html", false},
+		{"/page.html", "", "/page.html", true},
+	}
+	for _, test := range tests {
+		result, ok := stripHandlerPrefix(test.uri, test.prefix)
+		if result != test.expected || ok != test.ok {
+			t.Errorf("stripHandlerPrefix(%q, %q) = %q, %v; expected %q, %v", test.uri, test.prefix, result, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestHTMLBranchRejectsPathTraversal(t *testing.T) {
+	router := newTestUiRouter()
+	handler := router.Handler()
+
+	for _, uri := range []string{
+		"/ui/../../secret.html",
+		"/ui/../web/index.html",
+		"/ui/static/../../../secret.html",
+		"/ui/%2e%2e/%2e%2e/secret.html",
+		"/ui/..%5c..%5csecret.html",
+		"/ui/static%5c..%5cindex.html",
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RequestURI = uri
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", uri, rec.Code)
+		}
+	}
+
+	//Normal nested paths should still be served
+	req := httptest.NewRequest("GET", "/ui/page.html", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for page.html, got %d", rec.Code)
+	}
+}
+
+func TestHandlerRejectsRequestWithoutPrefix(t *testing.T) {
+	router := newTestUiRouter()
+
+	//Mounted at the wrong path, the request does not contain the /ui prefix
+	mux := http.NewServeMux()
+	mux.Handle("/", router.Handler())
+
+	for _, path := range []string{"/page.html", "/static/app.js", "/uiextra/page.html"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "Sub Page") {
+			t.Errorf("%s: expected the file not to be served", path)
+		}
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	router := newTestUiRouter()
+	handler := router.Handler()
+
+	for _, path := range []string{"/ui/page.html", "/ui/static/app.js"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || etag == "" {
+			t.Fatalf("%s: expected status 200 with an ETag, got %d %q", path, rec.Code, etag)
+		}
+
+		//The ETag must be stable across requests
+		req = httptest.NewRequest("GET", path, nil)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Header().Get("ETag") != etag {
+			t.Errorf("%s: expected a stable ETag, got %q and %q", path, etag, rec.Header().Get("ETag"))
+		}
+
+		req = httptest.NewRequest("GET", path, nil)
+		req.Header.Set("If-None-Match", etag)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("%s: expected status 304, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestUiRouterBadPrefix(t *testing.T) {
+	router := NewPluginEmbedUIRouter("org.example.test", &testWebFs, "/../outside", "/ui")
+	if router.Err() == nil {
+		t.Fatal("Expected an error for an invalid target fs prefix")
+	}
+
+	handler := router.Handler()
+	req := httptest.NewRequest("GET", "/ui/index.html", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "target fs prefix") {
+		t.Errorf("Expected a descriptive error, got %q", rec.Body.String())
+	}
+
+	if err := newTestUiRouter().Err(); err != nil {
+		t.Errorf("Expected no error for a valid prefix, got %v", err)
+	}
+}
+
+func TestMimeOverrides(t *testing.T) {
+	router := newTestUiRouter()
+	router.MimeOverrides = map[string]string{".js": "application/x-test"}
+	handler := router.Handler()
+
+	tests := map[string]string{
+		"/ui/static/module.mjs": "text/javascript; charset=utf-8",
+		"/ui/static/app.js":     "application/x-test",
+	}
+	for path, expected := range tests {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != expected {
+			t.Errorf("%s: expected content type %q, got %q", path, expected, got)
+		}
+	}
+}
+
+func TestRangeRequestOnTemplatedHTML(t *testing.T) {
+	handler := newTestUiRouter().Handler()
+
+	req := httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Header.Set("X-Zoraxy-Csrf", "a-much-longer-csrf-token-than-the-placeholder")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	fullBody := rec.Body.String()
+	etag := rec.Header().Get("ETag")
+	if !strings.Contains(fullBody, "a-much-longer-csrf-token-than-the-placeholder") {
+		t.Fatalf("Expected the CSRF token to be injected, got %q", fullBody)
+	}
+
+	//The range must be resolved against the substituted body
+	req = httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Header.Set("X-Zoraxy-Csrf", "a-much-longer-csrf-token-than-the-placeholder")
+	req.Header.Set("Range", "bytes=40-120")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("Expected status 206, got %d", rec.Code)
+	}
+	if rec.Body.String() != fullBody[40:121] {
+		t.Errorf("Expected range %q, got %q", fullBody[40:121], rec.Body.String())
+	}
+
+	//A range resumed with another token must return the full body
+	req = httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Header.Set("X-Zoraxy-Csrf", "another-token")
+	req.Header.Set("Range", "bytes=40-120")
+	req.Header.Set("If-Range", etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "another-token") {
+		t.Errorf("Expected the full body for a stale If-Range, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandlerPrefixEntryPoints(t *testing.T) {
+	handler := newTestUiRouter().Handler()
+
+	tests := []struct {
+		uri      string
+		status   int
+		location string
+	}{
+		{"/ui", http.StatusMovedPermanently, "ui/"},
+		{"/ui?tab=1", http.StatusMovedPermanently, "ui/?tab=1"},
+		{"/ui/", http.StatusFound, "index.html"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.uri, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.uri, test.status, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != test.location {
+			t.Errorf("%s: expected location %q, got %q", test.uri, test.location, got)
+		}
+	}
+}
+
+func TestCSPNonce(t *testing.T) {
+	handler := newTestUiRouter().WithCSP(DefaultCSP).Handler()
+
+	nonces := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/ui/nonce.html", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		csp := rec.Header().Get("Content-Security-Policy")
+		start := strings.Index(csp, "'nonce-")
+		if rec.Code != http.StatusOK || start == -1 {
+			t.Fatalf("Expected a CSP header with nonce, got %d %q", rec.Code, csp)
+		}
+		nonce := csp[start+len("'nonce-"):]
+		nonce = nonce[:strings.Index(nonce, "'")]
+		if !strings.Contains(rec.Body.String(), `nonce="`+nonce+`"`) {
+			t.Errorf("Expected the nonce %q in the page, got %q", nonce, rec.Body.String())
+		}
+		nonces[nonce] = true
+	}
+	if len(nonces) != 2 {
+		t.Error("Expected a new nonce for every response")
+	}
+
+	//Routers without CSP only restrict framing
+	req := httptest.NewRequest("GET", "/ui/page.html", nil)
+	rec := httptest.NewRecorder()
+	newTestUiRouter().Handler().ServeHTTP(rec, req)
+	if csp := rec.Header().Get("Content-Security-Policy"); csp != "frame-ancestors 'self'" {
+		t.Errorf("Expected only the frame-ancestors directive without WithCSP, got %q", csp)
+	}
+}
+
+func TestPrecompressedSibling(t *testing.T) {
+	handler := newTestUiRouter().Handler()
+
+	req := httptest.NewRequest("GET", "/ui/static/app.js", nil)
+	req.Header.Set("Accept-Encoding", "br;q=0, gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected the gzip sibling, got %d %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") {
+		t.Errorf("Expected the content type of app.js, got %q", rec.Header().Get("Content-Type"))
+	}
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	if !strings.Contains(string(content), "app loaded") {
+		t.Errorf("Unexpected decompressed content %q", content)
+	}
+
+	//Clients that do not accept gzip get the original file
+	req = httptest.NewRequest("GET", "/ui/static/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" || !strings.Contains(rec.Body.String(), "app loaded") {
+		t.Errorf("Expected the uncompressed file, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+	}
+}
+
+func TestLocales(t *testing.T) {
+	handler := newTestUiRouter().WithLocales(map[string]map[string]string{
+		"en":    {"title": "Settings", "greeting": "Hello & welcome"},
+		"zh-TW": {"title": "設定"},
+		"de":    {"title": "Einstellungen", "greeting": "Hallo"},
+	}, "en").Handler()
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		cookie         string
+		expectedLocale string
+		expectedText   string
+	}{
+		{"fallback", "/ui/i18n.html", "", "", "en", "<title>Settings</title>"},
+		{"escaped", "/ui/i18n.html", "en-US", "", "en", "Hello &amp; welcome"},
+		{"exact match", "/ui/i18n.html", "zh-TW,en;q=0.5", "", "zh-TW", "<title>設定</title>"},
+		{"missing key uses fallback", "/ui/i18n.html", "zh-TW", "", "zh-TW", "Hello &amp; welcome"},
+		{"quality order", "/ui/i18n.html", "fr, en;q=0.3, de;q=0.8", "", "de", "<title>Einstellungen</title>"},
+		{"cookie override", "/ui/i18n.html", "en", "de", "de", "Hallo"},
+		{"query override", "/ui/i18n.html?lang=zh-tw", "en", "de", "zh-TW", "<title>設定</title>"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: LocaleCookieName, Value: tt.cookie})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tt.name, rec.Code)
+		}
+		if !strings.Contains(body, `lang="`+tt.expectedLocale+`"`) || !strings.Contains(body, tt.expectedText) {
+			t.Errorf("%s: expected locale %q with %q, got %q", tt.name, tt.expectedLocale, tt.expectedText, body)
+		}
+		if !strings.Contains(rec.Header().Get("Vary"), "Accept-Language") {
+			t.Errorf("%s: expected Vary: Accept-Language", tt.name)
+		}
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	handler := newTestUiRouter().WithBasicAuth("Test Plugin", BasicAuthCredentials("admin", "secret")).Handler()
+
+	req := httptest.NewRequest("GET", "/ui/page.html", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), `Basic realm="Test Plugin"`) {
+		t.Fatalf("Expected a basic auth challenge, got %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	req = httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.SetBasicAuth("admin", "wrong")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong password, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with valid credentials, got %d", rec.Code)
+	}
+
+	//The index redirects still apply once authenticated
+	for target, location := range map[string]string{"/ui": "ui/", "/ui/": "index.html"} {
+		req = httptest.NewRequest("GET", target, nil)
+		req.SetBasicAuth("admin", "secret")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Header().Get("Location") != location {
+			t.Errorf("%s: expected redirect to %q, got %d %q", target, location, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
+
+func TestNotFoundFile(t *testing.T) {
+	router := newTestUiRouter()
+	router.NotFoundFile = "404.html"
+	handler := router.Handler()
+
+	for _, target := range []string{"/ui/missing.html", "/ui/static/missing.js"} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("X-Zoraxy-Csrf", "test-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		body := rec.Body.String()
+		if rec.Code != http.StatusNotFound || !strings.Contains(body, "Custom Not Found Page") {
+			t.Errorf("%s: expected the custom 404 page, got %d %q", target, rec.Code, body)
+		}
+		if !strings.Contains(body, `content="test-token"`) {
+			t.Errorf("%s: expected the CSRF token in the 404 page", target)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: expected a HTML content type, got %q", target, ct)
+		}
+	}
+
+	//Existing files are not affected
+	req := httptest.NewRequest("GET", "/ui/static/app.js", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for an existing file, got %d", rec.Code)
+	}
+
+	//A missing custom page falls back to plain text
+	router = newTestUiRouter()
+	router.NotFoundFile = "no-such-page.html"
+	req = httptest.NewRequest("GET", "/ui/missing.html", nil)
+	rec = httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Body.String(), "File not found") {
+		t.Errorf("Expected the plain text 404, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCSRFTokenSource(t *testing.T) {
+	//Default header source
+	req := httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Header.Set(CSRFTokenHeader, "header-token")
+	rec := httptest.NewRecorder()
+	newTestUiRouter().Handler().ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "header-token") {
+		t.Errorf("Expected the header token in the page, got %q", rec.Body.String())
+	}
+
+	//Deterministic source for tests
+	handler := newTestUiRouter().WithCSRFTokenSource(CSRFTokenSourceFunc(func(w http.ResponseWriter, r *http.Request) (string, error) {
+		return "fixed-token", nil
+	})).Handler()
+	req = httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Header.Set(CSRFTokenHeader, "header-token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "fixed-token") || strings.Contains(rec.Body.String(), "header-token") {
+		t.Errorf("Expected only the fixed token in the page, got %q", rec.Body.String())
+	}
+
+	//Cookie source generates a token once and reuses it
+	handler = newTestUiRouter().WithCSRFTokenSource(CookieCSRFTokenSource{}).Handler()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/page.html", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultCSRFCookieName || !strings.Contains(rec.Body.String(), cookies[0].Value) {
+		t.Fatalf("Expected a generated CSRF cookie matching the page, got %v", cookies)
+	}
+	req = httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if len(rec.Result().Cookies()) != 0 || !strings.Contains(rec.Body.String(), cookies[0].Value) {
+		t.Error("Expected the existing cookie token to be reused")
+	}
+
+	//Static assets do not request a token
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/static/app.js", nil))
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("Expected no CSRF cookie on static assets")
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	router := newTestUiRouter()
+	router.SPAFallback = true
+	handler := router.Handler()
+
+	req := httptest.NewRequest("GET", "/ui/settings/network?tab=dns", nil)
+	req.Header.Set("X-Zoraxy-Csrf", "test-token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	index, _ := testWebFs.ReadFile("testdata/web/index.html")
+	expected := strings.ReplaceAll(string(index), "{{.csrfToken}}", "test-token")
+	if rec.Code != http.StatusOK || rec.Body.String() != expected {
+		t.Errorf("Expected the app shell for a client side route, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	//Missing assets still 404
+	for _, target := range []string{"/ui/static/missing.js", "/ui/missing.html"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", target, rec.Code)
+		}
+	}
+
+	//Existing files are served as is
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/static/app.js", nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "<html") {
+		t.Errorf("Expected the asset itself, got %d", rec.Code)
+	}
+
+	//Without the option, client side routes 404
+	rec = httptest.NewRecorder()
+	newTestUiRouter().Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/ui/settings/network", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without SPAFallback, got %d", rec.Code)
+	}
+}
+
+func TestAssertMatchesIntroSpect(t *testing.T) {
+	router := newTestUiRouter()
+	for uiPath, matches := range map[string]bool{"/ui": true, "/ui/": true, "ui": true, "/ui2": false, "/": false, "": false} {
+		err := router.AssertMatchesIntroSpect(&IntroSpect{UIPath: uiPath})
+		if (err == nil) != matches {
+			t.Errorf("UI path %q: expected match %v, got %v", uiPath, matches, err)
+		}
+	}
+}
+
+func TestUiRecoverMiddleware(t *testing.T) {
+	var panicking atomic.Bool
+	panicking.Store(true)
+	handler := newTestUiRouter().WithCSRFTokenSource(CSRFTokenSourceFunc(func(w http.ResponseWriter, r *http.Request) (string, error) {
+		if panicking.Load() {
+			panic("template parse error")
+		}
+		return "token", nil
+	})).Handler()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ui/page.html")
+	if err != nil {
+		t.Fatalf("Expected a response instead of a dropped connection, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected 500 after a panic, got %d", resp.StatusCode)
+	}
+
+	//The router keeps serving after the panic
+	panicking.Store(false)
+	resp, err = http.Get(server.URL + "/ui/page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 after recovering, got %d", resp.StatusCode)
+	}
+}
+
+func TestAppFiles(t *testing.T) {
+	icon := fstest.MapFS{"favicon.ico": {Data: []byte("\x00\x00\x01\x00icon")}}
+	router := newTestUiRouter().WithFavicon(icon, "favicon.ico").WithWebManifest(WebManifest{Name: "Test Plugin"})
+	handler := router.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/favicon.ico", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/x-icon" || rec.Body.String() != "\x00\x00\x01\x00icon" {
+		t.Errorf("Expected the favicon, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	req := httptest.NewRequest("GET", "/ui/favicon.ico", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/manifest.webmanifest", nil))
+	manifest := WebManifest{}
+	if rec.Header().Get("Content-Type") != "application/manifest+json" || json.Unmarshal(rec.Body.Bytes(), &manifest) != nil {
+		t.Fatalf("Expected the web manifest, got %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if manifest.Name != "Test Plugin" || manifest.StartURL != "./" || manifest.Display != "standalone" {
+		t.Errorf("Expected the manifest defaults, got %+v", manifest)
+	}
+
+	//Served at the root of the plugin web server for direct browsing
+	mux := http.NewServeMux()
+	router.RegisterAppFileHandlers(mux)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/favicon.ico", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the root favicon, got %d", rec.Code)
+	}
+}
+
+func TestFrameAncestors(t *testing.T) {
+	tests := []struct {
+		ancestors    []string
+		csp          string
+		frameOptions string
+	}{
+		{nil, "frame-ancestors 'self'", "SAMEORIGIN"},
+		{[]string{"none"}, "frame-ancestors 'none'", "DENY"},
+		{[]string{"self", "https://zoraxy.example.com"}, "frame-ancestors 'self' https://zoraxy.example.com", ""},
+	}
+	for _, test := range tests {
+		router := newTestUiRouter()
+		router.FrameAncestors = test.ancestors
+		rec := httptest.NewRecorder()
+		router.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/ui/static/app.js", nil))
+		if rec.Header().Get("Content-Security-Policy") != test.csp || rec.Header().Get("X-Frame-Options") != test.frameOptions {
+			t.Errorf("%v: expected %q / %q, got %q / %q", test.ancestors, test.csp, test.frameOptions, rec.Header().Get("Content-Security-Policy"), rec.Header().Get("X-Frame-Options"))
+		}
+	}
+
+	//Merged into the WithCSP policy of HTML pages
+	rec := httptest.NewRecorder()
+	newTestUiRouter().WithCSP("default-src 'self';").Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/ui/page.html", nil))
+	if csp := rec.Header().Get("Content-Security-Policy"); csp != "default-src 'self'; frame-ancestors 'self'" || len(rec.Header().Values("Content-Security-Policy")) != 1 {
+		t.Errorf("Expected the merged policy, got %q", rec.Header().Values("Content-Security-Policy"))
+	}
+
+	//HSTS is only sent over HTTPS
+	router := newTestUiRouter()
+	router.HSTS = "max-age=31536000"
+	handler := router.Handler()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/page.html", nil))
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("Expected no HSTS over HTTP")
+	}
+	req := httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Strict-Transport-Security") != "max-age=31536000" {
+		t.Error("Expected HSTS over HTTPS")
+	}
+}
+
+func TestRootHandlers(t *testing.T) {
+	handler := newTestUiRouter().WithRootHandlers(map[string]http.Handler{
+		"application/json": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		}),
+	}).Handler()
+
+	tests := []struct {
+		path   string
+		accept string
+		json   bool
+	}{
+		{"/ui/", "", false},
+		{"/ui/", "*/*", false},
+		{"/ui/", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"/ui/", "application/json", true},
+		{"/ui/", "application/*", true},
+		{"/ui/", "text/html;q=0.5, application/json", true},
+		{"/ui/", "application/json;q=0, */*", false},
+		{"/ui/", "image/png", false},
+		{"/ui/index.html", "application/json", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		isJSON := strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json")
+		if isJSON != test.json {
+			t.Errorf("%s with Accept %q: expected JSON %v, got %d %q", test.path, test.accept, test.json, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if test.path == "/ui/" && !strings.Contains(rec.Header().Get("Vary"), "Accept") {
+			t.Errorf("Expected Vary: Accept at the root, got %q", rec.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestHeadMatchesGet(t *testing.T) {
+	router := newTestUiRouter()
+	router.NotFoundFile = "404.html"
+	server := httptest.NewServer(router.Handler())
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, target := range []string{"/ui", "/ui/", "/ui/index.html", "/ui/static/app.js", "/ui/static/", "/ui/missing.html", "/ui/missing.js"} {
+		responses := map[string]*http.Response{}
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			req, _ := http.NewRequest(method, server.URL+target, nil)
+			req.Header.Set(CSRFTokenHeader, "test-token")
+			//The transport only asks for gzip on GET, set it for both to compare the precompressed branch
+			req.Header.Set("Accept-Encoding", "gzip")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if method == http.MethodHead && len(body) != 0 {
+				t.Errorf("HEAD %s: expected no body, got %q", target, body)
+			}
+			responses[method] = resp
+		}
+
+		get, head := responses[http.MethodGet], responses[http.MethodHead]
+		if get.StatusCode != head.StatusCode {
+			t.Errorf("%s: GET status %d differs from HEAD status %d", target, get.StatusCode, head.StatusCode)
+		}
+		for _, header := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Location", "ETag", "Cache-Control", "X-Frame-Options"} {
+			if get.Header.Get(header) != head.Header.Get(header) {
+				t.Errorf("%s: GET %s %q differs from HEAD %q", target, header, get.Header.Get(header), head.Header.Get(header))
+			}
+		}
+	}
+}
+
+func TestConfigReloadHandler(t *testing.T) {
+	originalMaxBodySize := ConfigReloadMaxBodySize
+	defer func() { ConfigReloadMaxBodySize = originalMaxBodySize }()
+	ConfigReloadMaxBodySize = 2 * maxEventBodySize
+
+	var received ConfigureSpec
+	reloadErr := error(nil)
+	mux := http.NewServeMux()
+	newTestUiRouter().RegisterConfigReloadHandler(func(newSpec ConfigureSpec) error {
+		received = newSpec
+		return reloadErr
+	}, mux)
+	t.Cleanup(func() { setZoraxyEventSecret("") })
+
+	reload := func(method string, body string, secret string) int {
+		req := httptest.NewRequest(method, "/ui/reload", strings.NewReader(body))
+		if secret != "" {
+			SignRequest(req, []byte(body), secret)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	//Without a received EventSecret (development mode) every reload is rejected
+	setZoraxyEventSecret("")
+	if code := reload(http.MethodPost, `{"port":8080}`, ""); code != http.StatusUnauthorized || received.Port != 0 {
+		t.Errorf("Expected the reload to be rejected, got %d %+v", code, received)
+	}
+
+	setZoraxyEventSecret("secret")
+	if code := reload(http.MethodPost, `{"port":8080}`, "secret"); code != http.StatusOK || received.Port != 8080 {
+		t.Errorf("Expected the reload to pass, got %d %+v", code, received)
+	}
+	if code := reload(http.MethodGet, "", "secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", code)
+	}
+	if code := reload(http.MethodPost, `{"port":`, "secret"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid spec, got %d", code)
+	}
+	if code := reload(http.MethodPost, `{"port":8080,"protocol_version":999}`, "secret"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported protocol version, got %d", code)
+	}
+	//Specs larger than an event body can be reloaded, up to ConfigReloadMaxBodySize
+	large := `{"port":8082,"options":{"a":"` + strings.Repeat("a", maxEventBodySize) + `"}}`
+	if code := reload(http.MethodPost, large, "secret"); code != http.StatusOK || received.Port != 8082 {
+		t.Errorf("Expected the large spec to be reloaded, got %d", code)
+	}
+	oversized := `{"options":{"a":"` + strings.Repeat("a", int(ConfigReloadMaxBodySize)) + `"}}`
+	if code := reload(http.MethodPost, oversized, "secret"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized spec, got %d", code)
+	}
+	reloadErr = io.ErrUnexpectedEOF
+	if code := reload(http.MethodPost, `{"port":8080}`, "secret"); code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 when the reload handler fails, got %d", code)
+	}
+	reloadErr = nil
+
+	//Only reloads signed with the received EventSecret are accepted
+	received = ConfigureSpec{}
+	if code := reload(http.MethodPost, `{"port":8081}`, ""); code != http.StatusUnauthorized || received.Port != 0 {
+		t.Errorf("Expected the unsigned reload to be rejected, got %d", code)
+	}
+	if code := reload(http.MethodPost, `{"port":8081}`, "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected the reload signed with another secret to be rejected, got %d", code)
+	}
+	if code := reload(http.MethodPost, `{"port":8081,"event_secret":"rotated"}`, "secret"); code != http.StatusOK || received.Port != 8081 {
+		t.Errorf("Expected the signed reload to pass, got %d", code)
+	}
+	if receivedEventSecret() != "rotated" {
+		t.Errorf("Expected the rotated secret to be used for the next calls, got %q", receivedEventSecret())
+	}
+}
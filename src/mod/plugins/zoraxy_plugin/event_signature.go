@@ -0,0 +1,124 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Event_signature.go
+
+	Any local process can POST to the SubscriptionPath of a plugin, so
+	Zoraxy signs every subscription event body with the EventSecret of the
+	ConfigureSpec and sends the signature in the X-Zoraxy-Signature header
+	as "sha256=" followed by the hex encoded HMAC-SHA256 of the body.
+
+	Wrap your subscription handler with RequireEventSignature to reject
+	unsigned or tampered events with 401
+
+	The calls Zoraxy makes to the plugin endpoints (control channel,
+	terminate, reload and update) are signed with SignRequest instead.
+	The signature covers the method, the request URI, the X-Zoraxy-Timestamp
+	header and the body, so a captured signature is only valid for the
+	same call within RequestSignatureMaxAge and cannot be reused for
+	another endpoint or another (e.g. empty) body
+
+	Example:
+	http.Handle("/notifyme", zoraxy_plugin.RequireEventSignature(cfg.EventSecret, myEventHandler))
+*/
+
+const (
+	EventSignatureHeader = "X-Zoraxy-Signature"
+	eventSignaturePrefix = "sha256="
+)
+
+const (
+	RequestTimestampHeader = "X-Zoraxy-Timestamp" //Unix time in seconds the request was signed at by SignRequest
+	RequestSignatureMaxAge = 5 * time.Minute      //Max clock difference accepted by VerifyRequestSignature
+)
+
+// maxEventBodySize is the max size of a subscription event body read for signature verification
+const maxEventBodySize = 1 << 20
+
+// SignEvent returns the signature of the event body in the X-Zoraxy-Signature header format
+func SignEvent(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return eventSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyEventSignature checks if sig is a valid signature of the event body signed with secret
+// An empty secret never verifies, so a plugin started without an EventSecret rejects all events
+func VerifyEventSignature(body []byte, sig string, secret string) bool {
+	if secret == "" || !strings.HasPrefix(sig, eventSignaturePrefix) {
+		return false
+	}
+	expected := SignEvent(body, secret)
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
+
+// RequireEventSignature verifies the X-Zoraxy-Signature of the request before calling next
+// The request body is restored so next can read it as usual
+func RequireEventSignature(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxEventBodySize+1))
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxEventBodySize {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !VerifyEventSignature(body, r.Header.Get(EventSignatureHeader), secret) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestSignature returns the signature of a request in the X-Zoraxy-Signature header format
+func requestSignature(method string, requestURI string, timestamp string, body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	return eventSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs the method, request URI, current time and body of the request with secret
+// and sets the X-Zoraxy-Timestamp and X-Zoraxy-Signature headers. body must be the request body
+func SignRequest(req *http.Request, body []byte, secret string) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(RequestTimestampHeader, timestamp)
+	req.Header.Set(EventSignatureHeader, requestSignature(req.Method, req.URL.RequestURI(), timestamp, body, secret))
+}
+
+// VerifyRequestSignature checks if the request was signed by SignRequest with secret less than
+// RequestSignatureMaxAge ago. body is the request body, read by the caller
+// An empty secret never verifies
+func VerifyRequestSignature(r *http.Request, body []byte, secret string) bool {
+	sig := r.Header.Get(EventSignatureHeader)
+	if secret == "" || !strings.HasPrefix(sig, eventSignaturePrefix) {
+		return false
+	}
+	timestamp := r.Header.Get(RequestTimestampHeader)
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(signedAt, 0))
+	if age > RequestSignatureMaxAge || age < -RequestSignatureMaxAge {
+		return false
+	}
+	expected := requestSignature(r.Method, r.URL.RequestURI(), timestamp, body, secret)
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
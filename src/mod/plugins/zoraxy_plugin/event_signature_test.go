@@ -0,0 +1,46 @@
+package zoraxy_plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireEventSignature(t *testing.T) {
+	secret := "test-secret"
+	body := `{"event_name":"test","event_source":"zoraxy","payload":""}`
+	handler := RequireEventSignature(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		w.Write(received)
+	}))
+
+	tests := []struct {
+		signature string
+		expected  int
+	}{
+		{SignEvent([]byte(body), secret), http.StatusOK},
+		{"", http.StatusUnauthorized},
+		{SignEvent([]byte(body), "wrong-secret"), http.StatusUnauthorized},
+		{SignEvent([]byte(body+" "), secret), http.StatusUnauthorized},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("POST", "/notifyme", strings.NewReader(body))
+		if test.signature != "" {
+			req.Header.Set(EventSignatureHeader, test.signature)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.expected {
+			t.Errorf("Case %d: expected status %d, got %d", i, test.expected, rec.Code)
+		}
+		if test.expected == http.StatusOK && rec.Body.String() != body {
+			t.Errorf("Case %d: expected the body to be restored, got %q", i, rec.Body.String())
+		}
+	}
+
+	if VerifyEventSignature([]byte(body), SignEvent([]byte(body), ""), "") {
+		t.Error("Expected an empty secret to never verify")
+	}
+}
@@ -0,0 +1,75 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+)
+
+/*
+	Events.go
+
+	This file lists the well-known event names Zoraxy emits to the
+	SubscriptionPath of a plugin. Use these constants as the keys of
+	IntroSpect.SubscriptionsEvents instead of magic strings, a misspelled
+	event name is reported by IntroSpect.Warnings
+*/
+
+const (
+	EventName_CertRenewed       = "cert_renewed"        //A TLS certificate was issued or renewed
+	EventName_ProxyRuleAdded    = "proxy_rule_added"    //A HTTP proxy rule was created
+	EventName_ProxyRuleUpdated  = "proxy_rule_updated"  //A HTTP proxy rule was edited
+	EventName_ProxyRuleRemoved  = "proxy_rule_removed"  //A HTTP proxy rule was deleted
+	EventName_BlacklistUpdated  = "blacklist_updated"   //The IP / country blacklist of an access rule changed
+	EventName_WhitelistUpdated  = "whitelist_updated"   //The IP / country whitelist of an access rule changed
+	EventName_AccessRuleUpdated = "access_rule_updated" //An access rule was created, edited or deleted
+)
+
+// ValidEventNames returns the event names Zoraxy can emit
+func ValidEventNames() []string {
+	return []string{
+		EventName_CertRenewed,
+		EventName_ProxyRuleAdded,
+		EventName_ProxyRuleUpdated,
+		EventName_ProxyRuleRemoved,
+		EventName_BlacklistUpdated,
+		EventName_WhitelistUpdated,
+		EventName_AccessRuleUpdated,
+	}
+}
+
+// IsValidEventName checks if the event name is emitted by Zoraxy
+func IsValidEventName(eventName string) bool {
+	return slices.Contains(ValidEventNames(), eventName)
+}
+
+// RegisterSubscriptionHandler registers the handler of the subscription events at subscriptionPath
+// Zoraxy POSTs a SubscriptionEvent to the path, the event is acknowledged if the handler returns nil
+// Wrap the registered handler with RequireEventSignature by registering it manually if you need
+// to verify the event origin. If mux is nil, the handler will be registered to http.DefaultServeMux
+func RegisterSubscriptionHandler(subscriptionPath string, eventHandler func(event *SubscriptionEvent) error, mux *http.ServeMux) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(subscriptionPath, SubscriptionHandler(eventHandler))
+}
+
+// SubscriptionHandler returns the http.Handler that decodes the subscription events for eventHandler
+func SubscriptionHandler(eventHandler func(event *SubscriptionEvent) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var event SubscriptionEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, "invalid subscription event", http.StatusBadRequest)
+			return
+		}
+		if err := eventHandler(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,50 @@
+package zoraxy_plugin
+
+import (
+	"fmt"
+)
+
+/*
+	Exit_codes.go
+
+	This file defines the exit codes a plugin uses to tell Zoraxy
+	why it stopped, so a supervisor can decide whether to restart it:
+
+	- ExitOK (0): the plugin was asked to stop, do not restart
+	- ExitTransient (75): temporary failure (e.g. a backend is not up yet),
+	  restart with backoff
+	- ExitConfigError (78): the configure spec or plugin options are invalid,
+	  do not restart until the configuration changes
+	- ExitFatal (70): unrecoverable error, do not restart
+
+	Any other non zero exit code (including a panic, which exits with 2)
+	is treated as transient. The codes follow the BSD sysexits convention
+*/
+
+const (
+	ExitOK          = 0
+	ExitFatal       = 70 //EX_SOFTWARE
+	ExitTransient   = 75 //EX_TEMPFAIL
+	ExitConfigError = 78 //EX_CONFIG
+)
+
+// Exitf prints the message to STDOUT (written to the Zoraxy log) and exits with the given code
+func Exitf(code int, format string, args ...any) {
+	fmt.Println(fmt.Sprintf(format, args...))
+	ExitFunc(code)
+}
+
+// Fatalf prints the message and exits with ExitFatal, Zoraxy will not restart the plugin
+func Fatalf(format string, args ...any) {
+	Exitf(ExitFatal, format, args...)
+}
+
+// ShouldRestart reports whether a plugin that exited with the given code should be restarted
+func ShouldRestart(exitCode int) bool {
+	switch exitCode {
+	case ExitOK, ExitFatal, ExitConfigError:
+		return false
+	default:
+		return true
+	}
+}
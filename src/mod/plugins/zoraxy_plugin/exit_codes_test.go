@@ -0,0 +1,40 @@
+package zoraxy_plugin
+
+import (
+	"os"
+	"testing"
+)
+
+func TestExitf(t *testing.T) {
+	originalExit := ExitFunc
+	originalStdout := os.Stdout
+	defer func() {
+		ExitFunc = originalExit
+		os.Stdout = originalStdout
+	}()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer devNull.Close()
+	os.Stdout = devNull
+
+	exitCode := -1
+	ExitFunc = func(code int) {
+		exitCode = code
+	}
+	Fatalf("cannot open database: %s", "locked")
+	if exitCode != ExitFatal {
+		t.Errorf("Expected exit code %d, got %d", ExitFatal, exitCode)
+	}
+	Exitf(ExitConfigError, "invalid option")
+	if exitCode != ExitConfigError {
+		t.Errorf("Expected exit code %d, got %d", ExitConfigError, exitCode)
+	}
+
+	for code, expected := range map[int]bool{ExitOK: false, ExitFatal: false, ExitConfigError: false, ExitTransient: true, 1: true, 2: true} {
+		if ShouldRestart(code) != expected {
+			t.Errorf("ShouldRestart(%d): expected %v", code, expected)
+		}
+	}
+}
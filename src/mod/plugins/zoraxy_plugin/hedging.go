@@ -0,0 +1,246 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	Hedging.go
+
+	This file provides an opt-in request hedging helper for router plugins
+	that forward traffic to more than one upstream. If the primary upstream
+	does not respond within the hedge delay, a duplicate request is sent to
+	the secondary upstream and whichever responds first is returned.
+
+	Only safe methods (GET, HEAD, OPTIONS) without a body are hedged,
+	and a hedge is only sent if it keeps the ratio of hedged requests
+	over all handled requests at or below MaxHedgeRatio. Register the
+	hedging counters with the metrics endpoint with RegisterMetrics
+
+	Example:
+	hedger, _ := zoraxy_plugin.NewHedger("http://10.0.0.1:8080", "http://10.0.0.2:8080", nil)
+	hedger.RegisterMetrics(metrics)
+	resp, err := hedger.Do(r)
+*/
+
+type HedgeOptions struct {
+	Delay         time.Duration     //Delay before the hedged request is sent, default 50ms
+	MaxHedgeRatio float64           //Max ratio of hedged requests over all requests (0 - 1), default 0.1
+	Transport     http.RoundTripper //Transport used for upstream requests, default http.DefaultTransport
+}
+
+type HedgeStats struct {
+	Total     int64 `json:"total"`      //Number of requests handled
+	Hedged    int64 `json:"hedged"`     //Number of requests that sent a hedged request
+	HedgeWins int64 `json:"hedge_wins"` //Number of requests answered by the hedged request
+}
+
+type Hedger struct {
+	primary   *url.URL
+	secondary *url.URL
+	options   HedgeOptions
+
+	total     atomic.Int64
+	hedged    atomic.Int64
+	hedgeWins atomic.Int64
+}
+
+type hedgeResult struct {
+	resp     *http.Response
+	err      error
+	isHedged bool
+}
+
+// NewHedger creates a new Hedger that forwards requests to the primary upstream
+// and hedges them to the secondary upstream after the configured delay
+func NewHedger(primaryUpstream string, secondaryUpstream string, options *HedgeOptions) (*Hedger, error) {
+	primary, err := url.Parse(primaryUpstream)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := url.Parse(secondaryUpstream)
+	if err != nil {
+		return nil, err
+	}
+	if primary.Host == "" || secondary.Host == "" {
+		return nil, errors.New("upstream must be an absolute URL (e.g. http://127.0.0.1:8080)")
+	}
+
+	opts := HedgeOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = 50 * time.Millisecond
+	}
+	if opts.MaxHedgeRatio <= 0 || opts.MaxHedgeRatio > 1 {
+		opts.MaxHedgeRatio = 0.1
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+
+	return &Hedger{
+		primary:   primary,
+		secondary: secondary,
+		options:   opts,
+	}, nil
+}
+
+// Do sends the request to the upstreams and returns the first response
+// The request URL path and query are kept, only the scheme and host are replaced
+func (h *Hedger) Do(r *http.Request) (*http.Response, error) {
+	h.total.Add(1)
+	if !isHedgeableMethod(r.Method) || (r.Body != nil && r.Body != http.NoBody) {
+		//Not safe to send twice, forward to primary only
+		return h.options.Transport.RoundTrip(h.upstreamRequest(r.Context(), r, h.primary))
+	}
+
+	results := make(chan hedgeResult, 2)
+	primaryCtx, cancelPrimary := context.WithCancel(r.Context())
+	go h.send(primaryCtx, r, h.primary, false, results)
+
+	timer := time.NewTimer(h.options.Delay)
+	defer timer.Stop()
+
+	var cancelHedge context.CancelFunc
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if cancelHedge != nil || !h.allowHedge() {
+				continue
+			}
+			h.hedged.Add(1)
+			var hedgeCtx context.Context
+			hedgeCtx, cancelHedge = context.WithCancel(r.Context())
+			pending++
+			go h.send(hedgeCtx, r, h.secondary, true, results)
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				if pending > 0 {
+					//Wait for the other request
+					continue
+				}
+				if cancelHedge == nil && h.allowHedge() {
+					//Primary failed before the hedge delay, fire the hedge right away
+					h.hedged.Add(1)
+					var hedgeCtx context.Context
+					hedgeCtx, cancelHedge = context.WithCancel(r.Context())
+					pending++
+					go h.send(hedgeCtx, r, h.secondary, true, results)
+					continue
+				}
+				cancelPrimary()
+				if cancelHedge != nil {
+					cancelHedge()
+				}
+				return nil, result.err
+			}
+
+			//Got a winner, cancel the loser and drain its response in the background
+			winnerCancel := cancelPrimary
+			if result.isHedged {
+				h.hedgeWins.Add(1)
+				winnerCancel = cancelHedge
+				cancelPrimary()
+			} else if cancelHedge != nil {
+				cancelHedge()
+			}
+			if pending > 0 {
+				go drainHedgeResults(results, pending)
+			}
+			result.resp.Body = &cancelOnCloseBody{ReadCloser: result.resp.Body, cancel: winnerCancel}
+			return result.resp, nil
+		}
+	}
+}
+
+// Stats returns the hedging counters of this Hedger
+func (h *Hedger) Stats() HedgeStats {
+	return HedgeStats{
+		Total:     h.total.Load(),
+		Hedged:    h.hedged.Load(),
+		HedgeWins: h.hedgeWins.Load(),
+	}
+}
+
+// RegisterMetrics exports the counters of Stats to the metrics registry
+// The metric names are fixed, register a single Hedger per registry
+func (h *Hedger) RegisterMetrics(m *MetricsRegistry) {
+	m.RegisterCounterFunc(Metric_HedgeRequestsTotal, "Number of requests handled by the hedger", func() float64 {
+		return float64(h.total.Load())
+	})
+	m.RegisterCounterFunc(Metric_HedgedRequestsTotal, "Number of requests that sent a hedged request", func() float64 {
+		return float64(h.hedged.Load())
+	})
+	m.RegisterCounterFunc(Metric_HedgeWinsTotal, "Number of requests answered by the hedged request", func() float64 {
+		return float64(h.hedgeWins.Load())
+	})
+}
+
+// HedgeRate returns the ratio of requests that were hedged
+func (s HedgeStats) HedgeRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Hedged) / float64(s.Total)
+}
+
+func (h *Hedger) allowHedge() bool {
+	total := h.total.Load()
+	if total == 0 {
+		return false
+	}
+	return float64(h.hedged.Load()+1)/float64(total) <= h.options.MaxHedgeRatio
+}
+
+func (h *Hedger) send(ctx context.Context, r *http.Request, upstream *url.URL, isHedged bool, results chan<- hedgeResult) {
+	resp, err := h.options.Transport.RoundTrip(h.upstreamRequest(ctx, r, upstream))
+	results <- hedgeResult{resp: resp, err: err, isHedged: isHedged}
+}
+
+func (h *Hedger) upstreamRequest(ctx context.Context, r *http.Request, upstream *url.URL) *http.Request {
+	req := r.Clone(ctx)
+	req.RequestURI = ""
+	req.URL.Scheme = upstream.Scheme
+	req.URL.Host = upstream.Host
+	req.Host = upstream.Host
+	return req
+}
+
+func isHedgeableMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func drainHedgeResults(results <-chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		result := <-results
+		if result.resp != nil {
+			result.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnCloseBody releases the winner request context once the body is closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,127 @@
+package zoraxy_plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// hedgeTestTransport answers the primary after primaryDelay and the secondary right away
+type hedgeTestTransport struct {
+	primaryDelay     time.Duration
+	secondaryCalls   atomic.Int32
+	primaryCancelled chan struct{}
+}
+
+func (t *hedgeTestTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host == "secondary" {
+		t.secondaryCalls.Add(1)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("secondary")), Request: r}, nil
+	}
+	select {
+	case <-time.After(t.primaryDelay):
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("primary")), Request: r}, nil
+	case <-r.Context().Done():
+		if t.primaryCancelled != nil {
+			close(t.primaryCancelled)
+		}
+		return nil, r.Context().Err()
+	}
+}
+
+func doHedgeTestRequest(t *testing.T, h *Hedger, method string, body io.Reader) string {
+	resp, err := h.Do(httptest.NewRequest(method, "/resource", body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	content, _ := io.ReadAll(resp.Body)
+	return string(content)
+}
+
+func TestHedgerOnlyHedgesSafeRequests(t *testing.T) {
+	transport := &hedgeTestTransport{primaryDelay: 50 * time.Millisecond}
+	h, err := NewHedger("http://primary", "http://secondary", &HedgeOptions{Delay: time.Millisecond, MaxHedgeRatio: 1, Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := doHedgeTestRequest(t, h, http.MethodPost, nil); got != "primary" {
+		t.Errorf("Expected POST to go to the primary only, got %q", got)
+	}
+	if got := doHedgeTestRequest(t, h, http.MethodGet, strings.NewReader("body")); got != "primary" {
+		t.Errorf("Expected a GET with a body to go to the primary only, got %q", got)
+	}
+	if transport.secondaryCalls.Load() != 0 {
+		t.Errorf("Expected no hedged request, got %d", transport.secondaryCalls.Load())
+	}
+	if got := doHedgeTestRequest(t, h, http.MethodGet, nil); got != "secondary" {
+		t.Errorf("Expected the slow GET to be answered by the hedge, got %q", got)
+	}
+	stats := h.Stats()
+	if stats.Total != 3 || stats.Hedged != 1 || stats.HedgeWins != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestHedgerMaxHedgeRatio(t *testing.T) {
+	transport := &hedgeTestTransport{primaryDelay: 20 * time.Millisecond}
+	h, err := NewHedger("http://primary", "http://secondary", &HedgeOptions{Delay: time.Millisecond, MaxHedgeRatio: 0.5, Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		doHedgeTestRequest(t, h, http.MethodGet, nil)
+	}
+	stats := h.Stats()
+	if stats.Total != 10 || stats.Hedged != 5 || stats.HedgeRate() != 0.5 {
+		t.Errorf("Expected half of the requests to be hedged, got %+v", stats)
+	}
+	if transport.secondaryCalls.Load() != 5 {
+		t.Errorf("Expected 5 hedged requests, got %d", transport.secondaryCalls.Load())
+	}
+}
+
+func TestHedgerCancelsLoser(t *testing.T) {
+	transport := &hedgeTestTransport{primaryDelay: time.Minute, primaryCancelled: make(chan struct{})}
+	h, err := NewHedger("http://primary", "http://secondary", &HedgeOptions{Delay: time.Millisecond, MaxHedgeRatio: 1, Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doHedgeTestRequest(t, h, http.MethodGet, nil); got != "secondary" {
+		t.Fatalf("Expected the hedge to win, got %q", got)
+	}
+	select {
+	case <-transport.primaryCancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the losing primary request to be cancelled")
+	}
+}
+
+func TestHedgerMetrics(t *testing.T) {
+	transport := &hedgeTestTransport{primaryDelay: 50 * time.Millisecond}
+	h, err := NewHedger("http://primary", "http://secondary", &HedgeOptions{Delay: time.Millisecond, MaxHedgeRatio: 1, Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := NewMetricsRegistry("org.example.test")
+	h.RegisterMetrics(metrics)
+	doHedgeTestRequest(t, h, http.MethodGet, nil)
+	doHedgeTestRequest(t, h, http.MethodPost, nil)
+
+	output := metrics.render()
+	for _, expected := range []string{
+		"# TYPE " + Metric_HedgeRequestsTotal + " counter",
+		Metric_HedgeRequestsTotal + `{plugin_id="org.example.test"} 2`,
+		Metric_HedgedRequestsTotal + `{plugin_id="org.example.test"} 1`,
+		Metric_HedgeWinsTotal + `{plugin_id="org.example.test"} 1`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in the metrics output:\n%s", expected, output)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+/*
+	Host_router.go
+
+	This file provides a composite handler for plugins that serve
+	host based routes (e.g. a per-domain landing page) next to the
+	PluginUiRouter. Host matching always happens first on the original
+	request, only requests that do not match any host route fall through
+	to the UI router where the handler prefix is stripped.
+
+	Note that requests proxied from the Zoraxy web UI carry the Host
+	of the Zoraxy management interface, so do not register that host
+	as a host route or the plugin UI will be shadowed
+*/
+
+// ValidateHostRoutes checks if the given host routes can be used together with the PluginUiRouter
+func (p *PluginUiRouter) ValidateHostRoutes(hostRoutes map[string]http.Handler) error {
+	seen := map[string]string{}
+	for host, handler := range hostRoutes {
+		if handler == nil {
+			return errors.New("host route " + host + " has a nil handler")
+		}
+		if strings.ContainsAny(strings.TrimSpace(host), "/?#@ ") {
+			return errors.New("host route " + host + " must be a hostname without scheme or path")
+		}
+		normalizedHost := normalizeRouteHost(host)
+		if normalizedHost == "" {
+			return errors.New("host route cannot be empty")
+		}
+		if previous, ok := seen[normalizedHost]; ok {
+			return errors.New("host route " + host + " duplicates " + previous)
+		}
+		seen[normalizedHost] = host
+	}
+	return nil
+}
+
+// HandlerWithHostRoutes returns a http.Handler that dispatches the request to the matching
+// host route handler, or falls through to the PluginUiRouter handler if no host matches
+// Host route handlers receive the request untouched (without handler prefix stripping)
+func (p *PluginUiRouter) HandlerWithHostRoutes(hostRoutes map[string]http.Handler) (http.Handler, error) {
+	err := p.ValidateHostRoutes(hostRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := map[string]http.Handler{}
+	for host, handler := range hostRoutes {
+		routes[normalizeRouteHost(host)] = handler
+	}
+	uiHandler := p.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := routes[normalizeRouteHost(r.Host)]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		uiHandler.ServeHTTP(w, r)
+	}), nil
+}
+
+// normalizeRouteHost lower-cases the host and strips the port and trailing dot
+func normalizeRouteHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimPrefix(host, "[")
+	host = strings.TrimSuffix(host, "]")
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,68 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateHostRoutes(t *testing.T) {
+	router := newTestUiRouter()
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		routes  map[string]http.Handler
+		wantErr bool
+	}{
+		{"valid", map[string]http.Handler{"a.example.com": okHandler, "b.example.com:8080": okHandler}, false},
+		{"empty host", map[string]http.Handler{"": okHandler}, true},
+		{"nil handler", map[string]http.Handler{"a.example.com": nil}, true},
+		{"with scheme", map[string]http.Handler{"https://a.example.com": okHandler}, true},
+		{"with path", map[string]http.Handler{"a.example.com/ui": okHandler}, true},
+		{"duplicate after normalize", map[string]http.Handler{"A.example.com": okHandler, "a.example.com:443": okHandler}, true},
+	}
+
+	for _, test := range tests {
+		err := router.ValidateHostRoutes(test.routes)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: expected error = %v, got %v", test.name, test.wantErr, err)
+		}
+	}
+}
+
+func TestHandlerWithHostRoutes(t *testing.T) {
+	router := newTestUiRouter()
+	var hostRouteURI string
+	handler, err := router.HandlerWithHostRoutes(map[string]http.Handler{
+		"widget.example.com": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hostRouteURI = r.RequestURI
+			w.Write([]byte("widget"))
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Matching host route, the prefix must not be stripped
+	req := httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Host = "Widget.Example.com:8443"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Body.String() != "widget" {
+		t.Errorf("Expected host route to handle the request, got %q", rec.Body.String())
+	}
+	if hostRouteURI != "/ui/page.html" {
+		t.Errorf("Expected host route to receive the original URI, got %q", hostRouteURI)
+	}
+
+	//Non matching host falls through to the UI router
+	req = httptest.NewRequest("GET", "/ui/page.html", nil)
+	req.Host = "zoraxy.example.com"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Sub Page") {
+		t.Errorf("Expected UI router to serve page.html, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,216 @@
+package zoraxy_plugin
+
+/*
+	Introspect_builder.go
+
+	This file provides a fluent builder for the IntroSpect payload
+	as an alternative to a large struct literal. Build() validates
+	the result so missing required fields are caught at startup
+
+	Example:
+	spec, err := NewIntroSpect("com.example.myplugin", "My Plugin").
+		WithAuthor("foobar", "admin@example.com").
+		WithDescription("A simple plugin").
+		WithVersion(1, 0, 0).
+		AsUtility().
+		WithUIPath("/ui").
+		Build()
+*/
+
+type IntroSpectBuilder struct {
+	spec IntroSpect
+}
+
+// NewIntroSpect creates a new IntroSpectBuilder with the given plugin ID and name
+func NewIntroSpect(id string, name string) *IntroSpectBuilder {
+	return &IntroSpectBuilder{
+		spec: IntroSpect{
+			ID:   id,
+			Name: name,
+			Type: PluginType_Utilities,
+		},
+	}
+}
+
+// WithAuthor sets the author name and contact of the plugin
+func (b *IntroSpectBuilder) WithAuthor(author string, contact string) *IntroSpectBuilder {
+	b.spec.Author = author
+	b.spec.AuthorContact = contact
+	return b
+}
+
+// WithDescription sets the description of the plugin
+func (b *IntroSpectBuilder) WithDescription(description string) *IntroSpectBuilder {
+	b.spec.Description = description
+	return b
+}
+
+// WithURL sets the URL of the plugin
+func (b *IntroSpectBuilder) WithURL(url string) *IntroSpectBuilder {
+	b.spec.URL = url
+	return b
+}
+
+// WithLicense sets the SPDX license identifier and the source code URL of the plugin
+func (b *IntroSpectBuilder) WithLicense(license string, sourceURL string) *IntroSpectBuilder {
+	b.spec.License = license
+	b.spec.SourceURL = sourceURL
+	return b
+}
+
+// WithVersion sets the version of the plugin
+func (b *IntroSpectBuilder) WithVersion(major int, minor int, patch int) *IntroSpectBuilder {
+	b.spec.VersionMajor = major
+	b.spec.VersionMinor = minor
+	b.spec.VersionPatch = patch
+	return b
+}
+
+// AsRouter marks the plugin as a router plugin
+func (b *IntroSpectBuilder) AsRouter() *IntroSpectBuilder {
+	b.spec.Type = PluginType_Router
+	return b
+}
+
+// AsUtility marks the plugin as a utilities plugin (default)
+func (b *IntroSpectBuilder) AsUtility() *IntroSpectBuilder {
+	b.spec.Type = PluginType_Utilities
+	return b
+}
+
+// AsTLSInspector marks the plugin as a TLS inspector plugin with the given SNI inspect ingress
+func (b *IntroSpectBuilder) AsTLSInspector(sniInspectIngress string) *IntroSpectBuilder {
+	b.spec.Type = PluginType_TLSInspector
+	b.spec.SNIInspectIngress = sniInspectIngress
+	return b
+}
+
+// WithGlobalCapture sets the global capture ingress and appends the capture rules
+func (b *IntroSpectBuilder) WithGlobalCapture(ingress string, rules ...CaptureRule) *IntroSpectBuilder {
+	b.spec.GlobalCaptureIngress = ingress
+	b.spec.GlobalCapturePaths = append(b.spec.GlobalCapturePaths, rules...)
+	return b
+}
+
+// WithAlwaysCapture sets the always capture ingress and appends the capture rules
+func (b *IntroSpectBuilder) WithAlwaysCapture(ingress string, rules ...CaptureRule) *IntroSpectBuilder {
+	b.spec.AlwaysCaptureIngress = ingress
+	b.spec.AlwaysCapturePaths = append(b.spec.AlwaysCapturePaths, rules...)
+	return b
+}
+
+// WithDynamicIngress appends a named dynamic capture ingress pair
+func (b *IntroSpectBuilder) WithDynamicIngress(name string, captureIngress string, handleIngress string) *IntroSpectBuilder {
+	b.spec.DynamicIngresses = append(b.spec.DynamicIngresses, DynamicIngress{Name: name, CaptureIngress: captureIngress, HandleIngress: handleIngress})
+	return b
+}
+
+// WithResponseFilter sets the ingress receiving the upstream responses of requests answered with ControlStatusCode_FILTER
+func (b *IntroSpectBuilder) WithResponseFilter(ingress string) *IntroSpectBuilder {
+	b.spec.ResponseFilterIngress = ingress
+	return b
+}
+
+// WithCapturePriority sets the capture priority of the plugin, higher priorities are invoked first
+func (b *IntroSpectBuilder) WithCapturePriority(priority int) *IntroSpectBuilder {
+	b.spec.CapturePriority = priority
+	return b
+}
+
+// WithDefaultEnabled enables the plugin on new HTTP proxy rules with the given capture mode preselected
+func (b *IntroSpectBuilder) WithDefaultEnabled(mode CaptureMode) *IntroSpectBuilder {
+	b.spec.DefaultEnabled = true
+	b.spec.DefaultCaptureMode = mode
+	return b
+}
+
+// WithPreferredPort requests a fixed port and an accepted fallback range, pass a zero range for no fallback preference
+func (b *IntroSpectBuilder) WithPreferredPort(port int, portRange [2]int) *IntroSpectBuilder {
+	b.spec.PreferredPort = port
+	b.spec.PortRange = nil
+	if portRange != [2]int{} {
+		b.spec.PortRange = &portRange
+	}
+	return b
+}
+
+// WithUIPath sets the UI path of the plugin
+func (b *IntroSpectBuilder) WithUIPath(uiPath string) *IntroSpectBuilder {
+	b.spec.UIPath = uiPath
+	return b
+}
+
+// WithUITab appends a named UI entry point shown as a sidebar link in Zoraxy
+func (b *IntroSpectBuilder) WithUITab(title string, path string, icon string) *IntroSpectBuilder {
+	b.spec.UITabs = append(b.spec.UITabs, UITab{Title: title, Path: path, Icon: icon})
+	return b
+}
+
+// WithOpenAPIPath sets the path of the OpenAPI spec relative to the UI path
+func (b *IntroSpectBuilder) WithOpenAPIPath(openAPIPath string) *IntroSpectBuilder {
+	b.spec.OpenAPIPath = openAPIPath
+	return b
+}
+
+// WithUICapabilities sets the presentation preferences of the plugin UI
+func (b *IntroSpectBuilder) WithUICapabilities(capabilities UICapabilities) *IntroSpectBuilder {
+	b.spec.UICapabilities = &capabilities
+	return b
+}
+
+// WithSettings appends fields to the settings schema of the plugin
+func (b *IntroSpectBuilder) WithSettings(fields ...SettingField) *IntroSpectBuilder {
+	b.spec.SettingsSchema = append(b.spec.SettingsSchema, fields...)
+	return b
+}
+
+// WithSubscriptions sets the subscription path and the subscribed events
+func (b *IntroSpectBuilder) WithSubscriptions(subscriptionPath string, events map[string]string) *IntroSpectBuilder {
+	b.spec.SubscriptionPath = subscriptionPath
+	if b.spec.SubscriptionsEvents == nil {
+		b.spec.SubscriptionsEvents = map[string]string{}
+	}
+	for event, description := range events {
+		b.spec.SubscriptionsEvents[event] = description
+	}
+	return b
+}
+
+// WithPermissions appends the permissions required by the plugin
+func (b *IntroSpectBuilder) WithPermissions(permissions ...string) *IntroSpectBuilder {
+	b.spec.Permissions = append(b.spec.Permissions, permissions...)
+	return b
+}
+
+// WithOutboundHosts appends the external hosts contacted by the plugin in host:port format
+func (b *IntroSpectBuilder) WithOutboundHosts(hosts ...string) *IntroSpectBuilder {
+	b.spec.OutboundHosts = append(b.spec.OutboundHosts, hosts...)
+	return b
+}
+
+// WithConflicts appends the plugin IDs or capture path globs the plugin conflicts with
+func (b *IntroSpectBuilder) WithConflicts(conflicts ...string) *IntroSpectBuilder {
+	b.spec.Conflicts = append(b.spec.Conflicts, conflicts...)
+	return b
+}
+
+// WithIcon sets the icon of the plugin, see IntroSpect.Icon
+func (b *IntroSpectBuilder) WithIcon(icon string) *IntroSpectBuilder {
+	b.spec.Icon = icon
+	return b
+}
+
+// WithTLSPolicy sets the TLS policy of the plugin
+func (b *IntroSpectBuilder) WithTLSPolicy(policy *TLSPolicy) *IntroSpectBuilder {
+	b.spec.TLSPolicy = policy
+	return b
+}
+
+// Build validates and returns the IntroSpect
+func (b *IntroSpectBuilder) Build() (*IntroSpect, error) {
+	spec := b.spec
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
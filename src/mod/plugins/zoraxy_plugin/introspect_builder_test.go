@@ -0,0 +1,151 @@
+package zoraxy_plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntroSpectBuilder(t *testing.T) {
+	globalRule := CaptureRule{CapturePath: "/api", IncludeSubPaths: true}
+	alwaysRule := CaptureRule{CapturePath: "/app"}
+	setting := SettingField{Key: "greeting", Label: "Greeting", Type: SettingFieldType_String}
+	policy := &TLSPolicy{MinVersion: "1.3"}
+
+	spec, err := NewIntroSpect("org.example.test", "Test").
+		WithAuthor("foobar", "admin@example.com").
+		WithDescription("Test").
+		WithURL("https://example.com").
+		WithLicense("MIT", "https://example.com/source").
+		WithVersion(1, 2, 3).
+		AsRouter().
+		WithGlobalCapture("/capture", globalRule).
+		WithAlwaysCapture("/always", alwaysRule).
+		WithDynamicIngress("auth", "/d_auth_sniff", "/d_auth").
+		WithResponseFilter("/filter").
+		WithCapturePriority(CapturePriority_High).
+		WithDefaultEnabled(CaptureMode_Always).
+		WithPreferredPort(8150, [2]int{8100, 8200}).
+		WithUIPath("/ui").
+		WithUITab("Logs", "/ui/logs", "list").
+		WithOpenAPIPath("/openapi.json").
+		WithUICapabilities(UICapabilities{EmbedMode: UIEmbedMode_Tab}).
+		WithSettings(setting).
+		WithSubscriptions("/notifyme", map[string]string{"blacklistToggled": "Blacklist toggled"}).
+		WithPermissions(Permission_NetOutbound).
+		WithOutboundHosts("api.example.com:443").
+		WithConflicts("org.example.other").
+		WithIcon("/ui/icon.png").
+		WithTLSPolicy(policy).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := &IntroSpect{
+		ID:                    "org.example.test",
+		Name:                  "Test",
+		Author:                "foobar",
+		AuthorContact:         "admin@example.com",
+		Description:           "Test",
+		URL:                   "https://example.com",
+		License:               "MIT",
+		SourceURL:             "https://example.com/source",
+		Type:                  PluginType_Router,
+		VersionMajor:          1,
+		VersionMinor:          2,
+		VersionPatch:          3,
+		GlobalCaptureIngress:  "/capture",
+		GlobalCapturePaths:    []CaptureRule{globalRule},
+		AlwaysCaptureIngress:  "/always",
+		AlwaysCapturePaths:    []CaptureRule{alwaysRule},
+		DynamicIngresses:      []DynamicIngress{{Name: "auth", CaptureIngress: "/d_auth_sniff", HandleIngress: "/d_auth"}},
+		ResponseFilterIngress: "/filter",
+		CapturePriority:       CapturePriority_High,
+		DefaultEnabled:        true,
+		DefaultCaptureMode:    CaptureMode_Always,
+		PreferredPort:         8150,
+		PortRange:             &[2]int{8100, 8200},
+		UIPath:                "/ui",
+		UITabs:                []UITab{{Title: "Logs", Path: "/ui/logs", Icon: "list"}},
+		OpenAPIPath:           "/openapi.json",
+		UICapabilities:        &UICapabilities{EmbedMode: UIEmbedMode_Tab},
+		SettingsSchema:        []SettingField{setting},
+		SubscriptionPath:      "/notifyme",
+		SubscriptionsEvents:   map[string]string{"blacklistToggled": "Blacklist toggled"},
+		Permissions:           []string{Permission_NetOutbound},
+		OutboundHosts:         []string{"api.example.com:443"},
+		Conflicts:             []string{"org.example.other"},
+		Icon:                  "/ui/icon.png",
+		TLSPolicy:             policy,
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("Unexpected intro spect\n got: %+v\nwant: %+v", spec, expected)
+	}
+
+	//Type setters replace each other, utilities is the default
+	builder := NewIntroSpect("org.example.test", "Test")
+	if builder.spec.Type != PluginType_Utilities {
+		t.Errorf("Expected the utilities type by default, got %d", builder.spec.Type)
+	}
+	if builder.AsRouter().AsUtility().spec.Type != PluginType_Utilities {
+		t.Error("Expected AsUtility to set the utilities type")
+	}
+	if b := builder.AsTLSInspector("/sni"); b.spec.Type != PluginType_TLSInspector || b.spec.SNIInspectIngress != "/sni" {
+		t.Errorf("Expected AsTLSInspector to set the type and ingress, got %d %q", b.spec.Type, b.spec.SNIInspectIngress)
+	}
+
+	//List setters append across calls
+	builder = NewIntroSpect("org.example.test", "Test").
+		WithPermissions(Permission_FsRead).WithPermissions(Permission_FsWrite).
+		WithSubscriptions("/notifyme", map[string]string{"a": "A"}).WithSubscriptions("/notifyme", map[string]string{"b": "B"})
+	if !reflect.DeepEqual(builder.spec.Permissions, []string{Permission_FsRead, Permission_FsWrite}) {
+		t.Errorf("Expected the permissions to be appended, got %v", builder.spec.Permissions)
+	}
+	if len(builder.spec.SubscriptionsEvents) != 2 {
+		t.Errorf("Expected the subscriptions to be merged, got %v", builder.spec.SubscriptionsEvents)
+	}
+}
+
+func TestIntroSpectBuilderValidates(t *testing.T) {
+	base := func() *IntroSpectBuilder {
+		return NewIntroSpect("org.example.test", "Test").
+			WithAuthor("foobar", "").
+			WithDescription("Test").
+			WithUIPath("/ui")
+	}
+	tests := []struct {
+		name    string
+		builder *IntroSpectBuilder
+	}{
+		{"missing author", NewIntroSpect("org.example.test", "Test").WithDescription("Test").WithUIPath("/ui")},
+		{"missing name", NewIntroSpect("org.example.test", "").WithAuthor("foobar", "").WithDescription("Test").WithUIPath("/ui")},
+		{"unknown permission", base().WithPermissions("net.everything")},
+		{"invalid outbound host", base().WithOutboundHosts("example.com")},
+		{"TLS inspector without ingress", base().AsTLSInspector("")},
+		{"undeclared default capture mode", base().WithDefaultEnabled(CaptureMode_Global)},
+		{"invalid port range", base().WithPreferredPort(0, [2]int{8200, 8100})},
+		{"insecure TLS policy", base().WithTLSPolicy(&TLSPolicy{MinVersion: "1.0"})},
+	}
+	for _, test := range tests {
+		expectedErr := test.builder.spec.Validate()
+		if expectedErr == nil {
+			t.Fatalf("%s: expected the spec to be invalid", test.name)
+		}
+		spec, err := test.builder.Build()
+		if spec != nil || err == nil || err.Error() != expectedErr.Error() {
+			t.Errorf("%s: expected Build to return the Validate error %q, got %v, %v", test.name, expectedErr, spec, err)
+		}
+	}
+
+	spec, err := base().Build()
+	if err != nil || spec.ID != "org.example.test" {
+		t.Fatalf("Expected a valid spec, got %v, %v", spec, err)
+	}
+	//Build returns a copy, later builder calls do not modify it
+	builder := base()
+	spec, _ = builder.Build()
+	builder.WithDescription("Changed")
+	if spec.Description != "Test" {
+		t.Errorf("Expected the built spec to be independent of the builder, got %q", spec.Description)
+	}
+}
@@ -0,0 +1,60 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+/*
+	Json_response.go
+
+	This file provides helpers for writing JSON API responses, so plugin
+	APIs share a uniform error envelope that Zoraxy and plugin UIs can
+	parse the same way:
+
+	{"error": {"code": "invalid_request", "message": "name is empty"}}
+
+	Example:
+	if name == "" {
+		zoraxy_plugin.WriteJSONError(w, http.StatusBadRequest, "invalid_request", "name is empty")
+		return
+	}
+	zoraxy_plugin.WriteJSON(w, http.StatusOK, result)
+*/
+
+// JSONError is the error object of the JSON error envelope
+type JSONError struct {
+	Code    string `json:"code"`    //Machine readable error code (e.g. not_found)
+	Message string `json:"message"` //Human readable error message
+}
+
+// JSONErrorEnvelope is the body written by WriteJSONError
+type JSONErrorEnvelope struct {
+	Error JSONError `json:"error"`
+}
+
+// WriteJSON writes v as JSON with the given status code
+// If v cannot be encoded, a 500 error envelope is written instead and the encode error is returned
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	js, err := json.Marshal(v)
+	if err != nil {
+		fmt.Println("[json] failed to encode response: " + err.Error())
+		WriteJSONError(w, http.StatusInternalServerError, "internal_error", "failed to encode response")
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, err = w.Write(js)
+	return err
+}
+
+// WriteJSONError writes the uniform JSON error envelope with the given status code
+func WriteJSONError(w http.ResponseWriter, status int, code, message string) {
+	js, _ := json.Marshal(JSONErrorEnvelope{Error: JSONError{Code: code, Message: message}})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(js)
+}
@@ -0,0 +1,43 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteJSONError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteJSONError(rec, http.StatusBadRequest, "invalid_request", "name is empty")
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected a 400 JSON response, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	envelope := JSONErrorEnvelope{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Error.Code != "invalid_request" || envelope.Error.Message != "name is empty" {
+		t.Errorf("Unexpected envelope %+v", envelope)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := WriteJSON(rec, http.StatusCreated, map[string]int{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":1}` {
+		t.Errorf("Expected 201 {\"id\":1}, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	//Values that cannot be encoded answer the error envelope
+	rec = httptest.NewRecorder()
+	if err := WriteJSON(rec, http.StatusOK, make(chan int)); err == nil {
+		t.Error("Expected an encode error")
+	}
+	envelope := JSONErrorEnvelope{}
+	if rec.Code != http.StatusInternalServerError || json.Unmarshal(rec.Body.Bytes(), &envelope) != nil || envelope.Error.Code != "internal_error" {
+		t.Errorf("Expected a 500 error envelope, got %d %q", rec.Code, rec.Body.String())
+	}
+}
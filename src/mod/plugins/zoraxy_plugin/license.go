@@ -0,0 +1,79 @@
+package zoraxy_plugin
+
+import (
+	_ "embed"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+/*
+	License.go
+
+	This file validates the License of the IntroSpect against the
+	embedded list of common SPDX license identifiers (spdx_licenses.txt).
+	Simple SPDX expressions are accepted (e.g. "Apache-2.0 OR MIT",
+	"GPL-2.0-or-later WITH Classpath-exception-2.0"), as well as custom
+	licenses in the LicenseRef-<name> form
+
+	Example:
+	spec.License = "MIT"
+	spec.SourceURL = "https://github.com/example/myplugin"
+*/
+
+//go:embed spdx_licenses.txt
+var spdxLicenseList string
+
+// KnownSPDXLicenses returns the SPDX license identifiers accepted by Validate
+func KnownSPDXLicenses() []string {
+	return strings.Fields(spdxLicenseList)
+}
+
+// IsKnownSPDXLicense checks if the SPDX license identifier is known, the match is case insensitive as in the SPDX specification
+func IsKnownSPDXLicense(id string) bool {
+	for _, known := range KnownSPDXLicenses() {
+		if strings.EqualFold(known, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLicense checks the License expression and the SourceURL of the plugin
+func (i *IntroSpect) validateLicense() error {
+	if i.License != "" {
+		expression := strings.NewReplacer("(", " ", ")", " ").Replace(i.License)
+		terms := strings.Fields(expression)
+		if len(terms) == 0 {
+			return errors.New("plugin license is empty")
+		}
+		afterWith := false
+		for index, term := range terms {
+			isOperator := term == "AND" || term == "OR" || term == "WITH"
+			if (index%2 == 1) != isOperator {
+				return errors.New("invalid plugin license expression: " + i.License)
+			}
+			switch {
+			case isOperator:
+				afterWith = term == "WITH"
+			case afterWith:
+				//License exceptions are not in the embedded list
+				afterWith = false
+			case strings.HasPrefix(term, "LicenseRef-") && len(term) > len("LicenseRef-"):
+			case !IsKnownSPDXLicense(strings.TrimSuffix(term, "+")):
+				return errors.New("unknown SPDX license identifier in plugin license: " + term)
+			}
+		}
+		if last := terms[len(terms)-1]; last == "AND" || last == "OR" || last == "WITH" {
+			return errors.New("invalid plugin license expression: " + i.License)
+		}
+	}
+
+	if i.SourceURL != "" {
+		u, err := url.Parse(i.SourceURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("plugin source URL must be an absolute http(s) URL: " + i.SourceURL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"hash/fnv"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+	Live_reload.go
+
+	This file provides a dev-mode live reload for plugin UIs developed
+	from disk. WatchAndReload polls the directory for changes, debounces
+	them, and pushes a reload event to the connected browsers over a
+	server-sent events endpoint.
+
+	Live reload is only active if DevMode is true (set ZORAXY_PLUGIN_DEV=1),
+	otherwise the watcher is not started and the endpoint responds 404,
+	so it is safe to leave the code in a production build
+
+	Example:
+	reloader, _ := zoraxy_plugin.WatchAndReload("./web")
+	http.Handle("/ui/__livereload", reloader)
+	//Then add reloader.Script("__livereload") to your HTML page
+*/
+
+// DevMode enables the developer features of the SDK, never enable it in production
+var DevMode = os.Getenv("ZORAXY_PLUGIN_DEV") == "1"
+
+const (
+	liveReloadPollInterval = 500 * time.Millisecond
+	liveReloadDebounce     = 300 * time.Millisecond
+)
+
+type LiveReloader struct {
+	dir     string
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// WatchAndReload watches dir for changes and notifies the browsers connected to the returned LiveReloader
+// The watcher is only started in DevMode
+func WatchAndReload(dir string) (*LiveReloader, error) {
+	reloader := &LiveReloader{
+		dir:     dir,
+		clients: map[chan struct{}]struct{}{},
+		stop:    make(chan struct{}),
+	}
+	if !DevMode {
+		return reloader, nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(dir + " is not a directory")
+	}
+	go reloader.watchLoop()
+	return reloader, nil
+}
+
+// Script returns the script tag that reloads the page when the reloader at endpoint fires
+// Returns an empty string if DevMode is disabled
+func (lr *LiveReloader) Script(endpoint string) string {
+	if !DevMode {
+		return ""
+	}
+	return `<script>new EventSource(` + strconv.Quote(endpoint) + `).addEventListener("reload", function(){ location.reload(); });</script>`
+}
+
+// Close stops the watcher and disconnects the browsers
+func (lr *LiveReloader) Close() {
+	lr.once.Do(func() {
+		close(lr.stop)
+	})
+}
+
+// ServeHTTP serves the live reload server-sent events endpoint
+func (lr *LiveReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !DevMode {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	notify := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[notify] = struct{}{}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, notify)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-lr.stop:
+			return
+		case <-notify:
+			if _, err := w.Write([]byte("event: reload\ndata: {}\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (lr *LiveReloader) watchLoop() {
+	ticker := time.NewTicker(liveReloadPollInterval)
+	defer ticker.Stop()
+	lastSnapshot := snapshotDir(lr.dir)
+	var changedAt time.Time
+	for {
+		select {
+		case <-lr.stop:
+			return
+		case now := <-ticker.C:
+			snapshot := snapshotDir(lr.dir)
+			if snapshot != lastSnapshot {
+				//Wait for the editor / build tool to finish writing
+				lastSnapshot = snapshot
+				changedAt = now
+				continue
+			}
+			if !changedAt.IsZero() && now.Sub(changedAt) >= liveReloadDebounce {
+				changedAt = time.Time{}
+				lr.broadcast()
+			}
+		}
+	}
+}
+
+func (lr *LiveReloader) broadcast() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client := range lr.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+			//A reload is already pending for this client
+		}
+	}
+}
+
+// snapshotDir returns a fingerprint of the paths, sizes and modtimes of the files under dir
+func snapshotDir(dir string) uint64 {
+	hash := fnv.New64a()
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		hash.Write([]byte(path + "|" + strconv.FormatInt(info.Size(), 10) + "|" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\n"))
+		return nil
+	})
+	return hash.Sum64()
+}
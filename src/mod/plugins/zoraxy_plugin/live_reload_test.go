@@ -0,0 +1,62 @@
+package zoraxy_plugin
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchAndReload(t *testing.T) {
+	originalDevMode := DevMode
+	defer func() {
+		DevMode = originalDevMode
+	}()
+
+	//Inert outside of dev mode
+	DevMode = false
+	reloader, err := WatchAndReload(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	reloader.ServeHTTP(rec, httptest.NewRequest("GET", "/__livereload", nil))
+	if rec.Code != http.StatusNotFound || reloader.Script("__livereload") != "" {
+		t.Errorf("Expected live reload to be disabled, got %d", rec.Code)
+	}
+
+	DevMode = true
+	dir := t.TempDir()
+	reloader, err = WatchAndReload(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer reloader.Close()
+	server := httptest.NewServer(reloader)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("changed"), 0644)
+	received := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		received <- line
+	}()
+	select {
+	case line := <-received:
+		if !strings.HasPrefix(line, "event: reload") {
+			t.Errorf("Expected a reload event, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a reload event after the change")
+	}
+}
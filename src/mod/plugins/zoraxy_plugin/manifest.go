@@ -0,0 +1,130 @@
+package zoraxy_plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+/*
+	Manifest.go
+
+	This file generates the plugin manifest used for packaging and
+	plugin registries. The manifest is versioned by ManifestVersion,
+	new fields are only added in a backward compatible way.
+
+	Run your plugin with -manifest to print the manifest including
+	the checksum of the plugin binary, e.g.
+	./myplugin -manifest > manifest.json
+*/
+
+const ManifestVersion = 1
+
+type PluginManifest struct {
+	ManifestVersion int             `json:"manifest_version"` //Version of the manifest format
+	ID              string          `json:"id"`               //ID of the plugin
+	Name            string          `json:"name"`             //Name of the plugin
+	Version         string          `json:"version"`          //Version of the plugin in major.minor.patch format
+	Author          string          `json:"author"`           //Author name of the plugin
+	AuthorContact   string          `json:"author_contact"`   //Author contact of the plugin
+	Description     string          `json:"description"`      //Description of the plugin
+	URL             string          `json:"url"`              //URL of the plugin
+	Type            PluginType      `json:"type"`             //Type of the plugin
+	Permissions     []string        `json:"permissions"`      //Permissions required by the plugin
+	Binary          *ManifestBinary `json:"binary,omitempty"` //The plugin binary, omitted if no binary path is given
+	IntroSpect      *IntroSpect     `json:"introspect"`       //The full intro spect of the plugin
+}
+
+type ManifestBinary struct {
+	Filename string `json:"filename"` //File name of the binary
+	Size     int64  `json:"size"`     //Size of the binary in bytes
+	SHA256   string `json:"sha256"`   //Hex encoded SHA-256 checksum of the binary
+	OS       string `json:"os"`       //GOOS the binary was built for
+	Arch     string `json:"arch"`     //GOARCH the binary was built for
+}
+
+// Manifest returns the manifest of the plugin, with the checksum of the binary at binaryPath if it is not empty
+// The OS and Arch of the binary are the ones of the running process
+func (i *IntroSpect) Manifest(binaryPath string) (*PluginManifest, error) {
+	permissions := i.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+	manifest := &PluginManifest{
+		ManifestVersion: ManifestVersion,
+		ID:              i.ID,
+		Name:            i.Name,
+		Version:         i.Version(),
+		Author:          i.Author,
+		AuthorContact:   i.AuthorContact,
+		Description:     i.Description,
+		URL:             i.URL,
+		Type:            i.Type,
+		Permissions:     permissions,
+		IntroSpect:      i,
+	}
+	if binaryPath != "" {
+		binary, err := checksumBinary(binaryPath)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Binary = binary
+	}
+	return manifest, nil
+}
+
+// WriteManifest writes the manifest of the plugin as indented JSON
+func (i *IntroSpect) WriteManifest(w io.Writer) error {
+	return i.WriteManifestWithBinary(w, "")
+}
+
+// WriteManifestWithBinary writes the manifest of the plugin with the checksum of the binary at binaryPath
+func (i *IntroSpect) WriteManifestWithBinary(w io.Writer, binaryPath string) error {
+	manifest, err := i.Manifest(binaryPath)
+	if err != nil {
+		return err
+	}
+	js, err := json.MarshalIndent(manifest, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(js, '\n'))
+	return err
+}
+
+// serveManifest prints the manifest of the running plugin binary for the -manifest mode
+func serveManifest(pluginSpect *IntroSpect) {
+	binaryPath, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to locate the plugin binary: "+err.Error())
+		binaryPath = ""
+	}
+	if err := pluginSpect.WriteManifestWithBinary(os.Stdout, binaryPath); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write the plugin manifest: "+err.Error())
+	}
+}
+
+func checksumBinary(binaryPath string) (*ManifestBinary, error) {
+	f, err := os.Open(binaryPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, err
+	}
+	return &ManifestBinary{
+		Filename: filepath.Base(binaryPath),
+		Size:     size,
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteManifest(t *testing.T) {
+	spec := &IntroSpect{
+		ID:           "org.example.test",
+		Name:         "Test",
+		VersionMajor: 1,
+		VersionMinor: 2,
+		VersionPatch: 3,
+	}
+
+	var buf bytes.Buffer
+	if err := spec.WriteManifest(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var manifest PluginManifest
+	if err := json.Unmarshal(buf.Bytes(), &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	if manifest.ManifestVersion != ManifestVersion || manifest.Version != "1.2.3" || manifest.Binary != nil || manifest.IntroSpect.ID != spec.ID {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+
+	//The output is stable
+	var again bytes.Buffer
+	spec.WriteManifest(&again)
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("Expected the same manifest for the same intro spect")
+	}
+
+	binaryPath := filepath.Join(t.TempDir(), "myplugin")
+	if err := os.WriteFile(binaryPath, []byte("hello"), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	buf.Reset()
+	if err := spec.WriteManifestWithBinary(&buf, binaryPath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	manifest = PluginManifest{}
+	json.Unmarshal(buf.Bytes(), &manifest)
+	expectedHash := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if manifest.Binary == nil || manifest.Binary.SHA256 != expectedHash || manifest.Binary.Size != 5 || manifest.Binary.Filename != "myplugin" {
+		t.Errorf("Unexpected binary entry: %+v", manifest.Binary)
+	}
+
+	if err := spec.WriteManifestWithBinary(&buf, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing binary")
+	}
+}
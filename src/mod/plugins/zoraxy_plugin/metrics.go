@@ -0,0 +1,250 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+	Metrics.go
+
+	This file provides a minimal metrics registry that exports
+	counters and gauges in the Prometheus text format, so every plugin
+	can be scraped the same way at MetricsPath on its loopback port.
+	Every metric is labeled with the plugin ID
+*/
+
+const MetricsPath = "/__metrics"
+
+const (
+	Metric_UIRequestsTotal      = "zoraxy_plugin_ui_requests_total"      //UI requests served by status code
+	Metric_CaptureRequestsTotal = "zoraxy_plugin_capture_requests_total" //Captured requests handled by control status code
+	Metric_UIBytesTotal         = "zoraxy_plugin_ui_bytes_total"         //UI traffic in bytes by direction (in / out)
+	Metric_CaptureBytesTotal    = "zoraxy_plugin_capture_bytes_total"    //Captured request traffic in bytes by direction (in / out)
+	Metric_ConcurrencyRejected  = "zoraxy_plugin_limited_requests_total" //Requests rejected by a ConcurrencyLimiter by handler (ui / capture)
+	Metric_HedgeRequestsTotal   = "zoraxy_plugin_hedge_requests_total"   //Requests handled by the Hedger, see Hedger.RegisterMetrics
+	Metric_HedgedRequestsTotal  = "zoraxy_plugin_hedged_requests_total"  //Requests that sent a hedged request
+	Metric_HedgeWinsTotal       = "zoraxy_plugin_hedge_wins_total"       //Requests answered by the hedged request
+)
+
+type metricType string
+
+const (
+	metricType_Counter metricType = "counter"
+	metricType_Gauge   metricType = "gauge"
+)
+
+type metricFamily struct {
+	name    string
+	help    string
+	mType   metricType
+	values  map[string]float64 //Keyed by the encoded label set
+	valueFn func() float64     //For gauge functions, evaluated on scrape
+}
+
+type MetricsRegistry struct {
+	pluginID string
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// NewMetricsRegistry creates a new MetricsRegistry with the built-in metrics registered
+func NewMetricsRegistry(pluginID string) *MetricsRegistry {
+	m := &MetricsRegistry{
+		pluginID: pluginID,
+		families: map[string]*metricFamily{},
+	}
+	m.RegisterCounter(Metric_UIRequestsTotal, "Number of plugin UI requests by status code")
+	m.RegisterCounter(Metric_CaptureRequestsTotal, "Number of captured requests by control status code")
+	m.RegisterCounter(Metric_UIBytesTotal, "Plugin UI traffic in bytes by direction")
+	m.RegisterCounter(Metric_CaptureBytesTotal, "Captured request traffic in bytes by direction")
+	m.RegisterCounter(Metric_ConcurrencyRejected, "Number of requests rejected by the concurrency limit by handler")
+	return m
+}
+
+// RegisterCounter registers a counter metric, registering an existing name is a no-op
+func (m *MetricsRegistry) RegisterCounter(name string, help string) {
+	m.register(name, help, metricType_Counter, nil)
+}
+
+// RegisterGauge registers a gauge metric, registering an existing name is a no-op
+func (m *MetricsRegistry) RegisterGauge(name string, help string) {
+	m.register(name, help, metricType_Gauge, nil)
+}
+
+// RegisterCounterFunc registers a counter that is evaluated on every scrape, fn must never decrease
+func (m *MetricsRegistry) RegisterCounterFunc(name string, help string, fn func() float64) {
+	m.register(name, help, metricType_Counter, fn)
+}
+
+// RegisterGaugeFunc registers a gauge that is evaluated on every scrape
+// e.g. m.RegisterGaugeFunc("myplugin_hedge_rate", "Hedged request ratio", func() float64 { return hedger.Stats().HedgeRate() })
+func (m *MetricsRegistry) RegisterGaugeFunc(name string, help string, fn func() float64) {
+	m.register(name, help, metricType_Gauge, fn)
+}
+
+// Add adds the value to the counter with the given labels
+func (m *MetricsRegistry) Add(name string, value float64, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	family, ok := m.families[name]
+	if !ok || family.mType != metricType_Counter || family.valueFn != nil || value < 0 {
+		return
+	}
+	family.values[encodeMetricLabels(labels)] += value
+}
+
+// Inc increments the counter with the given labels by one
+func (m *MetricsRegistry) Inc(name string, labels map[string]string) {
+	m.Add(name, 1, labels)
+}
+
+// Set sets the value of the gauge with the given labels
+func (m *MetricsRegistry) Set(name string, value float64, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	family, ok := m.families[name]
+	if !ok || family.mType != metricType_Gauge || family.valueFn != nil {
+		return
+	}
+	family.values[encodeMetricLabels(labels)] = value
+}
+
+// UIMiddleware counts UI requests by response status code and the UI traffic
+func (m *MetricsRegistry) UIMiddleware(next http.Handler) http.Handler {
+	return m.statusCountingMiddleware(Metric_UIRequestsTotal, Metric_UIBytesTotal, next)
+}
+
+// CaptureMiddleware counts captured requests by the control status code returned by the handler and the captured traffic
+func (m *MetricsRegistry) CaptureMiddleware(next http.Handler) http.Handler {
+	return m.statusCountingMiddleware(Metric_CaptureRequestsTotal, Metric_CaptureBytesTotal, next)
+}
+
+// ServeHTTP serves the metrics in Prometheus text format
+func (m *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(m.render()))
+}
+
+func (m *MetricsRegistry) statusCountingMiddleware(name string, bytesName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w}
+		body := &countingReadCloser{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		next.ServeHTTP(sw, r)
+		m.Inc(name, map[string]string{"status": strconv.Itoa(sw.Status())})
+		m.Add(bytesName, float64(body.bytes.Load()), map[string]string{"direction": "in"})
+		m.Add(bytesName, float64(sw.bytes), map[string]string{"direction": "out"})
+	})
+}
+
+// get returns the value of the metric with the given labels
+func (m *MetricsRegistry) get(name string, labels map[string]string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	family, ok := m.families[name]
+	if !ok {
+		return 0
+	}
+	return family.values[encodeMetricLabels(labels)]
+}
+
+func (m *MetricsRegistry) register(name string, help string, mType metricType, fn func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.families[name]; ok {
+		return
+	}
+	m.families[name] = &metricFamily{
+		name:    name,
+		help:    help,
+		mType:   mType,
+		values:  map[string]float64{},
+		valueFn: fn,
+	}
+}
+
+func (m *MetricsRegistry) render() string {
+	m.mu.Lock()
+	families := []*metricFamily{}
+	for _, family := range m.families {
+		families = append(families, family)
+	}
+	m.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	pluginLabel := "plugin_id=\"" + escapeMetricLabelValue(m.pluginID) + "\""
+	var sb strings.Builder
+	for _, family := range families {
+		sb.WriteString("# HELP " + family.name + " " + family.help + "\n")
+		sb.WriteString("# TYPE " + family.name + " " + string(family.mType) + "\n")
+		if family.valueFn != nil {
+			sb.WriteString(family.name + "{" + pluginLabel + "} " + formatMetricValue(family.valueFn()) + "\n")
+			continue
+		}
+
+		m.mu.Lock()
+		labelSets := make([]string, 0, len(family.values))
+		for labelSet := range family.values {
+			labelSets = append(labelSets, labelSet)
+		}
+		sort.Strings(labelSets)
+		for _, labelSet := range labelSets {
+			labels := pluginLabel
+			if labelSet != "" {
+				labels += "," + labelSet
+			}
+			sb.WriteString(family.name + "{" + labels + "} " + formatMetricValue(family.values[labelSet]) + "\n")
+		}
+		m.mu.Unlock()
+	}
+	return sb.String()
+}
+
+// encodeMetricLabels encodes the labels into a sorted Prometheus label list
+func encodeMetricLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		if key == "plugin_id" || !isValidMetricLabelName(key) {
+			//Reserved, always set by the registry, or not representable in the text format
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"=\""+escapeMetricLabelValue(labels[key])+"\"")
+	}
+	return strings.Join(pairs, ",")
+}
+
+// isValidMetricLabelName checks if the name matches [a-zA-Z_][a-zA-Z0-9_]* and is not reserved with __
+func isValidMetricLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for index, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || index > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func escapeMetricLabelValue(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, "\"", "\\\"")
+	return strings.ReplaceAll(value, "\n", "\\n")
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
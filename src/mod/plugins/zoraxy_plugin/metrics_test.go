@@ -0,0 +1,131 @@
+package zoraxy_plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMetricLabels(t *testing.T) {
+	tests := []struct {
+		labels   map[string]string
+		expected string
+	}{
+		{nil, ""},
+		{map[string]string{"status": "200"}, `status="200"`},
+		{map[string]string{"b": "2", "a": "1"}, `a="1",b="2"`},
+		{map[string]string{"path": `C:\dir`}, `path="C:\\dir"`},
+		{map[string]string{"quote": `say "hi"`}, `quote="say \"hi\""`},
+		{map[string]string{"line": "a\nb"}, `line="a\nb"`},
+		//The plugin ID label is set by the registry and cannot be overridden
+		{map[string]string{"plugin_id": "org.example.other", "status": "200"}, `status="200"`},
+		//Invalid label names would break the text format
+		{map[string]string{`a"b`: "x", "a=b": "x", "1st": "x", "": "x", "__name__": "x", "_ok": "1"}, `_ok="1"`},
+	}
+	for _, test := range tests {
+		if got := encodeMetricLabels(test.labels); got != test.expected {
+			t.Errorf("%v: expected %s, got %s", test.labels, test.expected, got)
+		}
+	}
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	m := NewMetricsRegistry(`org.example."test"`)
+	m.RegisterGauge("myplugin_queue_length", "Queue length")
+	m.RegisterGaugeFunc("myplugin_workers", "Running workers", func() float64 { return 4 })
+	m.RegisterCounterFunc("myplugin_jobs_total", "Completed jobs", func() float64 { return 10 })
+	//Registering an existing name does not change its type
+	m.RegisterCounter("myplugin_queue_length", "Queue length")
+
+	m.Set("myplugin_queue_length", 3, map[string]string{"queue": "default"})
+	m.Set("myplugin_queue_length", 1.5, map[string]string{"queue": "default"})
+	m.Add("myplugin_queue_length", 2, map[string]string{"queue": "default"})
+	m.Inc(Metric_UIRequestsTotal, map[string]string{"status": "200", "plugin_id": "spoofed"})
+	m.Add(Metric_UIRequestsTotal, 2, map[string]string{"status": "200"})
+	m.Add(Metric_UIRequestsTotal, -1, map[string]string{"status": "200"})
+	m.Set(Metric_UIRequestsTotal, 100, map[string]string{"status": "200"})
+	m.Set("myplugin_workers", 100, nil)
+	m.Inc("myplugin_unknown_total", nil)
+
+	if got := m.get("myplugin_queue_length", map[string]string{"queue": "default"}); got != 1.5 {
+		t.Errorf("Expected the gauge to be set to 1.5, got %v", got)
+	}
+	if got := m.get(Metric_UIRequestsTotal, map[string]string{"status": "200"}); got != 3 {
+		t.Errorf("Expected the counter to only be incremented, got %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"# HELP myplugin_queue_length Queue length\n# TYPE myplugin_queue_length gauge\n",
+		`myplugin_queue_length{plugin_id="org.example.\"test\"",queue="default"} 1.5`,
+		"# TYPE myplugin_workers gauge\n" + `myplugin_workers{plugin_id="org.example.\"test\""} 4`,
+		"# TYPE myplugin_jobs_total counter\n" + `myplugin_jobs_total{plugin_id="org.example.\"test\""} 10`,
+		`zoraxy_plugin_ui_requests_total{plugin_id="org.example.\"test\"",status="200"} 3`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the metrics to contain %q, got\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, "spoofed") || strings.Contains(body, "myplugin_unknown_total") {
+		t.Errorf("Unexpected metrics output\n%s", body)
+	}
+	//Families are sorted by name
+	if strings.Index(body, "myplugin_jobs_total") > strings.Index(body, "myplugin_queue_length") {
+		t.Errorf("Expected the families to be sorted\n%s", body)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	metrics := NewMetricsRegistry("org.example.test")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ui/", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, "ui")
+	})
+	server := httptest.NewServer(NewServer("", metrics.UIMiddleware(mux), &ServeOptions{Metrics: metrics}).Handler)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/ui/", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + MetricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 from %s, got %d", MetricsPath, resp.StatusCode)
+	}
+	for _, expected := range []string{
+		Metric_UIRequestsTotal + `{plugin_id="org.example.test",status="200"} 1`,
+		Metric_UIBytesTotal + `{plugin_id="org.example.test",direction="in"} 5`,
+		Metric_UIBytesTotal + `{plugin_id="org.example.test",direction="out"} 2`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected the metrics to contain %q, got\n%s", expected, body)
+		}
+	}
+
+	//Without a registry the path is passed to the handler
+	server = httptest.NewServer(NewServer("", mux, nil).Handler)
+	defer server.Close()
+	resp, err = http.Get(server.URL + MetricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 without a metrics registry, got %d", resp.StatusCode)
+	}
+}
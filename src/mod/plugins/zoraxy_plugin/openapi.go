@@ -0,0 +1,50 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+	Openapi.go
+
+	This file serves the OpenAPI spec of the plugin HTTP API so Zoraxy
+	can link to it from the plugin list. Declare the path of the spec
+	relative to UIPath in IntroSpect.OpenAPIPath and mount the spec
+	under the UI path, so it is reachable through the Zoraxy plugin UI proxy
+
+	Example:
+	spec.OpenAPIPath = "/openapi.json"
+	http.Handle(spec.UIPath+spec.OpenAPIPath, ServeOpenAPI(webFs, "www/openapi.json"))
+*/
+
+// ServeOpenAPI returns a handler serving the OpenAPI spec file (JSON or YAML) from the fs
+// The file is read once, a missing file is logged and answered with a 404
+func ServeOpenAPI(specFs fs.FS, name string) http.Handler {
+	content, err := fs.ReadFile(specFs, name)
+	if err != nil {
+		fmt.Println("Failed to read OpenAPI spec " + name + ": " + err.Error())
+		return http.NotFoundHandler()
+	}
+
+	contentType := "application/json"
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		contentType = "application/yaml"
+	}
+	etag := contentETag(content)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+	})
+}
@@ -0,0 +1,31 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeOpenAPI(t *testing.T) {
+	handler := ServeOpenAPI(testWebFs, "testdata/api/openapi.json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || !strings.Contains(rec.Body.String(), `"openapi":"3.0.0"`) {
+		t.Errorf("Expected the OpenAPI spec, got %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/ui/openapi.json", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	ServeOpenAPI(testWebFs, "testdata/api/missing.yaml").ServeHTTP(rec, httptest.NewRequest("GET", "/ui/openapi.yaml", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing spec, got %d", rec.Code)
+	}
+}
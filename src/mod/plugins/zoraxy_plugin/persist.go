@@ -0,0 +1,143 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Persist.go
+
+	This file provides crash safe JSON persistence in the plugin data
+	directory (ConfigureSpec.DataDir). The file is written to a temp
+	file in the same directory and renamed over the target, so readers
+	see either the old or the new content, never a partial write.
+
+	Writers are serialized with a lock file next to the target, which
+	also protects against a second plugin process writing the same file
+
+	Example:
+	var settings MySettings
+	if err := configSpec.LoadJSON("settings.json", &settings); errors.Is(err, fs.ErrNotExist) {
+		settings = defaultSettings
+	}
+	configSpec.SaveJSON("settings.json", &settings)
+*/
+
+const (
+	//The data directory used if Zoraxy does not send one, relative to the plugin working directory
+	DefaultDataDir = "data"
+
+	persistLockTimeout = 5 * time.Second
+	persistLockStale   = 30 * time.Second //Lock files older than this are left over by a crashed writer
+)
+
+// persistMu serializes writers within the plugin process, the lock file handles other processes
+var persistMu sync.Mutex
+
+// SaveJSON atomically writes v as JSON to the named file in the plugin data directory
+func (c *ConfigureSpec) SaveJSON(name string, v any) error {
+	targetPath, err := c.dataFilePath(name)
+	if err != nil {
+		return err
+	}
+	js, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0775); err != nil {
+		return err
+	}
+
+	persistMu.Lock()
+	defer persistMu.Unlock()
+	release, err := acquireFileLock(targetPath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(targetPath), "."+filepath.Base(targetPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	if _, err := tmpFile.Write(js); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	//Flush to disk before the rename, otherwise a crash can leave an empty file behind
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// LoadJSON reads the named file in the plugin data directory into v
+// The returned error wraps fs.ErrNotExist if the file has not been saved yet
+func (c *ConfigureSpec) LoadJSON(name string, v any) error {
+	targetPath, err := c.dataFilePath(name)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(targetPath)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+// dataFilePath returns the path of the named file in the data directory
+// The name must be a plain file name, sub directories and traversal are rejected
+func (c *ConfigureSpec) dataFilePath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") || strings.HasSuffix(name, ".lock") {
+		return "", errors.New("invalid data file name: " + name)
+	}
+	dataDir := c.DataDir
+	if dataDir == "" {
+		dataDir = DefaultDataDir
+	}
+	return filepath.Join(dataDir, name), nil
+}
+
+// acquireFileLock creates the lock file exclusively, waiting for other writers up to persistLockTimeout
+func acquireFileLock(lockPath string) (release func(), err error) {
+	deadline := time.Now().Add(persistLockTimeout)
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			lockFile.Close()
+			return func() {
+				os.Remove(lockPath)
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > persistLockStale {
+			//Left over by a writer that crashed while holding the lock
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("timeout waiting for lock " + lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
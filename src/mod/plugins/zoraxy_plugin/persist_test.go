@@ -0,0 +1,61 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadJSON(t *testing.T) {
+	type settings struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	spec := &ConfigureSpec{DataDir: filepath.Join(t.TempDir(), "data")}
+
+	var loaded settings
+	if err := spec.LoadJSON("settings.json", &loaded); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist before saving, got %v", err)
+	}
+
+	//Concurrent writers do not corrupt the file
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := spec.SaveJSON("settings.json", &settings{Name: "test", Count: i}); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := spec.LoadJSON("settings.json", &loaded); err != nil || loaded.Name != "test" {
+		t.Errorf("Expected the saved settings, got %+v (%v)", loaded, err)
+	}
+
+	//No temp or lock files are left behind
+	entries, _ := os.ReadDir(spec.DataDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the settings file in the data dir, got %d entries", len(entries))
+	}
+
+	//A stale lock left by a crashed writer is recovered
+	lockPath := filepath.Join(spec.DataDir, "settings.json.lock")
+	os.WriteFile(lockPath, nil, 0644)
+	staleTime := time.Now().Add(-time.Hour)
+	os.Chtimes(lockPath, staleTime, staleTime)
+	if err := spec.SaveJSON("settings.json", &settings{Name: "recovered"}); err != nil {
+		t.Errorf("Expected the stale lock to be recovered, got %v", err)
+	}
+
+	for _, name := range []string{"", "..", "../escape.json", "sub/settings.json", "settings.json.lock"} {
+		if err := spec.SaveJSON(name, &settings{}); err == nil {
+			t.Errorf("Expected an error for the file name %q", name)
+		}
+	}
+}
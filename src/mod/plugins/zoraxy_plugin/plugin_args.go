@@ -0,0 +1,75 @@
+package zoraxy_plugin
+
+import (
+	"os"
+	"strings"
+)
+
+/*
+	Plugin_args.go
+
+	This file separates the SDK flags from the flags of the plugin itself.
+
+	The SDK reserves the following flags, do not declare them in your plugin:
+	- -introspect, -introspect-compact and -manifest
+	- -configure={json}, -configure=- and the split form -configure {json}
+	- every flag starting with -zoraxy- (e.g. -zoraxy-simulate-capture),
+	  new SDK flags are only added under this namespace
+
+	Example:
+	fs := flag.NewFlagSet("myplugin", flag.ExitOnError)
+	debug := fs.Bool("debug", false, "Enable debug mode")
+	fs.Parse(ParsePluginArgs())
+*/
+
+const SDKFlagPrefix = "-zoraxy-"
+
+// sdkFlags are the reserved flags that predate the -zoraxy- namespace
+var sdkFlags = []string{"-introspect", "-introspect-compact", "-manifest", "-configure"}
+
+// IsSDKFlag checks if the argument is a flag reserved by the SDK
+func IsSDKFlag(arg string) bool {
+	name := strings.TrimLeft(arg, "-")
+	name = "-" + strings.SplitN(name, "=", 2)[0]
+	if strings.HasPrefix(name, SDKFlagPrefix) {
+		return true
+	}
+	for _, flag := range sdkFlags {
+		if name == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePluginArgs returns the arguments of the plugin (os.Args[1:]) with the SDK flags removed
+// Pass the result to the Parse function of your own flag.FlagSet
+func ParsePluginArgs() []string {
+	return filterPluginArgs(os.Args[1:])
+}
+
+func filterPluginArgs(args []string) []string {
+	pluginArgs := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			//Everything after the terminator belongs to the plugin
+			return append(pluginArgs, args[i:]...)
+		}
+		if !IsSDKFlag(arg) {
+			pluginArgs = append(pluginArgs, arg)
+			continue
+		}
+		name := "-" + strings.TrimLeft(arg, "-")
+		if name == SimulateCaptureFlag && i+1 < len(args) {
+			//Skip the value of the split form -zoraxy-simulate-capture <url>
+			i++
+		} else if name == "-configure" && i+1 < len(args) {
+			//Skip the payload of the split form -configure {json}, including the chunks of an unquoted payload
+			i += configureArgLength(args[i+1:])
+		} else if value, ok := strings.CutPrefix(name, "-configure="); ok {
+			i += configureArgLength(append([]string{value}, args[i+1:]...)) - 1
+		}
+	}
+	return pluginArgs
+}
@@ -0,0 +1,38 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	Protocol_version.go
+
+	This file defines the version of the wire protocol between Zoraxy
+	and plugins (the argv flags and the JSON shape of the intro spect
+	and configure spec). It is independent from the version of the plugin.
+
+	The plugin reports the version in its intro spect and Zoraxy sends
+	its own in the configure spec, each side rejects a version it does
+	not speak. A zero version means the peer predates versioning and is
+	treated as version 1
+*/
+
+// ProtocolVersion is the version of the plugin wire protocol implemented by this SDK
+// Only bump this on breaking changes, backward compatible fields do not need a new version
+const ProtocolVersion = 1
+
+// ErrProtocolMismatch is returned when the peer speaks an incompatible protocol version
+var ErrProtocolMismatch = errors.New("plugin protocol version mismatch")
+
+// CheckProtocolVersion checks if the protocol version of the peer is compatible with this SDK
+func CheckProtocolVersion(peerVersion int) error {
+	if peerVersion == 0 {
+		//Peer predates protocol versioning
+		peerVersion = 1
+	}
+	if peerVersion != ProtocolVersion {
+		return fmt.Errorf("%w: peer speaks version %d, this SDK speaks version %d", ErrProtocolMismatch, peerVersion, ProtocolVersion)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package zoraxy_plugin
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Ratelimit.go
+
+	This file provides a token bucket rate limiter keyed by
+	an arbitrary string (usually the client IP) for router plugins.
+
+	Memory usage is bounded by MaxKeys, when the limit is reached
+	the least recently used key is evicted. Keys that are idle for
+	longer than IdleTimeout are removed by a background GC routine
+*/
+
+const (
+	defaultRateLimiterMaxKeys     = 10000
+	defaultRateLimiterIdleTimeout = 10 * time.Minute
+)
+
+type TokenBucketLimiter struct {
+	RatePerSec  float64       //Number of tokens refilled per second
+	Burst       int           //Max number of tokens a bucket can hold
+	MaxKeys     int           //Max number of keys tracked at the same time, LRU evicted when exceeded
+	IdleTimeout time.Duration //Idle duration after which a key is removed
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List //Front is most recently used
+	stopGC  chan struct{}
+}
+
+type tokenBucket struct {
+	key        string
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewTokenBucketLimiter creates a new token bucket rate limiter
+// and starts the background GC of idle keys. Call Stop to end the GC routine
+func NewTokenBucketLimiter(ratePerSec float64, burst int) *TokenBucketLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	limiter := &TokenBucketLimiter{
+		RatePerSec:  ratePerSec,
+		Burst:       burst,
+		MaxKeys:     defaultRateLimiterMaxKeys,
+		IdleTimeout: defaultRateLimiterIdleTimeout,
+		buckets:     map[string]*list.Element{},
+		lru:         list.New(),
+		stopGC:      make(chan struct{}),
+	}
+	go limiter.gcLoop()
+	return limiter
+}
+
+// Allow consumes a token from the bucket of the given key
+// and returns true if the request is allowed
+func (l *TokenBucketLimiter) Allow(key string) bool {
+	allowed, _ := l.allow(key)
+	return allowed
+}
+
+// allow is Allow that also returns the time until the next token is available if the request is rejected
+func (l *TokenBucketLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var bucket *tokenBucket
+	if elem, ok := l.buckets[key]; ok {
+		bucket = elem.Value.(*tokenBucket)
+		l.lru.MoveToFront(elem)
+		elapsed := now.Sub(bucket.lastRefill).Seconds()
+		bucket.tokens += elapsed * l.RatePerSec
+		if bucket.tokens > float64(l.Burst) {
+			bucket.tokens = float64(l.Burst)
+		}
+		bucket.lastRefill = now
+	} else {
+		//New key, evict the least recently used keys if the cap is reached
+		for l.MaxKeys > 0 && l.lru.Len() >= l.MaxKeys {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*tokenBucket).key)
+		}
+		bucket = &tokenBucket{
+			key:        key,
+			tokens:     float64(l.Burst),
+			lastRefill: now,
+		}
+		l.buckets[key] = l.lru.PushFront(bucket)
+	}
+
+	if bucket.tokens < 1 {
+		if l.RatePerSec <= 0 {
+			return false, 0
+		}
+		return false, time.Duration((1 - bucket.tokens) / l.RatePerSec * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// Stop stops the background GC routine of the limiter
+func (l *TokenBucketLimiter) Stop() {
+	select {
+	case <-l.stopGC:
+	default:
+		close(l.stopGC)
+	}
+}
+
+// Middleware returns a http middleware that rate limits requests by the client IP
+// Requests exceeding the limit are rejected with 429 Too Many Requests and a Retry-After
+// header with the seconds until the next token is available
+func (l *TokenBucketLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := GetClientIP(r)
+		if addr, err := ParseClientIP(r); err == nil {
+			//Use the canonical form so different notations of the same IPv6 address share a bucket
+			key = addr.String()
+		}
+		if allowed, wait := l.allow(key); !allowed {
+			if wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			}
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *TokenBucketLimiter) gcLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stopGC:
+			return
+		case <-ticker.C:
+			l.removeIdleKeys()
+		}
+	}
+}
+
+func (l *TokenBucketLimiter) removeIdleKeys() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	deadline := time.Now().Add(-l.IdleTimeout)
+	//The list is ordered by last use, walk from the oldest entry
+	for elem := l.lru.Back(); elem != nil; {
+		bucket := elem.Value.(*tokenBucket)
+		if bucket.lastRefill.After(deadline) {
+			break
+		}
+		prev := elem.Prev()
+		l.lru.Remove(elem)
+		delete(l.buckets, bucket.key)
+		elem = prev
+	}
+}
+
+// GetClientIP returns the IP of the client that sent the request to Zoraxy
+// The X-Zoraxy-Client-IP header is preferred, followed by the X-Real-Ip header
+// set by the Zoraxy proxy core. The connection remote address is used as fallback
+func GetClientIP(r *http.Request) string {
+	for _, header := range []string{"X-Zoraxy-Client-IP", "X-Real-Ip"} {
+		if clientIP := strings.TrimSpace(r.Header.Get(header)); clientIP != "" {
+			return clientIP
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
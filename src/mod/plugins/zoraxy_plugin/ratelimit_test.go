@@ -0,0 +1,131 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rewindBucket moves the last refill of the key back as if d had passed
+func rewindBucket(l *TokenBucketLimiter, key string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket := l.buckets[key].Value.(*tokenBucket)
+	bucket.lastRefill = bucket.lastRefill.Add(-d)
+}
+
+func TestTokenBucketLimiterBurstAndRefill(t *testing.T) {
+	limiter := NewTokenBucketLimiter(2, 3)
+	defer limiter.Stop()
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a") {
+			t.Fatalf("Expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	if limiter.Allow("a") {
+		t.Fatal("Expected the request over the burst to be rejected")
+	}
+	if !limiter.Allow("b") {
+		t.Error("Expected other keys to have their own bucket")
+	}
+
+	//2 tokens per second, half a second refills one token
+	rewindBucket(limiter, "a", 500*time.Millisecond)
+	if !limiter.Allow("a") {
+		t.Error("Expected a refilled token to be allowed")
+	}
+	if limiter.Allow("a") {
+		t.Error("Expected only one token to be refilled")
+	}
+
+	//The refill is capped at the burst
+	rewindBucket(limiter, "a", time.Hour)
+	allowed := 0
+	for limiter.Allow("a") {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Errorf("Expected the refill to be capped at the burst of 3, got %d", allowed)
+	}
+}
+
+func TestTokenBucketLimiterMaxKeys(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 1)
+	defer limiter.Stop()
+	limiter.MaxKeys = 2
+
+	limiter.Allow("a")
+	limiter.Allow("b")
+	//Using a again makes b the least recently used key
+	limiter.Allow("a")
+	limiter.Allow("c")
+
+	if _, ok := limiter.buckets["b"]; ok {
+		t.Error("Expected the least recently used key to be evicted")
+	}
+	if _, ok := limiter.buckets["a"]; !ok {
+		t.Error("Expected the recently used key to be kept")
+	}
+	if len(limiter.buckets) != 2 || limiter.lru.Len() != 2 {
+		t.Errorf("Expected 2 tracked keys, got %d / %d", len(limiter.buckets), limiter.lru.Len())
+	}
+	//An evicted key starts over with a full bucket
+	if !limiter.Allow("b") {
+		t.Error("Expected the evicted key to start with a full bucket")
+	}
+}
+
+func TestTokenBucketLimiterGC(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 1)
+	defer limiter.Stop()
+	limiter.IdleTimeout = time.Minute
+
+	limiter.Allow("idle")
+	limiter.Allow("active")
+	rewindBucket(limiter, "idle", 2*time.Minute)
+	limiter.removeIdleKeys()
+
+	if _, ok := limiter.buckets["idle"]; ok {
+		t.Error("Expected the idle key to be removed")
+	}
+	if _, ok := limiter.buckets["active"]; !ok {
+		t.Error("Expected the active key to be kept")
+	}
+	if limiter.lru.Len() != 1 {
+		t.Errorf("Expected 1 tracked key, got %d", limiter.lru.Len())
+	}
+}
+
+func TestTokenBucketLimiterMiddleware(t *testing.T) {
+	limiter := NewTokenBucketLimiter(0.5, 1)
+	defer limiter.Stop()
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(clientIP string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Zoraxy-Client-IP", clientIP)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("2001:db8::1"); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected the first request to pass, got %d", rec.Code)
+	}
+	//Another notation of the same IPv6 address shares the bucket
+	rec := request("2001:0db8:0000::0001")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rec.Code)
+	}
+	//0.5 tokens per second, the next token is 2 seconds away
+	if rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", rec.Header().Get("Retry-After"))
+	}
+	if rec := request("192.0.2.1"); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected another client to pass, got %d", rec.Code)
+	}
+}
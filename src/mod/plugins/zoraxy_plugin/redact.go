@@ -0,0 +1,96 @@
+package zoraxy_plugin
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+	Redact.go
+
+	This file masks secret values (e.g. API keys entered in the plugin
+	settings) before they reach the plugin logs, which Zoraxy forwards
+	to the operators. Wrap the log output in a RedactingWriter and
+	register the secrets once the ConfigureSpec is received, settings
+	fields marked as Secret in the schema can be registered at once
+
+	Example:
+	redactor := zoraxy_plugin.NewRedactingWriter(os.Stdout)
+	redactor.AddSecrets(pluginSpec.SecretSettings(configureSpec.Options)...)
+	log.SetOutput(redactor)
+	uiRouter.WithAccessLog(redactor, zoraxy_plugin.AccessLogFormat_Combined)
+*/
+
+// RedactedPlaceholder replaces the secret values in redacted strings
+const RedactedPlaceholder = "[REDACTED]"
+
+// RedactSecrets replaces every occurrence of the secrets in s with RedactedPlaceholder, empty secrets are ignored
+func RedactSecrets(s string, secrets []string) string {
+	//Replace longer secrets first so a secret containing another one is not partially revealed
+	sorted := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret != "" {
+			sorted = append(sorted, secret)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, secret := range sorted {
+		s = strings.ReplaceAll(s, secret, RedactedPlaceholder)
+	}
+	return s
+}
+
+// RedactingWriter redacts the registered secrets from everything written through it
+// Every Write is redacted on its own, which matches the one write per line of log.Logger and fmt.Println
+type RedactingWriter struct {
+	mu      sync.RWMutex
+	out     io.Writer
+	secrets []string
+}
+
+// NewRedactingWriter creates a RedactingWriter writing to out
+func NewRedactingWriter(out io.Writer, secrets ...string) *RedactingWriter {
+	w := &RedactingWriter{out: out}
+	w.AddSecrets(secrets...)
+	return w
+}
+
+// AddSecrets registers values to be redacted from the following writes
+func (w *RedactingWriter) AddSecrets(secrets ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, secret := range secrets {
+		if secret != "" {
+			w.secrets = append(w.secrets, secret)
+		}
+	}
+}
+
+// Write writes p with the secrets redacted, the length of p is returned on success so callers do not see a short write
+func (w *RedactingWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	secrets := w.secrets
+	w.mu.RUnlock()
+	if len(secrets) == 0 {
+		return w.out.Write(p)
+	}
+	if _, err := io.WriteString(w.out, RedactSecrets(string(p), secrets)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// SecretSettings returns the values of the options whose settings schema field is marked as Secret
+func (i *IntroSpect) SecretSettings(options map[string]string) []string {
+	secrets := []string{}
+	for _, field := range i.SettingsSchema {
+		if field.Secret && options[field.Key] != "" {
+			secrets = append(secrets, options[field.Key])
+		}
+	}
+	return secrets
+}
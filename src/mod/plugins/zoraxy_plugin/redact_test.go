@@ -0,0 +1,39 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		input    string
+		secrets  []string
+		expected string
+	}{
+		{"token=abc123 ok", []string{"abc123"}, "token=[REDACTED] ok"},
+		{"key sk-live-1 and sk-live-1", []string{"sk-live-1"}, "key [REDACTED] and [REDACTED]"},
+		{"nested secret-long", []string{"secret", "secret-long"}, "nested [REDACTED]"},
+		{"nothing to hide", []string{""}, "nothing to hide"},
+	}
+	for _, test := range tests {
+		if got := RedactSecrets(test.input, test.secrets); got != test.expected {
+			t.Errorf("RedactSecrets(%q, %v): expected %q, got %q", test.input, test.secrets, test.expected, got)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	redactor := NewRedactingWriter(out)
+	logger := log.New(redactor, "", 0)
+	logger.Println("before apikey-1")
+	spec := IntroSpect{SettingsSchema: []SettingField{
+		{Key: "api_key", Type: SettingFieldType_String, Secret: true},
+		{Key: "region", Type: SettingFieldType_String},
+	}}
+	redactor.AddSecrets(spec.SecretSettings(map[string]string{"api_key": "apikey-1", "region": "eu"})...)
+	logger.Println("after apikey-1 in eu")
+	if out.String() != "before apikey-1\nafter [REDACTED] in eu\n" {
+		t.Errorf("Unexpected redacted log %q", out.String())
+	}
+}
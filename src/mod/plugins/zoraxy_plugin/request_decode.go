@@ -0,0 +1,174 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Request_decode.go
+
+	This file decodes query and form parameters into a struct by the
+	zoraxy struct tag, so plugin handlers do not parse and validate every
+	parameter by hand. Supported field types are string, bool, the int,
+	uint and float types, time.Duration (e.g. 5s) and slices of them for
+	repeated parameters. Fields without a zoraxy tag are left untouched.
+
+	All problems are collected in a single *DecodeError, which lists the
+	failed fields so the handler can return them at once, e.g. with
+	WriteJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+
+	Example:
+	var params struct {
+		Name    string        `zoraxy:"name,required"`
+		Limit   int           `zoraxy:"limit"`
+		Timeout time.Duration `zoraxy:"timeout"`
+		Tags    []string      `zoraxy:"tag"`
+	}
+	if err := zoraxy_plugin.DecodeQuery(r, &params); err != nil {
+		zoraxy_plugin.WriteJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+*/
+
+const decodeTagName = "zoraxy"
+
+// FieldError describes a parameter that could not be decoded
+type FieldError struct {
+	Field   string `json:"field"`   //Name of the parameter
+	Message string `json:"message"` //Why the parameter was rejected
+}
+
+// DecodeError aggregates the errors of all parameters of a DecodeQuery / DecodeForm call
+type DecodeError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *DecodeError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return "invalid parameters: " + strings.Join(messages, "; ")
+}
+
+// DecodeQuery decodes the URL query parameters of the request into dst, which must be a pointer to a struct
+func DecodeQuery(r *http.Request, dst any) error {
+	return DecodeValues(r.URL.Query(), dst)
+}
+
+// DecodeForm decodes the form body (urlencoded or multipart) of the request into dst, which must be a pointer to a struct
+// Query parameters are not included, use DecodeQuery for them
+func DecodeForm(r *http.Request, dst any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return err
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return err
+	}
+	return DecodeValues(r.PostForm, dst)
+}
+
+// DecodeValues decodes the values into dst, which must be a pointer to a struct
+func DecodeValues(values url.Values, dst any) error {
+	target := reflect.ValueOf(dst)
+	if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return errors.New("decode target must be a non nil pointer to a struct")
+	}
+	target = target.Elem()
+
+	decodeErr := &DecodeError{}
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		tag, ok := field.Tag.Lookup(decodeTagName)
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		required := options == "required"
+
+		fieldValues, present := values[name]
+		if !present || len(fieldValues) == 0 || (len(fieldValues) == 1 && fieldValues[0] == "" && required) {
+			if required {
+				decodeErr.Fields = append(decodeErr.Fields, FieldError{Field: name, Message: "is required"})
+			}
+			continue
+		}
+		if err := setDecodedField(target.Field(i), fieldValues); err != nil {
+			decodeErr.Fields = append(decodeErr.Fields, FieldError{Field: name, Message: err.Error()})
+		}
+	}
+	if len(decodeErr.Fields) > 0 {
+		return decodeErr
+	}
+	return nil
+}
+
+// setDecodedField converts the values to the type of the field, slices take every value and other types the first one
+func setDecodedField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setDecodedValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setDecodedValue(field, values[0])
+}
+
+func setDecodedValue(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("is not a valid duration (e.g. 5s)")
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("is not a valid boolean")
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid integer")
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid unsigned integer")
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid number")
+		}
+		field.SetFloat(parsed)
+	default:
+		return errors.New("has an unsupported field type " + field.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDecodeParams struct {
+	Name    string        `zoraxy:"name,required"`
+	Limit   int           `zoraxy:"limit"`
+	Ratio   float64       `zoraxy:"ratio"`
+	Enabled bool          `zoraxy:"enabled"`
+	Timeout time.Duration `zoraxy:"timeout"`
+	Tags    []string      `zoraxy:"tag"`
+	Ports   []uint16      `zoraxy:"port"`
+	Ignored string
+}
+
+func TestDecodeQuery(t *testing.T) {
+	params := testDecodeParams{Limit: 10, Ignored: "kept"}
+	req := httptest.NewRequest("GET", "/api?name=demo&ratio=0.5&enabled=true&timeout=5s&tag=a&tag=b&port=80&port=443&Ignored=x", nil)
+	if err := DecodeQuery(req, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Name != "demo" || params.Limit != 10 || params.Ratio != 0.5 || !params.Enabled || params.Timeout != 5*time.Second ||
+		strings.Join(params.Tags, ",") != "a,b" || len(params.Ports) != 2 || params.Ports[1] != 443 || params.Ignored != "kept" {
+		t.Errorf("Unexpected decoded params %+v", params)
+	}
+
+	//All errors are reported at once
+	req = httptest.NewRequest("GET", "/api?limit=ten&port=70000&timeout=5", nil)
+	err := DecodeQuery(req, &testDecodeParams{})
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || len(decodeErr.Fields) != 4 {
+		t.Fatalf("Expected 4 field errors, got %v", err)
+	}
+	for _, field := range []string{"name", "limit", "timeout", "port"} {
+		if !strings.Contains(err.Error(), field+": ") {
+			t.Errorf("Expected an error for %s, got %v", field, err)
+		}
+	}
+
+	if err := DecodeQuery(req, testDecodeParams{}); err == nil || errors.As(err, &decodeErr) {
+		t.Errorf("Expected an error for a non pointer target, got %v", err)
+	}
+}
+
+func TestDecodeForm(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api?name=query", strings.NewReader("name=form&limit=3"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	params := testDecodeParams{}
+	if err := DecodeForm(req, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Name != "form" || params.Limit != 3 {
+		t.Errorf("Expected the form values, got %+v", params)
+	}
+
+	req = httptest.NewRequest("POST", "/api?name=query", strings.NewReader("name="))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := DecodeForm(req, &testDecodeParams{}); err == nil {
+		t.Error("Expected an empty required field to be rejected")
+	}
+}
@@ -0,0 +1,81 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+	Request_id.go
+
+	This file handles the X-Zoraxy-Request-ID header used to correlate
+	a captured request across Zoraxy and plugin logs
+
+	Example:
+	http.Handle("/d_handler", zoraxy_plugin.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := zoraxy_plugin.DynamicCaptureRequest{Request: r}
+		zoraxy_plugin.Logf(r, "handling %s", req.URL.Path) //Printed as [<request id>] handling /path
+	})))
+*/
+
+const RequestIDHeader = "X-Zoraxy-Request-ID"
+
+type requestIDContextKey struct{}
+
+// DynamicCaptureRequest is a request forwarded by Zoraxy to a capture ingress
+type DynamicCaptureRequest struct {
+	*http.Request
+}
+
+// RequestID returns the request ID of the captured request, see RequestID
+func (req DynamicCaptureRequest) RequestID() string {
+	return RequestID(req.Request)
+}
+
+// RequestIDMiddleware reads the request ID sent by Zoraxy (or generates one if absent),
+// stores it in the request context and echoes it back in the response headers
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := strings.TrimSpace(r.Header.Get(RequestIDHeader))
+		if requestID == "" || len(requestID) > 128 {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDContextKey{}, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestID returns the request ID of the request
+// If the request did not pass through RequestIDMiddleware, the header value is returned
+func RequestID(r *http.Request) string {
+	if requestID, ok := r.Context().Value(requestIDContextKey{}).(string); ok {
+		return requestID
+	}
+	return strings.TrimSpace(r.Header.Get(RequestIDHeader))
+}
+
+// Logf prints a log line prefixed with the request ID of the request to STDOUT (the Zoraxy log)
+func Logf(r *http.Request, format string, args ...any) {
+	fmt.Println(logPrefix(r) + fmt.Sprintf(format, args...))
+}
+
+// logPrefix returns "[<request id>] " or an empty string if the request has no ID
+func logPrefix(r *http.Request) string {
+	if requestID := RequestID(r); requestID != "" {
+		return "[" + requestID + "] "
+	}
+	return ""
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
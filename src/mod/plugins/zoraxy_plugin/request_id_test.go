@@ -0,0 +1,69 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var received string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = DynamicCaptureRequest{Request: r}.RequestID()
+	}))
+
+	tests := []struct {
+		name      string
+		header    string
+		propagate bool
+	}{
+		{"incoming id", "abc-123", true},
+		{"incoming id with spaces", "  abc-123  ", true},
+		{"missing header", "", false},
+		{"oversized header", strings.Repeat("a", 129), false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/d_handler", nil)
+		if test.header != "" {
+			req.Header.Set(RequestIDHeader, test.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		echoed := rec.Header().Get(RequestIDHeader)
+		if echoed != received {
+			t.Errorf("%s: expected the response header %q to match the request ID %q", test.name, echoed, received)
+		}
+		if test.propagate && received != strings.TrimSpace(test.header) {
+			t.Errorf("%s: expected the incoming ID to be kept, got %q", test.name, received)
+		}
+		if !test.propagate && len(received) != 32 {
+			t.Errorf("%s: expected a generated 32 character ID, got %q", test.name, received)
+		}
+	}
+}
+
+func TestRequestIDFallback(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if RequestID(req) != "" || logPrefix(req) != "" {
+		t.Error("Expected no request ID without the header")
+	}
+	req.Header.Set(RequestIDHeader, "abc-123")
+	if RequestID(req) != "abc-123" || logPrefix(req) != "[abc-123] " {
+		t.Errorf("Expected the header value outside of the middleware, got %q", RequestID(req))
+	}
+}
+
+func TestAccessLogRequestID(t *testing.T) {
+	var logs strings.Builder
+	router := newTestUiRouter().WithAccessLog(&logs, AccessLogFormat_JSON)
+	handler := RequestIDMiddleware(router.accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !strings.Contains(logs.String(), `"request_id":"abc-123"`) {
+		t.Errorf("Expected the request ID in the access log, got %q", logs.String())
+	}
+}
@@ -0,0 +1,145 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*
+	Response_filter.go
+
+	This file implements the response filter protocol, for plugins that
+	modify the upstream response instead of capturing the request
+	(e.g. inject a banner or rewrite links).
+
+	1. A capture handler (global, always or dynamic sniff) answers
+	   ControlStatusCode_FILTER (288) with an empty body. Zoraxy continues
+	   handling the request and fetches the upstream response
+	2. Zoraxy sends the upstream response as a POST request to the
+	   ResponseFilterIngress of the plugin:
+	   - the body is the upstream response body, decoded (no Content-Encoding)
+	   - the headers are the upstream response headers
+	   - X-Zoraxy-Upstream-Status: the status code of the upstream response
+	   - X-Zoraxy-Request-Method / -URI / -Host: the original client request
+	3. The plugin answers either
+	   - ControlStatusCode_CAPTURED with the X-Zoraxy-Status-Code header:
+	     the status code, headers and body replace the upstream response
+	     (see DynamicCaptureResponse)
+	   - ControlStatusCode_UNHANDLED: the upstream response is sent unchanged
+
+	Buffering vs streaming
+	HandleResponseFilterFunc buffers the whole upstream body (up to
+	maxBodySize) before calling the filter. This is the simplest model and
+	required for transformations that need the complete document (e.g.
+	parsing HTML), but it adds the full upstream latency and memory per
+	request. Larger bodies are passed through unchanged. A plugin can stream
+	instead by reading ResponseFilterRequest.Body and writing the CAPTURED
+	response as it goes, which keeps the memory constant but cannot fall
+	back to UNHANDLED once the first byte is written. Only declare the
+	filter for responses that need it, e.g. check the Content-Type in the
+	filter and answer UNHANDLED for everything else
+
+	Example:
+	http.Handle("/filter", zoraxy_plugin.HandleResponseFilterFunc(func(upstream *zoraxy_plugin.ResponseFilterRequest, body []byte) (*zoraxy_plugin.DynamicCaptureResponse, error) {
+		if !strings.HasPrefix(upstream.Header.Get("Content-Type"), "text/html") {
+			return nil, nil
+		}
+		resp := zoraxy_plugin.NewDynamicCaptureResponse(upstream.StatusCode, bytes.Replace(body, []byte("<body>"), []byte("<body>"+banner), 1))
+		resp.Header = upstream.Header
+		return resp, nil
+	}, 4<<20))
+*/
+
+const (
+	ControlStatusCode_FILTER ControlStatusCode = 288 //Ask Zoraxy to process the traffic and send the upstream response to the ResponseFilterIngress
+)
+
+const (
+	FilterHeader_UpstreamStatus = "X-Zoraxy-Upstream-Status"
+	FilterHeader_RequestMethod  = "X-Zoraxy-Request-Method"
+	FilterHeader_RequestURI     = "X-Zoraxy-Request-URI"
+	FilterHeader_RequestHost    = "X-Zoraxy-Request-Host"
+
+	DefaultResponseFilterMaxBodySize = 4 << 20 //4MB
+)
+
+// ResponseFilterRequest is the upstream response Zoraxy sends to the ResponseFilterIngress
+type ResponseFilterRequest struct {
+	StatusCode int           //Status code of the upstream response
+	Header     http.Header   //Headers of the upstream response, without the X-Zoraxy-* protocol headers
+	Body       io.ReadCloser //Body of the upstream response, read it to stream the filtered response
+	Method     string        //Method of the original client request
+	RequestURI string        //Request URI of the original client request
+	Host       string        //Host of the original client request
+}
+
+// WriteFilterDirective asks Zoraxy to continue handling the request and send the upstream response to the ResponseFilterIngress
+func WriteFilterDirective(w http.ResponseWriter) {
+	w.WriteHeader(int(ControlStatusCode_FILTER))
+}
+
+// ParseResponseFilterRequest parses the upstream response sent by Zoraxy to the ResponseFilterIngress
+func ParseResponseFilterRequest(r *http.Request) (*ResponseFilterRequest, error) {
+	if r.Method != http.MethodPost {
+		return nil, errors.New("response filter request must be a POST request")
+	}
+	statusCode, err := strconv.Atoi(r.Header.Get(FilterHeader_UpstreamStatus))
+	if err != nil || statusCode < 100 || statusCode > 999 {
+		return nil, errors.New("response filter request has an invalid " + FilterHeader_UpstreamStatus + " header")
+	}
+	header := http.Header{}
+	for key, values := range r.Header {
+		if strings.HasPrefix(key, "X-Zoraxy-") {
+			continue
+		}
+		header[key] = values
+	}
+	return &ResponseFilterRequest{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       r.Body,
+		Method:     r.Header.Get(FilterHeader_RequestMethod),
+		RequestURI: r.Header.Get(FilterHeader_RequestURI),
+		Host:       r.Header.Get(FilterHeader_RequestHost),
+	}, nil
+}
+
+// HandleResponseFilterFunc adapts a buffered response filter into a http.Handler for the ResponseFilterIngress
+// The filter returns the replacement response, or nil to send the upstream response unchanged.
+// Bodies larger than maxBodySize (default DefaultResponseFilterMaxBodySize) are sent unchanged without calling the filter
+func HandleResponseFilterFunc(filter func(upstream *ResponseFilterRequest, body []byte) (*DynamicCaptureResponse, error), maxBodySize int64) http.Handler {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultResponseFilterMaxBodySize
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream, err := ParseResponseFilterRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(upstream.Body, maxBodySize+1))
+		if err != nil {
+			w.WriteHeader(int(ControlStatusCode_UNHANDLED))
+			return
+		}
+		if int64(len(body)) > maxBodySize {
+			//Too large to buffer, send the upstream response unchanged
+			w.WriteHeader(int(ControlStatusCode_UNHANDLED))
+			return
+		}
+		resp, err := filter(upstream, body)
+		if err != nil {
+			//Zoraxy logs the error of filters answering ControlStatusCode_ERROR
+			w.WriteHeader(int(ControlStatusCode_ERROR))
+			return
+		}
+		if resp == nil {
+			w.WriteHeader(int(ControlStatusCode_UNHANDLED))
+			return
+		}
+		resp.Write(w)
+	})
+}
@@ -0,0 +1,70 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestFilterRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/filter", strings.NewReader(body))
+	req.Header.Set(FilterHeader_UpstreamStatus, "200")
+	req.Header.Set(FilterHeader_RequestMethod, "GET")
+	req.Header.Set(FilterHeader_RequestURI, "/index.html")
+	req.Header.Set(FilterHeader_RequestHost, "example.com")
+	req.Header.Set("Content-Type", "text/html")
+	req.Header.Set("X-Upstream", "1")
+	return req
+}
+
+func TestResponseFilter(t *testing.T) {
+	handler := HandleResponseFilterFunc(func(upstream *ResponseFilterRequest, body []byte) (*DynamicCaptureResponse, error) {
+		if upstream.Header.Get(FilterHeader_UpstreamStatus) != "" || upstream.Host != "example.com" || upstream.RequestURI != "/index.html" {
+			t.Errorf("Unexpected filter request %+v", upstream)
+		}
+		if !strings.HasPrefix(upstream.Header.Get("Content-Type"), "text/html") {
+			return nil, nil
+		}
+		resp := NewDynamicCaptureResponse(upstream.StatusCode, bytes.Replace(body, []byte("<body>"), []byte("<body><div>banner</div>"), 1))
+		resp.Header = upstream.Header
+		return resp, nil
+	}, 32)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newTestFilterRequest("<body>hello</body>"))
+	if rec.Code != int(ControlStatusCode_CAPTURED) || rec.Header().Get(DirectiveHeader_StatusCode) != "200" || rec.Body.String() != "<body><div>banner</div>hello</body>" {
+		t.Errorf("Expected the filtered response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Upstream") != "1" {
+		t.Error("Expected the upstream headers to be kept")
+	}
+
+	//Non HTML responses and bodies over the limit are sent unchanged
+	req := newTestFilterRequest("{}")
+	req.Header.Set("Content-Type", "application/json")
+	for _, req := range []*http.Request{req, newTestFilterRequest(strings.Repeat("a", 64))} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != int(ControlStatusCode_UNHANDLED) || rec.Body.Len() != 0 {
+			t.Errorf("Expected UNHANDLED, got %d %q", rec.Code, rec.Body.String())
+		}
+	}
+
+	req = newTestFilterRequest("")
+	req.Header.Del(FilterHeader_UpstreamStatus)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without the upstream status, got %d", rec.Code)
+	}
+}
+
+func TestWriteFilterDirective(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteFilterDirective(rec)
+	if rec.Code != int(ControlStatusCode_FILTER) {
+		t.Errorf("Expected the FILTER status code, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package zoraxy_plugin
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+	Retry_client.go
+
+	This file provides a HTTP client for outbound calls of plugins
+	(e.g. certificate providers or webhooks) that retries failed requests
+	with exponential backoff and jitter.
+
+	A request is retried on network errors and on 429 / 502 / 503 / 504,
+	the Retry-After header of the response is honored (capped at MaxDelay).
+	Only idempotent methods are retried unless RetryNonIdempotent is set,
+	and requests with a body are only retried if the body can be replayed
+	(i.e. http.NewRequest with a bytes / strings reader)
+
+	Example:
+	client := NewRetryClient(RetryOptions{MaxAttempts: 5})
+	resp, err := client.Get("https://api.example.com/status")
+*/
+
+type RetryOptions struct {
+	MaxAttempts        int                                       //Max number of attempts including the first one, default 3
+	BaseDelay          time.Duration                             //Delay before the first retry, doubled on every retry, default 200ms
+	MaxDelay           time.Duration                             //Max delay between attempts, default 10s
+	Timeout            time.Duration                             //Timeout of the whole call including retries, 0 for no timeout
+	RetryNonIdempotent bool                                      //Also retry POST / PATCH requests
+	ShouldRetry        func(resp *http.Response, err error) bool //Decides if an attempt is retried, default retries network errors and 429 / 502 / 503 / 504
+	Transport          http.RoundTripper                         //Transport used for the requests, default http.DefaultTransport
+	Logger             func(message string)                      //Logs the retried attempts, default prints to STDOUT (the Zoraxy log)
+}
+
+// RetryTransport is a http.RoundTripper that retries failed requests, see NewRetryClient
+type RetryTransport struct {
+	options RetryOptions
+}
+
+// NewRetryClient creates a http.Client that retries failed requests with exponential backoff
+func NewRetryClient(opts RetryOptions) *http.Client {
+	return &http.Client{
+		Transport: NewRetryTransport(opts),
+		Timeout:   opts.Timeout,
+	}
+}
+
+// NewRetryTransport creates a RetryTransport with the defaults applied to the options
+func NewRetryTransport(opts RetryOptions) *RetryTransport {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 200 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * time.Second
+	}
+	if opts.ShouldRetry == nil {
+		opts.ShouldRetry = DefaultShouldRetry
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.Logger == nil {
+		opts.Logger = func(message string) {
+			fmt.Println(message)
+		}
+	}
+	return &RetryTransport{options: opts}
+}
+
+// DefaultShouldRetry retries network errors and 429 / 502 / 503 / 504 responses
+func DefaultShouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RoundTrip sends the request, retrying it according to the options
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := t.canRetry(req)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.options.Transport.RoundTrip(req)
+		if !retryable || attempt >= t.options.MaxAttempts || !t.options.ShouldRetry(resp, err) {
+			return resp, err
+		}
+		if req.Context().Err() != nil {
+			//The caller gave up, do not mask the response with a context error
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			//Drain the body so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+		t.options.Logger("[retry] " + req.Method + " " + req.URL.Redacted() + " attempt " + strconv.Itoa(attempt) + " failed (" + reason + "), retrying in " + delay.String())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// canRetry checks if the request can be sent again
+func (t *RetryTransport) canRetry(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		//The body cannot be replayed
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return t.options.RetryNonIdempotent
+}
+
+// backoff returns the delay before the next attempt
+func (t *RetryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(delay, t.options.MaxDelay)
+		}
+	}
+	delay := t.options.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > t.options.MaxDelay {
+		delay = t.options.MaxDelay
+	}
+	//Jitter in [delay/2, delay] so clients failing together do not retry together
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter parses the Retry-After header in either seconds or HTTP date form
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
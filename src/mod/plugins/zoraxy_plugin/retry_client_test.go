@@ -0,0 +1,81 @@
+package zoraxy_plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryClient(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	logs := []string{}
+	client := NewRetryClient(RetryOptions{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Logger: func(message string) {
+			logs = append(logs, message)
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("payload"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "payload" || attempts.Load() != 3 || len(logs) != 2 {
+		t.Errorf("Expected success with the replayed body on the third attempt, got %d %q after %d attempts (%d logs)", resp.StatusCode, body, attempts.Load(), len(logs))
+	}
+
+	//The last response is returned once the attempts are exhausted
+	attempts.Store(-10)
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts.Load() != -7 {
+		t.Errorf("Expected 503 after 3 attempts, got %d after %d attempts", resp.StatusCode, attempts.Load()+10)
+	}
+
+	//Non idempotent requests are not retried by default
+	attempts.Store(0)
+	resp, err = client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts.Load() != 1 {
+		t.Errorf("Expected a single POST attempt, got %d attempts", attempts.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if delay, ok := parseRetryAfter("5"); !ok || delay != 5*time.Second {
+		t.Errorf("Expected 5s, got %v %v", delay, ok)
+	}
+	if delay, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || delay < 59*time.Minute {
+		t.Errorf("Expected about one hour, got %v %v", delay, ok)
+	}
+	for _, value := range []string{"", "-1", "soon"} {
+		if _, ok := parseRetryAfter(value); ok {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+/*
+	Schema.go
+
+	This file generates JSON Schema documents from the IntroSpect
+	and ConfigureSpec struct definitions, so tools written in other
+	languages can validate plugin introspect output and configure payloads
+*/
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// IntroSpectSchema returns the JSON Schema of the IntroSpect payload
+func IntroSpectSchema() []byte {
+	return generateJSONSchema("IntroSpect", reflect.TypeOf(IntroSpect{}), []string{"id", "name", "author", "description", "ui_path"})
+}
+
+// ConfigureSpecSchema returns the JSON Schema of the ConfigureSpec payload
+func ConfigureSpecSchema() []byte {
+	return generateJSONSchema("ConfigureSpec", reflect.TypeOf(ConfigureSpec{}), []string{"port"})
+}
+
+func generateJSONSchema(title string, t reflect.Type, required []string) []byte {
+	schema := typeToJSONSchema(t)
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = title
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	js, _ := json.MarshalIndent(schema, "", " ")
+	return js
+}
+
+func typeToJSONSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeToJSONSchema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeToJSONSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, ok := jsonFieldName(field)
+			if !ok {
+				continue
+			}
+			properties[name] = typeToJSONSchema(field.Type)
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+	}
+
+	//Unknown types (e.g. interface) accept any value
+	return map[string]interface{}{}
+}
+
+// jsonFieldName returns the JSON name of the struct field, ok is false if the field is not serialized
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
@@ -0,0 +1,88 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+/*
+	Self_update.go
+
+	This file implements the plugin side of the binary update handshake.
+	Zoraxy stages the new plugin binary next to the running one and POSTs
+	its path to the update endpoint of the plugin (<UIPath>/update):
+
+	{"binary_path": "/opt/zoraxy/plugins/myplugin/myplugin.new"}
+
+	The update handler validates the staged binary (e.g. checks a signature
+	or migrates the data directory) and returns nil once the plugin is ready
+	to be swapped. Zoraxy then terminates the plugin through the terminate
+	handler and starts the new binary. An error rejects the update and the
+	running plugin is kept
+
+	Example:
+	uiRouter.RegisterUpdateHandler(func(newBinaryPath string) error {
+		return verifySignature(newBinaryPath)
+	}, nil)
+*/
+
+// UpdateRequest is the payload Zoraxy sends to the update endpoint
+type UpdateRequest struct {
+	BinaryPath string `json:"binary_path"` //Absolute path of the staged plugin binary
+}
+
+// Version returns the version of the plugin in major.minor.patch format
+func (i *IntroSpect) Version() string {
+	return strconv.Itoa(i.VersionMajor) + "." + strconv.Itoa(i.VersionMinor) + "." + strconv.Itoa(i.VersionPatch)
+}
+
+// RegisterUpdateHandler registers the update handler for the PluginUiRouter
+// Zoraxy will POST the path of the staged binary to the update endpoint, the plugin
+// is reported as ready to be swapped if the handler returns nil, requests not signed by Zoraxy are rejected
+// if mux is nil, the handler will be registered to http.DefaultServeMux
+func (p *PluginUiRouter) RegisterUpdateHandler(updateFunc func(newBinaryPath string) error, mux *http.ServeMux) {
+	p.updateHandler = updateFunc
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(p.HandlerPrefix+"/update", RequireZoraxy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req UpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid update request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkStagedBinary(req.BinaryPath); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := p.updateHandler(req.BinaryPath); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+}
+
+// checkStagedBinary checks that the staged binary is an existing regular file
+func checkStagedBinary(binaryPath string) error {
+	if binaryPath == "" {
+		return errors.New("staged binary path is empty")
+	}
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return errors.New("staged binary not found: " + binaryPath)
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("staged binary is not a regular file: " + binaryPath)
+	}
+	return nil
+}
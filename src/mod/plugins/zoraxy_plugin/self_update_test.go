@@ -0,0 +1,74 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisterUpdateHandler(t *testing.T) {
+	stagedBinary := filepath.Join(t.TempDir(), "plugin.new")
+	os.WriteFile(stagedBinary, []byte("binary"), 0755)
+
+	reject := false
+	var staged string
+	mux := http.NewServeMux()
+	newTestUiRouter().RegisterUpdateHandler(func(newBinaryPath string) error {
+		staged = newBinaryPath
+		if reject {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}, mux)
+
+	tests := []struct {
+		method string
+		body   string
+		reject bool
+		status int
+	}{
+		{"POST", `{"binary_path":"` + filepath.ToSlash(stagedBinary) + `"}`, false, http.StatusOK},
+		{"POST", `{"binary_path":"` + filepath.ToSlash(stagedBinary) + `"}`, true, http.StatusConflict},
+		{"POST", `{"binary_path":"/does/not/exist"}`, false, http.StatusBadRequest},
+		{"POST", `not json`, false, http.StatusBadRequest},
+		{"GET", ``, false, http.StatusMethodNotAllowed},
+	}
+	setZoraxyEventSecret("secret")
+	t.Cleanup(func() { setZoraxyEventSecret("") })
+	for _, test := range tests {
+		reject = test.reject
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, newSignedTestRequest(test.method, "/ui/update", test.body, "secret"))
+		if rec.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.body, test.status, rec.Code)
+		}
+	}
+	if staged != filepath.ToSlash(stagedBinary) {
+		t.Errorf("Expected the staged binary path to be passed to the handler, got %q", staged)
+	}
+
+	//Unsigned update requests are rejected, with or without an EventSecret
+	body := `{"binary_path":"` + filepath.ToSlash(stagedBinary) + `"}`
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/ui/update", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned update request to be rejected, got %d", rec.Code)
+	}
+	setZoraxyEventSecret("")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/ui/update", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected update requests to be rejected without an EventSecret, got %d", rec.Code)
+	}
+}
+
+func TestIntroSpectVersion(t *testing.T) {
+	spec := &IntroSpect{VersionMajor: 1, VersionMinor: 12, VersionPatch: 3}
+	if spec.Version() != "1.12.3" {
+		t.Errorf("Expected 1.12.3, got %s", spec.Version())
+	}
+}
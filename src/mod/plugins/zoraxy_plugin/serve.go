@@ -0,0 +1,211 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+/*
+	Serve.go
+
+	This file provides helpers to start the plugin web server
+	with sane timeout defaults. Even though plugins only listen on
+	loopback, a server without timeouts can be exhausted by slow clients.
+
+	ListenAndServeWithOptions registers the server with the worker
+	lifecycle, so on a terminate request from Zoraxy (or on SIGTERM /
+	SIGINT if HandleSignals was called) it stops accepting new connections
+	and lets in-flight requests complete within the ShutdownGracePeriod
+*/
+
+const (
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+
+	//Zoraxy kills the plugin 5 seconds after requesting it to stop
+	DefaultShutdownGracePeriod = 5 * time.Second
+)
+
+// ServeOptions defines the timeouts of the plugin web server
+// Zero values are replaced by the defaults and negative values disable the timeout
+// (e.g. set WriteTimeout to -1 if your plugin serves long-lived streaming responses)
+type ServeOptions struct {
+	ReadTimeout       time.Duration //Max duration for reading the entire request, default 15s
+	ReadHeaderTimeout time.Duration //Max duration for reading the request headers, default 10s
+	WriteTimeout      time.Duration //Max duration before timing out writes of the response, default 30s
+	IdleTimeout       time.Duration //Max duration to wait for the next request on keep-alive connections, default 60s
+
+	ShutdownGracePeriod time.Duration //Max duration to wait for in-flight requests on shutdown, default 5s, negative to close immediately
+
+	Metrics   *MetricsRegistry //If set, the metrics are served at MetricsPath in Prometheus text format
+	EnableH2C bool             //Serve HTTP/2 cleartext (h2c) in addition to HTTP/1.1, e.g. for gRPC-web on the same port
+	TLSPolicy *TLSPolicy       //TLS policy applied by ListenAndServeTLSWithOptions, nil for the secure defaults
+}
+
+// NewServer creates a http.Server listening on addr with the given options applied
+// If handler is nil, http.DefaultServeMux is used
+func NewServer(addr string, handler http.Handler, options *ServeOptions) *http.Server {
+	opts := ServeOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Metrics != nil {
+		handler = withMetricsEndpoint(handler, opts.Metrics)
+	}
+	idleTimeout := timeoutOrDefault(opts.IdleTimeout, DefaultIdleTimeout)
+	if opts.EnableH2C {
+		if handler == nil {
+			handler = http.DefaultServeMux
+		}
+		//Accepts both prior knowledge HTTP/2 and the HTTP/1.1 Upgrade: h2c handshake
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: idleTimeout})
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       timeoutOrDefault(opts.ReadTimeout, DefaultReadTimeout),
+		ReadHeaderTimeout: timeoutOrDefault(opts.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		WriteTimeout:      timeoutOrDefault(opts.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// ListenAndServeWithOptions starts the plugin web server with the given options
+// This is a drop-in replacement of http.ListenAndServe with timeouts applied and graceful shutdown
+// When the server is shut down, it returns nil after the in-flight requests are drained
+func ListenAndServeWithOptions(addr string, handler http.Handler, options *ServeOptions) error {
+	return serveWithOptions(NewServer(addr, handler, options), options, func(server *http.Server) error {
+		return server.ListenAndServe()
+	})
+}
+
+// ListenAndServeTLSWithOptions is the TLS version of ListenAndServeWithOptions
+// The TLSPolicy of the options (e.g. IntroSpect.TLSPolicy) sets the minimum TLS version and the cipher suites,
+// an invalid policy is returned as error before the server starts listening
+// Policies without an AES_128_GCM_SHA256 suite are served over HTTP/1.1 only, as required by HTTP/2
+func ListenAndServeTLSWithOptions(addr string, certFile string, keyFile string, handler http.Handler, options *ServeOptions) error {
+	var policy *TLSPolicy
+	if options != nil {
+		policy = options.TLSPolicy
+	}
+	tlsConfig, err := policy.TLSConfig()
+	if err != nil {
+		return err
+	}
+	server := NewServer(addr, handler, options)
+	server.TLSConfig = tlsConfig
+	if !allowsHTTP2(tlsConfig) {
+		//HTTP/2 refuses to start without an AES_128_GCM_SHA256 suite, serve HTTP/1.1 only instead
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	return serveWithOptions(server, options, func(server *http.Server) error {
+		return server.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// allowsHTTP2 checks if the cipher suites of the config include one required by HTTP/2
+func allowsHTTP2(tlsConfig *tls.Config) bool {
+	if len(tlsConfig.CipherSuites) == 0 || tlsConfig.MinVersion >= tls.VersionTLS13 {
+		return true
+	}
+	for _, suite := range tlsConfig.CipherSuites {
+		if suite == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || suite == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return true
+		}
+	}
+	return false
+}
+
+// serveWithOptions runs the server with listen and registers it with the worker lifecycle
+func serveWithOptions(server *http.Server, options *ServeOptions, listen func(server *http.Server) error) error {
+	gracePeriod := DefaultShutdownGracePeriod
+	if options != nil && options.ShutdownGracePeriod != 0 {
+		gracePeriod = options.ShutdownGracePeriod
+	}
+	worker := &serverWorker{
+		server:      server,
+		gracePeriod: gracePeriod,
+		done:        make(chan struct{}),
+	}
+	addStartedWorker(worker)
+	err := listen(worker.server)
+	if errors.Is(err, http.ErrServerClosed) {
+		//Wait for the shutdown to finish so the caller does not exit mid drain
+		<-worker.done
+		return nil
+	}
+	//The server failed to start (e.g. the port is in use), it must not be stopped with the workers
+	removeStartedWorker(worker)
+	return err
+}
+
+// serverWorker adapts a running http.Server to the Worker lifecycle
+type serverWorker struct {
+	server      *http.Server
+	gracePeriod time.Duration
+	done        chan struct{}
+	stopOnce    sync.Once
+	stopErr     error
+}
+
+func (s *serverWorker) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops accepting new connections and waits for in-flight requests
+// until the grace period or the context expires, remaining connections are closed
+// Only the first call shuts the server down, later calls return the same result
+func (s *serverWorker) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		defer close(s.done)
+		s.stopErr = s.shutdown(ctx)
+	})
+	return s.stopErr
+}
+
+func (s *serverWorker) shutdown(ctx context.Context) error {
+	if s.gracePeriod < 0 {
+		return s.server.Close()
+	}
+	shutdownCtx, cancel := context.WithTimeout(ctx, s.gracePeriod)
+	defer cancel()
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		s.server.Close()
+		return err
+	}
+	return nil
+}
+
+func timeoutOrDefault(value time.Duration, defaultValue time.Duration) time.Duration {
+	if value < 0 {
+		//Disabled
+		return 0
+	}
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// withMetricsEndpoint serves the metrics registry at MetricsPath and passes other requests to handler
+func withMetricsEndpoint(handler http.Handler, metrics *MetricsRegistry) http.Handler {
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == MetricsPath {
+			metrics.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
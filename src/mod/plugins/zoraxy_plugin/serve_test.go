@@ -0,0 +1,151 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func TestListenAndServeGracefulShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	requestStarted := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- ListenAndServeWithOptions(addr, mux, &ServeOptions{ShutdownGracePeriod: 2 * time.Second})
+	}()
+
+	//Wait for the server to listen
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not start: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			body <- "error: " + err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		content, _ := io.ReadAll(resp.Body)
+		body <- string(content)
+	}()
+	<-requestStarted
+
+	if err := StopWorkers(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := <-body; got != "done" {
+		t.Errorf("Expected the in-flight request to complete, got %q", got)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Expected nil after a graceful shutdown, got %v", err)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("Expected the server to stop accepting connections")
+	}
+}
+
+func TestListenAndServeFailedStart(t *testing.T) {
+	//Occupy the port so the plugin server fails to start
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer listener.Close()
+
+	if err := ListenAndServeWithOptions(listener.Addr().String(), http.NewServeMux(), nil); err == nil {
+		t.Fatal("Expected an error for an occupied port")
+	}
+	workerState.mu.Lock()
+	startedWorkers := len(workerState.started)
+	workerState.mu.Unlock()
+	if startedWorkers != 0 {
+		t.Errorf("Expected the failed server to be removed from the workers, got %d", startedWorkers)
+	}
+}
+
+func TestServerWorkerStopTwice(t *testing.T) {
+	worker := &serverWorker{server: NewServer("127.0.0.1:0", http.NewServeMux(), nil), done: make(chan struct{})}
+	if err := worker.Stop(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	//A second Stop (e.g. terminate request and SIGTERM) must not panic on the closed channel
+	if err := worker.Stop(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	<-worker.done
+}
+
+func TestServeH2C(t *testing.T) {
+	protoHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	for _, enabled := range []bool{true, false} {
+		server := httptest.NewServer(NewServer("", protoHandler, &ServeOptions{EnableH2C: enabled}).Handler)
+		client := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}}
+		resp, err := client.Get(server.URL)
+		if enabled {
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			proto, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(proto) != "HTTP/2.0" {
+				t.Errorf("Expected HTTP/2.0 with h2c enabled, got %q", proto)
+			}
+		} else if err == nil {
+			resp.Body.Close()
+			t.Error("Expected prior knowledge HTTP/2 to fail without h2c")
+		}
+
+		//HTTP/1.1 keeps working
+		resp, err = http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		proto, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(proto) != "HTTP/1.1" {
+			t.Errorf("Expected HTTP/1.1, got %q", proto)
+		}
+		server.Close()
+	}
+}
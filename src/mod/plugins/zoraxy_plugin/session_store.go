@@ -0,0 +1,223 @@
+package zoraxy_plugin
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+	Session_store.go
+
+	This file provides a small in-memory session store for plugin UIs.
+	Sessions expire after the store TTL of inactivity, and the number
+	of sessions is capped by MaxSessions (the oldest session is evicted
+	when the cap is reached). Sessions do not survive a plugin restart.
+
+	Only SessionStore.Get (and GetFromRequest) count as activity and
+	extend the expiry of a session, reading or writing its values with
+	Session.Get / Session.Set does not. Look the session up through the
+	store on every request to keep active sessions alive
+*/
+
+const (
+	DefaultSessionCookieName = "zoraxy_plugin_session"
+	defaultMaxSessions       = 1024
+)
+
+type Session struct {
+	ID        string
+	CreatedAt time.Time
+
+	mu        sync.RWMutex
+	values    map[string]string
+	expiresAt time.Time
+}
+
+type SessionStore struct {
+	TTL         time.Duration //Idle duration before a session expires
+	MaxSessions int           //Max number of sessions kept in memory, default 1024
+	CookieName  string        //Name of the session cookie, default zoraxy_plugin_session
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	stop     chan struct{}
+}
+
+// NewSessionStore creates a new SessionStore and starts the background expiry routine
+// Call Close to stop the background routine
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = 30 * time.Minute
+	}
+	store := &SessionStore{
+		TTL:         ttl,
+		MaxSessions: defaultMaxSessions,
+		CookieName:  DefaultSessionCookieName,
+		sessions:    map[string]*Session{},
+		stop:        make(chan struct{}),
+	}
+	go store.expiryLoop()
+	return store
+}
+
+// Create creates a new session, evicting the session closest to expiry if MaxSessions is reached
+func (s *SessionStore) Create() (*Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &Session{
+		ID:        base64.RawURLEncoding.EncodeToString(buf),
+		CreatedAt: now,
+		values:    map[string]string{},
+		expiresAt: now.Add(s.TTL),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.MaxSessions > 0 && len(s.sessions) >= s.MaxSessions {
+		s.evictOldestLocked()
+	}
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+// Get returns the session with the given ID and extends its expiry
+func (s *SessionStore) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	if session.expired(time.Now()) {
+		delete(s.sessions, id)
+		return nil, errors.New("session expired")
+	}
+	session.touch(s.TTL)
+	return session, nil
+}
+
+// Delete removes the session with the given ID
+func (s *SessionStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// Close stops the background expiry routine
+func (s *SessionStore) Close() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+}
+
+// SetCookie writes the session cookie to the response
+func (s *SessionStore) SetCookie(w http.ResponseWriter, session *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.CookieName,
+		Value:    session.ID,
+		Path:     "/",
+		MaxAge:   int(s.TTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCookie removes the session cookie from the client
+func (s *SessionStore) ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// GetFromRequest returns the session referenced by the session cookie of the request
+func (s *SessionStore) GetFromRequest(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(s.CookieName)
+	if err != nil {
+		return nil, errors.New("session cookie not found")
+	}
+	return s.Get(cookie.Value)
+}
+
+// Get returns the value stored in the session
+// It does not extend the expiry of the session, see SessionStore.Get
+func (s *Session) Get(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Set stores a value in the session
+// It does not extend the expiry of the session, see SessionStore.Get
+func (s *Session) Set(key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// Remove deletes a value from the session
+func (s *Session) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+func (s *Session) touch(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiresAt = time.Now().Add(ttl)
+}
+
+func (s *Session) expired(now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return now.After(s.expiresAt)
+}
+
+func (s *SessionStore) evictOldestLocked() {
+	var oldestID string
+	var oldestExpiry time.Time
+	for id, session := range s.sessions {
+		session.mu.RLock()
+		expiresAt := session.expiresAt
+		session.mu.RUnlock()
+		if oldestID == "" || expiresAt.Before(oldestExpiry) {
+			oldestID = id
+			oldestExpiry = expiresAt
+		}
+	}
+	delete(s.sessions, oldestID)
+}
+
+func (s *SessionStore) expiryLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, session := range s.sessions {
+				if session.expired(now) {
+					delete(s.sessions, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
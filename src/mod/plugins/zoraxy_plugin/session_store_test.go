@@ -0,0 +1,145 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// expireSession moves the expiry of the session back by d
+func expireSession(session *Session, d time.Duration) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.expiresAt = session.expiresAt.Add(-d)
+}
+
+func TestSessionStoreTTL(t *testing.T) {
+	store := NewSessionStore(time.Minute)
+	defer store.Close()
+
+	session, err := store.Create()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	session.Set("user", "admin")
+
+	//Get extends the expiry of the session
+	expireSession(session, 50*time.Second)
+	if _, err := store.Get(session.ID); err != nil {
+		t.Fatalf("Expected the session to be valid, got %v", err)
+	}
+	if session.expired(time.Now().Add(30 * time.Second)) {
+		t.Error("Expected Get to extend the expiry")
+	}
+
+	//Reading and writing values does not
+	expireSession(session, 50*time.Second)
+	session.Get("user")
+	session.Set("user", "guest")
+	if !session.expired(time.Now().Add(30 * time.Second)) {
+		t.Error("Expected Session.Get and Session.Set not to extend the expiry")
+	}
+
+	expireSession(session, time.Minute)
+	if _, err := store.Get(session.ID); err == nil {
+		t.Error("Expected the expired session to be rejected")
+	}
+	if _, ok := store.sessions[session.ID]; ok {
+		t.Error("Expected the expired session to be removed")
+	}
+	if _, err := store.Get("unknown"); err == nil {
+		t.Error("Expected an unknown session to be rejected")
+	}
+}
+
+func TestSessionStoreMaxSessions(t *testing.T) {
+	store := NewSessionStore(time.Minute)
+	defer store.Close()
+	store.MaxSessions = 2
+
+	first, _ := store.Create()
+	second, _ := store.Create()
+	expireSession(first, 2*time.Second)
+	expireSession(second, time.Second)
+	//Using the first session makes the second one the oldest
+	store.Get(first.ID)
+	third, _ := store.Create()
+
+	if len(store.sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(store.sessions))
+	}
+	if _, err := store.Get(second.ID); err == nil {
+		t.Error("Expected the oldest session to be evicted")
+	}
+	if _, err := store.Get(first.ID); err != nil {
+		t.Errorf("Expected the recently used session to be kept, got %v", err)
+	}
+	if _, err := store.Get(third.ID); err != nil {
+		t.Errorf("Expected the new session to be kept, got %v", err)
+	}
+}
+
+func TestSessionStoreCookie(t *testing.T) {
+	store := NewSessionStore(time.Hour)
+	defer store.Close()
+	session, _ := store.Create()
+
+	rec := httptest.NewRecorder()
+	store.SetCookie(rec, session)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected one cookie, got %d", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != DefaultSessionCookieName || cookie.Value != session.ID || cookie.Path != "/" || cookie.MaxAge != 3600 || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Unexpected session cookie %+v", cookie)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/", nil)
+	req.AddCookie(cookie)
+	if found, err := store.GetFromRequest(req); err != nil || found != session {
+		t.Errorf("Expected the session from the cookie, got %v", err)
+	}
+	if _, err := store.GetFromRequest(httptest.NewRequest(http.MethodGet, "/ui/", nil)); err == nil {
+		t.Error("Expected an error without the session cookie")
+	}
+
+	rec = httptest.NewRecorder()
+	store.ClearCookie(rec)
+	cookie = rec.Result().Cookies()[0]
+	if cookie.Name != DefaultSessionCookieName || cookie.Value != "" || cookie.MaxAge != -1 || !cookie.HttpOnly {
+		t.Errorf("Unexpected cleared cookie %+v", cookie)
+	}
+}
+
+func TestSessionStoreConcurrent(t *testing.T) {
+	store := NewSessionStore(time.Minute)
+	defer store.Close()
+	store.MaxSessions = 16
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				session, err := store.Create()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				session.Set("key", "value")
+				if found, err := store.Get(session.ID); err == nil {
+					found.Get("key")
+				}
+				store.Delete(session.ID)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(store.sessions) > store.MaxSessions {
+		t.Errorf("Expected at most %d sessions, got %d", store.MaxSessions, len(store.sessions))
+	}
+}
@@ -0,0 +1,164 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+	Settings_schema.go
+
+	This file defines the settings schema a plugin can declare in
+	IntroSpect.SettingsSchema. Zoraxy renders a standard settings form
+	from the schema, validates the submitted values and passes them to
+	the plugin in ConfigureSpec.Options, so simple plugins do not need
+	to build a settings UI of their own
+
+	Example:
+	spec, err := NewIntroSpect("com.example.myplugin", "My Plugin").
+		WithSettings(
+			zoraxy_plugin.SettingField{Key: "greeting", Label: "Greeting", Type: zoraxy_plugin.SettingFieldType_String, Default: "Hello", Pattern: "^[A-Za-z ]+$"},
+			zoraxy_plugin.SettingField{Key: "mode", Label: "Mode", Type: zoraxy_plugin.SettingFieldType_Select, Options: []string{"fast", "safe"}, Default: "safe"},
+		).
+		Build()
+
+	greeting := configureSpec.GetString("greeting", "Hello")
+*/
+
+type SettingFieldType string
+
+const (
+	SettingFieldType_String SettingFieldType = "string" //Free text, optionally restricted by Pattern
+	SettingFieldType_Bool   SettingFieldType = "bool"   //Checkbox, stored as true or false
+	SettingFieldType_Int    SettingFieldType = "int"    //Integer input, optionally bounded by Min and Max
+	SettingFieldType_Select SettingFieldType = "select" //Dropdown of the values in Options
+)
+
+type SettingField struct {
+	Key         string           `json:"key"`                   //Key of the value in ConfigureSpec.Options
+	Label       string           `json:"label"`                 //Label shown in the settings form
+	Description string           `json:"description,omitempty"` //Help text shown below the input
+	Type        SettingFieldType `json:"type"`                  //Input type, one of the SettingFieldType_* constants
+	Default     string           `json:"default,omitempty"`     //Value used when the user has not set one
+	Required    bool             `json:"required,omitempty"`    //Reject empty values
+	Options     []string         `json:"options,omitempty"`     //Allowed values of a select field
+	Pattern     string           `json:"pattern,omitempty"`     //Regular expression a string value must fully match
+	Min         *int             `json:"min,omitempty"`         //Minimum value of an int field
+	Max         *int             `json:"max,omitempty"`         //Maximum value of an int field
+	Secret      bool             `json:"secret,omitempty"`      //Mask the input and the value in logs, see SecretSettings
+}
+
+// Validate checks if the field declaration itself is valid
+func (f *SettingField) Validate() error {
+	if f.Key == "" {
+		return errors.New("plugin setting key is empty")
+	}
+	switch f.Type {
+	case SettingFieldType_String, SettingFieldType_Bool, SettingFieldType_Int:
+	case SettingFieldType_Select:
+		if len(f.Options) == 0 {
+			return errors.New("plugin setting " + f.Key + " is a select field without options")
+		}
+	default:
+		return errors.New("plugin setting " + f.Key + " has an unknown type: " + string(f.Type))
+	}
+	if f.Pattern != "" {
+		if f.Type != SettingFieldType_String {
+			return errors.New("plugin setting " + f.Key + " has a pattern but is not a string field")
+		}
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return fmt.Errorf("plugin setting %s has an invalid pattern: %w", f.Key, err)
+		}
+	}
+	if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+		return errors.New("plugin setting " + f.Key + " has a min larger than its max")
+	}
+	if f.Default != "" {
+		if err := f.ValidateValue(f.Default); err != nil {
+			return fmt.Errorf("plugin setting %s has an invalid default: %w", f.Key, err)
+		}
+	}
+	return nil
+}
+
+// ValidateValue checks if the value is accepted by the field
+func (f *SettingField) ValidateValue(value string) error {
+	if value == "" {
+		if f.Required {
+			return errors.New("value is required")
+		}
+		return nil
+	}
+	switch f.Type {
+	case SettingFieldType_Bool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("value must be true or false")
+		}
+	case SettingFieldType_Int:
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return errors.New("value must be an integer")
+		}
+		if f.Min != nil && parsed < *f.Min {
+			return fmt.Errorf("value must be at least %d", *f.Min)
+		}
+		if f.Max != nil && parsed > *f.Max {
+			return fmt.Errorf("value must be at most %d", *f.Max)
+		}
+	case SettingFieldType_Select:
+		if !slices.Contains(f.Options, value) {
+			return errors.New("value must be one of " + strings.Join(f.Options, ", "))
+		}
+	case SettingFieldType_String:
+		if f.Pattern != "" {
+			pattern, err := regexp.Compile("^(?:" + f.Pattern + ")$")
+			if err != nil || !pattern.MatchString(value) {
+				return errors.New("value does not match the pattern " + f.Pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSettingsSchema checks every field declaration and rejects duplicated keys
+func (i *IntroSpect) validateSettingsSchema() error {
+	keys := map[string]bool{}
+	for _, field := range i.SettingsSchema {
+		if err := field.Validate(); err != nil {
+			return err
+		}
+		if keys[field.Key] {
+			return errors.New("plugin setting key is duplicated: " + field.Key)
+		}
+		keys[field.Key] = true
+	}
+	return nil
+}
+
+// ValidateSettings checks the options against the settings schema, options without a schema field are kept as is
+func (i *IntroSpect) ValidateSettings(options map[string]string) error {
+	for _, field := range i.SettingsSchema {
+		if err := field.ValidateValue(options[field.Key]); err != nil {
+			return fmt.Errorf("%s: %w", field.Label, err)
+		}
+	}
+	return nil
+}
+
+// SettingsWithDefaults returns a copy of the options with the default of every unset schema field filled in
+func (i *IntroSpect) SettingsWithDefaults(options map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, field := range i.SettingsSchema {
+		if field.Default != "" {
+			merged[field.Key] = field.Default
+		}
+	}
+	for key, value := range options {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,111 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+/*
+	Simulate_capture.go
+
+	This file explains which capture rule of the IntroSpect matches a
+	request, for debugging and testing the capture rules without Zoraxy.
+	The rules are evaluated in the order Zoraxy uses: the global capture
+	paths, then the always capture paths, then the dynamic ingresses
+
+	From the command line, the decision is printed as JSON by
+	./myplugin -zoraxy-simulate-capture https://example.com/api/v1
+
+	Example:
+	decision := spec.SimulateCapture(httptest.NewRequest("GET", "/api/v1", nil))
+	if !decision.Captured {
+		t.Error(decision.Reason)
+	}
+*/
+
+const SimulateCaptureFlag = SDKFlagPrefix + "simulate-capture"
+
+// CaptureDecision describes how the capture rules of a plugin apply to a request
+type CaptureDecision struct {
+	Captured bool         `json:"captured"`          //True if a global or always capture rule matches the request
+	Mode     CaptureMode  `json:"mode,omitempty"`    //Capture mode of the matched rule, dynamic if a sniff ingress decides
+	Ingress  string       `json:"ingress,omitempty"` //Ingress the request is forwarded to (for dynamic, the capture ingress)
+	Rule     *CaptureRule `json:"rule,omitempty"`    //The matched capture rule
+	Reason   string       `json:"reason"`            //Human readable explanation of the decision
+}
+
+// SimulateCapture returns which capture rule of the plugin would match the request and why
+func (i *IntroSpect) SimulateCapture(req *http.Request) CaptureDecision {
+	for _, rule := range i.GlobalCapturePaths {
+		if !rule.MatchesRequest(req) {
+			continue
+		}
+		matchedRule := rule
+		return CaptureDecision{
+			Captured: true,
+			Mode:     CaptureMode_Global,
+			Ingress:  i.GlobalCaptureIngress,
+			Rule:     &matchedRule,
+			Reason:   "global capture path " + describeCaptureRule(rule) + " matches " + req.URL.Path + " on every HTTP proxy rule",
+		}
+	}
+	for _, rule := range i.AlwaysCapturePaths {
+		if !rule.MatchesRequest(req) {
+			continue
+		}
+		matchedRule := rule
+		return CaptureDecision{
+			Captured: true,
+			Mode:     CaptureMode_Always,
+			Ingress:  i.AlwaysCaptureIngress,
+			Rule:     &matchedRule,
+			Reason:   "always capture path " + describeCaptureRule(rule) + " matches " + req.URL.Path + " on the HTTP proxy rules the plugin is enabled on",
+		}
+	}
+	if ingresses := i.DynamicIngressList(); len(ingresses) > 0 {
+		return CaptureDecision{
+			Mode:    CaptureMode_Dynamic,
+			Ingress: ingresses[0].CaptureIngress,
+			Reason:  "no capture path matches " + req.URL.Path + ", the dynamic capture ingress " + ingresses[0].CaptureIngress + " (" + ingresses[0].Name + ") decides per request",
+		}
+	}
+	return CaptureDecision{
+		Reason: "no capture path matches " + req.URL.Path + " and the plugin has no dynamic capture ingress",
+	}
+}
+
+// describeCaptureRule formats the capture rule for a CaptureDecision reason
+func describeCaptureRule(rule CaptureRule) string {
+	description := rule.CapturePath
+	if rule.IncludeSubPaths {
+		description += " (including sub paths)"
+	}
+	if len(rule.HeaderMatch) > 0 {
+		description += " with header conditions"
+	}
+	return description
+}
+
+// serveSimulateCapture prints the CaptureDecision of the URL given on the command line
+func serveSimulateCapture(pluginSpect *IntroSpect, args []string) {
+	target := ""
+	if len(args) > 0 && strings.HasPrefix(args[0], SimulateCaptureFlag+"=") {
+		target = strings.TrimPrefix(args[0], SimulateCaptureFlag+"=")
+	} else if len(args) > 1 {
+		target = args[1]
+	}
+	if target == "" {
+		fmt.Fprintln(os.Stderr, "Usage: "+SimulateCaptureFlag+" <url>")
+		return
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid URL "+target+": "+err.Error())
+		return
+	}
+	jsonData, _ := json.MarshalIndent(pluginSpect.SimulateCapture(req), "", " ")
+	fmt.Println(string(jsonData))
+}
@@ -0,0 +1,79 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func newTestCaptureSpec() *IntroSpect {
+	return &IntroSpect{
+		GlobalCaptureIngress: "/g_capture",
+		GlobalCapturePaths:   []CaptureRule{{CapturePath: "/login", HeaderMatch: map[string]string{"Authorization": ""}}},
+		AlwaysCaptureIngress: "/a_capture",
+		AlwaysCapturePaths:   []CaptureRule{{CapturePath: "/api", IncludeSubPaths: true}},
+	}
+}
+
+func TestSimulateCapture(t *testing.T) {
+	spec := newTestCaptureSpec()
+	tests := []struct {
+		path     string
+		auth     string
+		captured bool
+		mode     CaptureMode
+		ingress  string
+	}{
+		{"/login", "", true, CaptureMode_Global, "/g_capture"},
+		{"/login", "Bearer abc", false, "", ""},
+		{"/api/v1/users", "", true, CaptureMode_Always, "/a_capture"},
+		{"/static/app.js", "", false, "", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		decision := spec.SimulateCapture(req)
+		if decision.Captured != test.captured || decision.Mode != test.mode || decision.Ingress != test.ingress || decision.Reason == "" {
+			t.Errorf("%s: unexpected decision %+v", test.path, decision)
+		}
+	}
+
+	spec.DynamicCaptureIngress = "/d_sniff"
+	spec.DynamicHandleIngress = "/d_handler"
+	decision := spec.SimulateCapture(httptest.NewRequest("GET", "/static/app.js", nil))
+	if decision.Captured || decision.Mode != CaptureMode_Dynamic || decision.Ingress != "/d_sniff" {
+		t.Errorf("Expected the dynamic ingress to decide, got %+v", decision)
+	}
+}
+
+func TestServeSimulateCapture(t *testing.T) {
+	originalArgs := os.Args
+	originalStdout := os.Stdout
+	defer func() {
+		os.Args = originalArgs
+		os.Stdout = originalStdout
+	}()
+
+	for _, args := range [][]string{{SimulateCaptureFlag, "https://example.com/api/v1"}, {SimulateCaptureFlag + "=/api/v1"}} {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		os.Stdout = writer
+		os.Args = append([]string{originalArgs[0]}, args...)
+		served := TryServeIntroSpect(newTestCaptureSpec())
+		writer.Close()
+		output, _ := io.ReadAll(reader)
+		reader.Close()
+		os.Stdout = originalStdout
+
+		var decision CaptureDecision
+		if !served || json.Unmarshal(output, &decision) != nil || !decision.Captured || decision.Mode != CaptureMode_Always {
+			t.Errorf("%v: expected an always capture decision, got %q", args, output)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*
+	Sni_inspect.go
+
+	TLSInspector plugins can allow, deny or re-route incoming TLS
+	connections based on the SNI and ALPN sent by the client, before
+	Zoraxy picks a proxy rule for them.
+
+	Zoraxy sends a POST request with SNIInspectRequest as JSON body to
+	IntroSpect.SNIInspectIngress and expects a SNIInspectResponse in return.
+	If the plugin does not reply in time or replies with an error,
+	Zoraxy continues as if the connection was allowed
+*/
+
+type SNIInspectAction string
+
+const (
+	SNIInspectAction_ALLOW SNIInspectAction = "allow" //Continue routing the connection as usual
+	SNIInspectAction_DENY  SNIInspectAction = "deny"  //Close the connection before the handshake completes
+	SNIInspectAction_ROUTE SNIInspectAction = "route" //Route the connection to the proxy rule of RouteTo instead
+)
+
+type SNIInspectRequest struct {
+	ServerName    string   `json:"server_name"`    //SNI sent by the client, can be empty
+	ALPNProtocols []string `json:"alpn_protocols"` //ALPN protocols offered by the client (e.g. h2, http/1.1)
+	ClientIP      string   `json:"client_ip"`      //IP address of the client
+	ListenAddr    string   `json:"listen_addr"`    //Address of the Zoraxy listener that accepted the connection
+}
+
+type SNIInspectResponse struct {
+	Action  SNIInspectAction `json:"action"`
+	RouteTo string           `json:"route_to,omitempty"` //Hostname of the proxy rule to route to, only used with SNIInspectAction_ROUTE
+	Reason  string           `json:"reason,omitempty"`   //Optional reason, logged by Zoraxy on deny
+}
+
+// RegisterSNIInspectHandler registers the SNI inspection handler at the given ingress path
+// If mux is nil, the handler is registered to http.DefaultServeMux
+func RegisterSNIInspectHandler(ingress string, fn func(*SNIInspectRequest) *SNIInspectResponse, mux *http.ServeMux) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.HandleFunc(ingress, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req SNIInspectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid SNI inspect request", http.StatusBadRequest)
+			return
+		}
+
+		resp := fn(&req)
+		if resp == nil {
+			resp = &SNIInspectResponse{Action: SNIInspectAction_ALLOW}
+		}
+		switch resp.Action {
+		case SNIInspectAction_ALLOW, SNIInspectAction_DENY:
+		case SNIInspectAction_ROUTE:
+			if resp.RouteTo == "" {
+				http.Error(w, "route action requires a route target", http.StatusInternalServerError)
+				return
+			}
+		default:
+			http.Error(w, "unknown SNI inspect action: "+string(resp.Action), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
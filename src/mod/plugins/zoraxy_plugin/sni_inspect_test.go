@@ -0,0 +1,121 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// captureClientHello runs a TLS client handshake over a pipe and returns the ClientHello seen by the server
+func captureClientHello(t *testing.T, serverName string, alpnProtocols []string) *tls.ClientHelloInfo {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	errAbortHandshake := errors.New("abort handshake")
+	helloChan := make(chan *tls.ClientHelloInfo, 1)
+	go func() {
+		server := tls.Server(serverConn, &tls.Config{
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				helloChan <- hello
+				return nil, errAbortHandshake
+			},
+		})
+		server.Handshake()
+		serverConn.Close()
+	}()
+	client := tls.Client(clientConn, &tls.Config{ServerName: serverName, NextProtos: alpnProtocols, InsecureSkipVerify: true})
+	client.Handshake()
+
+	select {
+	case hello := <-helloChan:
+		return hello
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not receive a ClientHello")
+		return nil
+	}
+}
+
+func TestRegisterSNIInspectHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterSNIInspectHandler("/sni_inspect", func(req *SNIInspectRequest) *SNIInspectResponse {
+		switch req.ServerName {
+		case "blocked.example.com":
+			return &SNIInspectResponse{Action: SNIInspectAction_DENY, Reason: "blocked"}
+		case "old.example.com":
+			if slices.Contains(req.ALPNProtocols, "h2") {
+				return &SNIInspectResponse{Action: SNIInspectAction_ROUTE, RouteTo: "new.example.com"}
+			}
+		case "broken.example.com":
+			return &SNIInspectResponse{Action: SNIInspectAction_ROUTE}
+		case "unknown.example.com":
+			return &SNIInspectResponse{Action: "drop"}
+		}
+		return nil
+	}, mux)
+
+	inspect := func(hello *tls.ClientHelloInfo) *httptest.ResponseRecorder {
+		js, _ := json.Marshal(SNIInspectRequest{
+			ServerName:    hello.ServerName,
+			ALPNProtocols: hello.SupportedProtos,
+			ClientIP:      "192.0.2.1",
+			ListenAddr:    ":443",
+		})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sni_inspect", bytes.NewReader(js)))
+		return rec
+	}
+
+	tests := []struct {
+		serverName     string
+		alpnProtocols  []string
+		expectedStatus int
+		expected       SNIInspectResponse
+	}{
+		{"www.example.com", []string{"h2", "http/1.1"}, http.StatusOK, SNIInspectResponse{Action: SNIInspectAction_ALLOW}},
+		{"blocked.example.com", nil, http.StatusOK, SNIInspectResponse{Action: SNIInspectAction_DENY, Reason: "blocked"}},
+		{"old.example.com", []string{"h2"}, http.StatusOK, SNIInspectResponse{Action: SNIInspectAction_ROUTE, RouteTo: "new.example.com"}},
+		{"old.example.com", []string{"http/1.1"}, http.StatusOK, SNIInspectResponse{Action: SNIInspectAction_ALLOW}},
+		{"broken.example.com", nil, http.StatusInternalServerError, SNIInspectResponse{}},
+		{"unknown.example.com", nil, http.StatusInternalServerError, SNIInspectResponse{}},
+	}
+	for _, test := range tests {
+		hello := captureClientHello(t, test.serverName, test.alpnProtocols)
+		if hello.ServerName != test.serverName || !slices.Equal(hello.SupportedProtos, test.alpnProtocols) {
+			t.Fatalf("%s: unexpected ClientHello %q %v", test.serverName, hello.ServerName, hello.SupportedProtos)
+		}
+		rec := inspect(hello)
+		if rec.Code != test.expectedStatus {
+			t.Errorf("%s %v: expected status %d, got %d", test.serverName, test.alpnProtocols, test.expectedStatus, rec.Code)
+			continue
+		}
+		if test.expectedStatus != http.StatusOK {
+			continue
+		}
+		if rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: unexpected content type %q", test.serverName, rec.Header().Get("Content-Type"))
+		}
+		var resp SNIInspectResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp != test.expected {
+			t.Errorf("%s %v: expected %+v, got %+v (%v)", test.serverName, test.alpnProtocols, test.expected, resp, err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sni_inspect", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sni_inspect", bytes.NewReader([]byte("{"))))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid body, got %d", rec.Code)
+	}
+}
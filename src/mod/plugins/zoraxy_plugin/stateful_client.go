@@ -0,0 +1,168 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/*
+	Stateful_client.go
+
+	This file provides a HTTP client with a cookie jar for plugins that log
+	into an upstream service and keep its session cookies. The standard
+	cookiejar cannot be enumerated, so the PersistentCookieJar records the
+	received cookies and can save them to the plugin data directory
+	(see SaveJSON) and restore them after a restart
+
+	Example:
+	client := zoraxy_plugin.NewStatefulClient(zoraxy_plugin.StatefulClientOptions{})
+	jar := client.Jar.(*zoraxy_plugin.PersistentCookieJar)
+	if err := jar.Load(configSpec, "cookies.json"); err != nil {
+		login(client)
+	}
+	defer jar.Save(configSpec, "cookies.json")
+*/
+
+type StatefulClientOptions struct {
+	Timeout               time.Duration              //Timeout of the whole request, default 30s
+	ResponseHeaderTimeout time.Duration              //Max duration to wait for the response headers, 0 for no limit
+	Transport             http.RoundTripper          //Transport used for the requests, default a clone of http.DefaultTransport
+	PublicSuffixList      cookiejar.PublicSuffixList //Public suffix list of the cookie jar, see cookiejar.Options
+}
+
+// PersistentCookieJar is a http.CookieJar that can be saved and restored, see NewStatefulClient
+type PersistentCookieJar struct {
+	jar     *cookiejar.Jar
+	options *cookiejar.Options
+	mu      sync.Mutex
+	cookies map[string]persistedCookie //Key is the URL, domain, path and name of the cookie
+}
+
+type persistedCookie struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Path     string        `json:"path,omitempty"`
+	Domain   string        `json:"domain,omitempty"`
+	Expires  time.Time     `json:"expires,omitempty"` //Zero for session cookies
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// NewStatefulClient creates a http.Client with a PersistentCookieJar
+func NewStatefulClient(opts StatefulClientOptions) *http.Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	transport := opts.Transport
+	if transport == nil {
+		defaultTransport := http.DefaultTransport.(*http.Transport).Clone()
+		defaultTransport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+		transport = defaultTransport
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		Jar:       NewPersistentCookieJar(&cookiejar.Options{PublicSuffixList: opts.PublicSuffixList}),
+	}
+}
+
+// NewPersistentCookieJar creates an empty PersistentCookieJar, options may be nil
+func NewPersistentCookieJar(options *cookiejar.Options) *PersistentCookieJar {
+	//cookiejar.New never returns an error
+	jar, _ := cookiejar.New(options)
+	return &PersistentCookieJar{
+		jar:     jar,
+		options: options,
+		cookies: map[string]persistedCookie{},
+	}
+}
+
+// SetCookies implements http.CookieJar and records the cookies for Save
+func (j *PersistentCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar.SetCookies(u, cookies)
+	now := time.Now()
+	for _, cookie := range cookies {
+		key := u.Scheme + "://" + u.Host + "|" + cookie.Domain + "|" + cookie.Path + "|" + cookie.Name
+		expires := cookie.Expires
+		if cookie.MaxAge > 0 {
+			expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if cookie.MaxAge < 0 || (!expires.IsZero() && !expires.After(now)) {
+			//The upstream deleted the cookie
+			delete(j.cookies, key)
+			continue
+		}
+		j.cookies[key] = persistedCookie{
+			URL:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			Expires:  expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: cookie.SameSite,
+		}
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *PersistentCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	jar := j.jar
+	j.mu.Unlock()
+	return jar.Cookies(u)
+}
+
+// Save writes the unexpired cookies of the jar to the named file in the plugin data directory
+func (j *PersistentCookieJar) Save(spec *ConfigureSpec, name string) error {
+	j.mu.Lock()
+	cookies := []persistedCookie{}
+	now := time.Now()
+	for _, cookie := range j.cookies {
+		if cookie.Expires.IsZero() || cookie.Expires.After(now) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	j.mu.Unlock()
+	return spec.SaveJSON(name, cookies)
+}
+
+// Load replaces the cookies of the jar with the ones saved to the named file, expired cookies are dropped
+// The returned error wraps fs.ErrNotExist if the jar has not been saved yet
+func (j *PersistentCookieJar) Load(spec *ConfigureSpec, name string) error {
+	cookies := []persistedCookie{}
+	if err := spec.LoadJSON(name, &cookies); err != nil {
+		return err
+	}
+	jar, _ := cookiejar.New(j.options)
+	j.mu.Lock()
+	j.jar = jar
+	j.cookies = map[string]persistedCookie{}
+	j.mu.Unlock()
+
+	for _, cookie := range cookies {
+		u, err := url.Parse(cookie.URL)
+		if err != nil {
+			continue
+		}
+		j.SetCookies(u, []*http.Cookie{{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: cookie.SameSite,
+		}})
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatefulClient(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "tracking", Value: "1", Path: "/"})
+		case "/logout-tracking":
+			http.SetCookie(w, &http.Cookie{Name: "tracking", Value: "", Path: "/", MaxAge: -1})
+		case "/me":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+	}))
+	defer upstream.Close()
+	spec := &ConfigureSpec{DataDir: t.TempDir()}
+
+	client := NewStatefulClient(StatefulClientOptions{})
+	for _, path := range []string{"/login", "/logout-tracking"} {
+		resp, err := client.Get(upstream.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if err := client.Jar.(*PersistentCookieJar).Save(spec, "cookies.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	//A new client after a restart picks up the saved session
+	restored := NewStatefulClient(StatefulClientOptions{})
+	if err := restored.Jar.(*PersistentCookieJar).Load(spec, "cookies.json"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := restored.Get(upstream.URL + "/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the restored session to be sent, got %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest("GET", upstream.URL, nil)
+	cookies := restored.Jar.Cookies(req.URL)
+	if len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Errorf("Expected only the session cookie to be restored, got %v", cookies)
+	}
+
+	if err := NewPersistentCookieJar(nil).Load(spec, "missing.json"); err == nil {
+		t.Error("Expected an error for a jar that was never saved")
+	}
+}
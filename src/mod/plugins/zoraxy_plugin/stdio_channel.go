@@ -0,0 +1,138 @@
+package zoraxy_plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+	Stdio_channel.go
+
+	This file provides a bidirectional channel of newline delimited JSON
+	events over the STDIN and STDOUT of the plugin, so the plugin and
+	Zoraxy can exchange messages without an extra port. Every event is a
+	single line wrapped in {"zoraxy_event": ...} like the watchdog
+	heartbeat, so it can be told apart from the log lines printed to
+	STDOUT. Lines are read with no length limit, events split across
+	several reads are joined before they are dispatched
+
+	If the ConfigureSpec was piped to STDIN (-configure=-), the bytes
+	after the payload are kept for the channel, the argv and environment
+	configure forms do not touch STDIN at all
+
+	Example:
+	channel := zoraxy_plugin.NewStdioChannel()
+	channel.OnReceive(func(event json.RawMessage) {
+		fmt.Println("Received event: " + string(event))
+	})
+	channel.Send(map[string]string{"type": "ready"})
+*/
+
+// StdioEventPrefix is the prefix of the event lines sent over the stdio channel
+const StdioEventPrefix = `{"zoraxy_event":`
+
+type StdioChannel struct {
+	writeMu  sync.Mutex
+	out      io.Writer
+	in       io.Reader
+	mu       sync.RWMutex
+	handlers []func(event json.RawMessage)
+	readOnce sync.Once
+}
+
+var (
+	stdinRemainderMu sync.Mutex
+	stdinRemainder   []byte //Bytes read past the ConfigureSpec piped to STDIN
+)
+
+// NewStdioChannel creates a channel over the STDIN and STDOUT of the plugin
+func NewStdioChannel() *StdioChannel {
+	stdinRemainderMu.Lock()
+	remainder := stdinRemainder
+	stdinRemainder = nil
+	stdinRemainderMu.Unlock()
+	return newStdioChannel(io.MultiReader(bytes.NewReader(remainder), os.Stdin), os.Stdout)
+}
+
+func newStdioChannel(in io.Reader, out io.Writer) *StdioChannel {
+	return &StdioChannel{in: in, out: out}
+}
+
+// Send writes the event as a single JSON line, it is safe to call from multiple goroutines
+func (c *StdioChannel) Send(event any) error {
+	js, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line := make([]byte, 0, len(StdioEventPrefix)+len(js)+2)
+	line = append(line, StdioEventPrefix...)
+	line = append(line, js...)
+	line = append(line, '}', '\n')
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.out.Write(line)
+	return err
+}
+
+// OnReceive registers a handler called with every received event, the first call starts the dispatch loop
+// Handlers are called in registration order from the dispatch goroutine
+func (c *StdioChannel) OnReceive(fn func(event json.RawMessage)) {
+	c.mu.Lock()
+	c.handlers = append(c.handlers, fn)
+	c.mu.Unlock()
+	c.readOnce.Do(func() {
+		go c.dispatchLoop()
+	})
+}
+
+// dispatchLoop reads event lines until STDIN is closed, other lines are ignored
+func (c *StdioChannel) dispatchLoop() {
+	reader := bufio.NewReader(c.in)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			c.dispatchLine(line)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Println("[stdio] Failed to read from STDIN: " + err.Error())
+			}
+			return
+		}
+	}
+}
+
+func (c *StdioChannel) dispatchLine(line []byte) {
+	event, ok := ParseStdioEvent(string(bytes.TrimRight(line, "\r\n")))
+	if !ok {
+		return
+	}
+	c.mu.RLock()
+	handlers := c.handlers
+	c.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// ParseStdioEvent returns the event of a line sent over the stdio channel
+func ParseStdioEvent(line string) (json.RawMessage, bool) {
+	if len(line) < len(StdioEventPrefix) || line[:len(StdioEventPrefix)] != StdioEventPrefix {
+		return nil, false
+	}
+	payload := struct {
+		Event json.RawMessage `json:"zoraxy_event"`
+	}{}
+	if err := json.Unmarshal([]byte(line), &payload); err != nil || len(payload.Event) == 0 {
+		fmt.Println("[stdio] Ignored malformed event line")
+		return nil, false
+	}
+	return payload.Event, true
+}
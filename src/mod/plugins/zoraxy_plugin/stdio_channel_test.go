@@ -0,0 +1,50 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdioChannel(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	out := &bytes.Buffer{}
+	channel := newStdioChannel(inReader, out)
+
+	received := make(chan string, 4)
+	channel.OnReceive(func(event json.RawMessage) {
+		received <- string(event)
+	})
+
+	//Log lines are ignored and events split across writes are joined
+	io.WriteString(inWriter, "plain log line\n"+StdioEventPrefix+`{"type":"re`)
+	io.WriteString(inWriter, `load"}}`+"\n"+StdioEventPrefix+`"`+strings.Repeat("x", 100000)+`"}`+"\n")
+	for _, expected := range []string{`{"type":"reload"}`, `"` + strings.Repeat("x", 100000) + `"`} {
+		select {
+		case event := <-received:
+			if event != expected {
+				t.Errorf("Expected event of length %d, got %.50s", len(expected), event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for the event")
+		}
+	}
+	inWriter.Close()
+
+	if err := channel.Send(map[string]string{"type": "ready"}); err != nil {
+		t.Fatal(err)
+	}
+	line := out.String()
+	if line != StdioEventPrefix+`{"type":"ready"}}`+"\n" {
+		t.Errorf("Unexpected event line %q", line)
+	}
+	if event, ok := ParseStdioEvent(strings.TrimSpace(line)); !ok || string(event) != `{"type":"ready"}` {
+		t.Errorf("Expected the sent line to parse back, got %s", event)
+	}
+	if _, ok := ParseHeartbeat(strings.TrimSpace(line)); ok {
+		t.Error("Expected an event line not to be parsed as a heartbeat")
+	}
+}
@@ -0,0 +1,289 @@
+package testutil
+
+/*
+	Zoraxy Plugin Test Utilities
+
+	This package simulates the Zoraxy side of the plugin protocol
+	so plugin authors can test their plugin main function in-process.
+
+	When copying the zoraxy_plugin module into your plugin, update the
+	zoraxy_plugin import below to match the path in your own module
+*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	plugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
+
+// The plugin main function reads os.Args and os.Stdout, only simulate one plugin at a time
+var runMu sync.Mutex
+
+// pluginRunning is set while a plugin started by RunConfigure owns os.Args and plugin.ExitFunc
+var pluginRunning atomic.Bool
+
+// ErrPluginRunning is returned while a plugin started by RunConfigure is still running in-process
+var ErrPluginRunning = errors.New("a plugin started by RunConfigure is still running")
+
+// exitSignal is raised in place of os.Exit while the plugin main function runs in-process
+type exitSignal struct {
+	code int
+}
+
+// RunIntrospect runs the plugin main function with the -introspect flag
+// and returns the IntroSpect printed by the plugin
+func RunIntrospect(pluginMain func()) (plugin.IntroSpect, error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+
+	var spec plugin.IntroSpect
+	if pluginRunning.Load() {
+		return spec, ErrPluginRunning
+	}
+	output, exitCode, err := runCaptured(pluginMain, []string{"-introspect"})
+	if err != nil {
+		return spec, err
+	}
+	if exitCode != 0 {
+		return spec, fmt.Errorf("plugin exited with code %d during introspect", exitCode)
+	}
+	if err := json.Unmarshal(output, &spec); err != nil {
+		return spec, fmt.Errorf("failed to parse introspect output: %v", err)
+	}
+	return spec, nil
+}
+
+// RunningPlugin is a plugin main function started in-process by RunConfigure
+type RunningPlugin struct {
+	Spec *plugin.ConfigureSpec //The configure spec sent to the plugin
+
+	done    chan struct{}
+	exitErr error
+}
+
+// RunConfigure starts the plugin main function with the -configure flag in the background
+// and waits until the plugin is listening on the configured port. If spec.Port is 0, a free
+// port is assigned. The returned RunningPlugin holds the spec sent to the plugin
+// The plugin keeps running until its main function returns or exits, or Stop is called.
+// os.Args and plugin.ExitFunc stay overridden until then and RunIntrospect / RunConfigure
+// return ErrPluginRunning meanwhile
+func RunConfigure(pluginMain func(), spec plugin.ConfigureSpec) (*RunningPlugin, error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	if pluginRunning.Load() {
+		return nil, ErrPluginRunning
+	}
+
+	if spec.Port == 0 {
+		port, err := getFreePort()
+		if err != nil {
+			return nil, err
+		}
+		spec.Port = port
+	}
+	js, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	//The overrides belong to the plugin goroutine, it restores them once the plugin is gone
+	//so a late plugin exit never reaches the real os.Exit
+	originalArgs := os.Args
+	originalExit := plugin.ExitFunc
+	os.Args = []string{originalArgs[0], "-configure=" + string(js)}
+	plugin.ExitFunc = func(code int) {
+		panic(exitSignal{code: code})
+	}
+	pluginRunning.Store(true)
+
+	running := &RunningPlugin{Spec: &spec, done: make(chan struct{})}
+	go func() {
+		defer func() {
+			err := errPluginReturned
+			if r := recover(); r != nil {
+				if sig, ok := r.(exitSignal); ok {
+					err = fmt.Errorf("plugin exited with code %d", sig.code)
+					if sig.code == 0 {
+						err = errPluginReturned
+					}
+				} else {
+					err = fmt.Errorf("plugin panicked: %v", r)
+				}
+			}
+			os.Args = originalArgs
+			plugin.ExitFunc = originalExit
+			pluginRunning.Store(false)
+			running.exitErr = err
+			close(running.done)
+		}()
+		pluginMain()
+	}()
+
+	//Wait for the plugin to start listening
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-running.done:
+			return nil, running.exitErr
+		default:
+		}
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(spec.Port), 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return running, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	//Do not leave the plugin running without a handle to stop it
+	ctx, cancel := context.WithTimeout(context.Background(), plugin.WorkerStopTimeout)
+	defer cancel()
+	running.Stop(ctx)
+	return nil, errors.New("plugin did not start listening on port " + strconv.Itoa(spec.Port))
+}
+
+// errPluginReturned is the exit result of a plugin main function that returned or exited with code 0
+var errPluginReturned = errors.New("plugin main returned")
+
+// Stop stops the workers and web servers the plugin started through the SDK (see plugin.StopWorkers)
+// and waits until the plugin main function returns. os.Args and plugin.ExitFunc are restored and
+// the harness can run the next plugin once Stop returns nil.
+// A plugin serving with plain http.ListenAndServe cannot be stopped, use plugin.ListenAndServeWithOptions.
+// If the main function has not returned when ctx is done, the context error is returned and
+// the plugin is still considered running. Calling Stop again returns the same result
+func (p *RunningPlugin) Stop(ctx context.Context) error {
+	select {
+	case <-p.done:
+	default:
+		if err := plugin.StopWorkers(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			return fmt.Errorf("plugin main did not return: %w", ctx.Err())
+		}
+	}
+	if errors.Is(p.exitErr, errPluginReturned) {
+		return nil
+	}
+	return p.exitErr
+}
+
+// FakeCaptureRequest builds a request as forwarded by Zoraxy to a plugin capture ingress
+type FakeCaptureRequest struct {
+	req *http.Request
+}
+
+// NewFakeCaptureRequest creates a new FakeCaptureRequest with the given method and target path
+func NewFakeCaptureRequest(method string, target string, body []byte) *FakeCaptureRequest {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, bodyReader)
+	req.RemoteAddr = "127.0.0.1:40000"
+	return &FakeCaptureRequest{req: req}
+}
+
+// WithClientIP sets the IP of the original client
+func (f *FakeCaptureRequest) WithClientIP(ip string) *FakeCaptureRequest {
+	f.req.Header.Set("X-Zoraxy-Client-IP", ip)
+	f.req.Header.Set("X-Real-Ip", ip)
+	return f
+}
+
+// WithHost sets the Host of the original request
+func (f *FakeCaptureRequest) WithHost(host string) *FakeCaptureRequest {
+	f.req.Host = host
+	return f
+}
+
+// WithHeader sets a header of the request
+func (f *FakeCaptureRequest) WithHeader(key string, value string) *FakeCaptureRequest {
+	f.req.Header.Set(key, value)
+	return f
+}
+
+// WithRequestID sets the request ID of the request
+func (f *FakeCaptureRequest) WithRequestID(requestID string) *FakeCaptureRequest {
+	f.req.Header.Set(plugin.RequestIDHeader, requestID)
+	return f
+}
+
+// WithTraceParent sets the W3C traceparent header of the request
+func (f *FakeCaptureRequest) WithTraceParent(traceID string, spanID string) *FakeCaptureRequest {
+	f.req.Header.Set(plugin.TraceHeader_TraceParent, "00-"+traceID+"-"+spanID+"-01")
+	return f
+}
+
+// Build returns the http.Request
+func (f *FakeCaptureRequest) Build() *http.Request {
+	return f.req
+}
+
+// runCaptured runs the plugin main function with the given arguments and captures its STDOUT
+func runCaptured(pluginMain func(), args []string) (output []byte, exitCode int, err error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	originalArgs := os.Args
+	originalStdout := os.Stdout
+	originalExit := plugin.ExitFunc
+	os.Args = append([]string{originalArgs[0]}, args...)
+	os.Stdout = writer
+	plugin.ExitFunc = func(code int) {
+		panic(exitSignal{code: code})
+	}
+
+	outputChan := make(chan []byte, 1)
+	go func() {
+		buf, _ := io.ReadAll(reader)
+		outputChan <- buf
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if sig, ok := r.(exitSignal); ok {
+					exitCode = sig.code
+					return
+				}
+				err = fmt.Errorf("plugin panicked: %v", r)
+			}
+		}()
+		pluginMain()
+	}()
+
+	os.Args = originalArgs
+	os.Stdout = originalStdout
+	plugin.ExitFunc = originalExit
+	writer.Close()
+	output = <-outputChan
+	reader.Close()
+	return output, exitCode, err
+}
+
+func getFreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
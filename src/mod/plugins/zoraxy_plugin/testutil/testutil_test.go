@@ -0,0 +1,149 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	plugin "imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
+)
+
+func examplePluginMain() {
+	runtimeCfg, err := plugin.ServeAndRecvSpec(&plugin.IntroSpect{
+		ID:          "org.example.testutil",
+		Name:        "Testutil Plugin",
+		Author:      "foobar",
+		Description: "Plugin used to test the testutil package",
+		Type:        plugin.PluginType_Utilities,
+		UIPath:      "/ui",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ui/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	plugin.ListenAndServeWithOptions("127.0.0.1:"+strconv.Itoa(runtimeCfg.Port), mux, nil)
+}
+
+func TestRunIntrospect(t *testing.T) {
+	spec, err := RunIntrospect(examplePluginMain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.ID != "org.example.testutil" || spec.UIPath != "/ui" {
+		t.Errorf("Unexpected introspect result: %+v", spec)
+	}
+}
+
+func TestRunConfigureExit(t *testing.T) {
+	originalArgs := os.Args
+	_, err := RunConfigure(func() {
+		plugin.ExitFunc(2)
+	}, plugin.ConfigureSpec{})
+	if err == nil || !strings.Contains(err.Error(), "code 2") {
+		t.Fatalf("Expected the plugin exit code, got %v", err)
+	}
+	if len(os.Args) != len(originalArgs) || os.Args[0] != originalArgs[0] {
+		t.Errorf("Expected os.Args to be restored after the plugin exited, got %v", os.Args)
+	}
+	if _, err := RunIntrospect(examplePluginMain); err != nil {
+		t.Errorf("Expected the harness to be usable after the plugin exited, got %v", err)
+	}
+}
+
+func TestRunConfigure(t *testing.T) {
+	originalArgs := os.Args
+	running, err := RunConfigure(examplePluginMain, plugin.ConfigureSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { running.Stop(context.Background()) })
+
+	resp, err := http.Get("http://127.0.0.1:" + strconv.Itoa(running.Spec.Port) + "/ui/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if _, err := RunIntrospect(examplePluginMain); !errors.Is(err, ErrPluginRunning) {
+		t.Errorf("Expected ErrPluginRunning while the plugin runs, got %v", err)
+	}
+
+	//Stop shuts the plugin down and frees the harness
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := running.Stop(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := running.Stop(ctx); err != nil {
+		t.Errorf("Expected a second Stop to return the same result, got %v", err)
+	}
+	if len(os.Args) != len(originalArgs) || os.Args[0] != originalArgs[0] {
+		t.Errorf("Expected os.Args to be restored after Stop, got %v", os.Args)
+	}
+	if _, err := RunIntrospect(examplePluginMain); err != nil {
+		t.Errorf("Expected the harness to be usable after Stop, got %v", err)
+	}
+}
+
+func TestRunningPluginStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	running, err := RunConfigure(func() {
+		runtimeCfg, err := plugin.RecvConfigureSpec()
+		if err != nil {
+			panic(err)
+		}
+		//A plain listener is not stopped by plugin.StopWorkers
+		listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(runtimeCfg.Port))
+		if err != nil {
+			panic(err)
+		}
+		defer listener.Close()
+		<-release
+	}, plugin.ConfigureSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := running.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := RunIntrospect(examplePluginMain); !errors.Is(err, ErrPluginRunning) {
+		t.Errorf("Expected the plugin to be still running, got %v", err)
+	}
+	close(release)
+	if err := running.Stop(context.Background()); err != nil {
+		t.Errorf("Expected nil once the plugin returned, got %v", err)
+	}
+}
+
+func TestNewFakeCaptureRequest(t *testing.T) {
+	req := NewFakeCaptureRequest("GET", "/app/index.html", nil).
+		WithClientIP("203.0.113.7").
+		WithRequestID("req-1").
+		WithTraceParent("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7").
+		Build()
+
+	if plugin.GetClientIP(req) != "203.0.113.7" {
+		t.Errorf("Unexpected client IP: %s", plugin.GetClientIP(req))
+	}
+	if plugin.RequestID(req) != "req-1" {
+		t.Errorf("Unexpected request ID: %s", plugin.RequestID(req))
+	}
+	if traceID, _, ok := plugin.TraceContext(req); !ok || traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected trace context: %s %v", traceID, ok)
+	}
+}
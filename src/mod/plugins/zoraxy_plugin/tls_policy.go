@@ -0,0 +1,122 @@
+package zoraxy_plugin
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+/*
+	TLS_policy.go
+
+	This file allows plugins that terminate TLS connections themselves
+	to declare the minimum TLS version and cipher suites they accept.
+	Insecure configurations are rejected when the policy is validated.
+
+	Go does not allow configuring the TLS 1.3 cipher suites, so only TLS
+	1.2 suites can be listed and listing suites with a minimum version of
+	1.3 is rejected as they would never be used. Pass the policy in the
+	ServeOptions of ListenAndServeTLSWithOptions to apply it
+
+	Example:
+	zoraxy_plugin.ListenAndServeTLSWithOptions(addr, "cert.pem", "key.pem", nil, &zoraxy_plugin.ServeOptions{
+		TLSPolicy: pluginSpec.TLSPolicy,
+	})
+*/
+
+type TLSPolicy struct {
+	MinVersion   string   `json:"min_version"`   //Minimum TLS version, "1.2" or "1.3", default "1.2"
+	CipherSuites []string `json:"cipher_suites"` //IANA names of the allowed TLS 1.2 cipher suites, default secure AEAD suites
+}
+
+// Default cipher suites for TLS 1.2, TLS 1.3 suites are not configurable in Go
+var defaultTLSCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// Validate checks if the TLS policy is secure and only uses known values
+func (p *TLSPolicy) Validate() error {
+	_, err := p.minVersion()
+	if err != nil {
+		return err
+	}
+	_, err = p.cipherSuites()
+	return err
+}
+
+// TLSConfig returns a tls.Config with the policy applied
+// The caller should fill in the certificates before using it
+func (p *TLSPolicy) TLSConfig() (*tls.Config, error) {
+	if p == nil {
+		p = &TLSPolicy{}
+	}
+	minVersion, err := p.minVersion()
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := p.cipherSuites()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+	}, nil
+}
+
+func (p *TLSPolicy) minVersion() (uint16, error) {
+	switch p.MinVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.0", "1.1":
+		return 0, errors.New("TLS " + p.MinVersion + " is insecure, minimum TLS version must be 1.2 or above")
+	}
+	return 0, errors.New("unknown TLS version: " + p.MinVersion)
+}
+
+func (p *TLSPolicy) cipherSuites() ([]uint16, error) {
+	if len(p.CipherSuites) == 0 {
+		return defaultTLSCipherSuites, nil
+	}
+	if p.MinVersion == "1.3" {
+		return nil, errors.New("cipher suites cannot be configured with a minimum TLS version of 1.3")
+	}
+
+	secureSuites := map[string]uint16{}
+	tls13Suites := map[string]bool{}
+	for _, suite := range tls.CipherSuites() {
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			tls13Suites[suite.Name] = true
+			continue
+		}
+		secureSuites[suite.Name] = suite.ID
+	}
+	insecureSuites := map[string]bool{}
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecureSuites[suite.Name] = true
+	}
+
+	results := []uint16{}
+	for _, name := range p.CipherSuites {
+		if insecureSuites[name] {
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+		if tls13Suites[name] {
+			return nil, fmt.Errorf("cipher suite %s is a TLS 1.3 suite, TLS 1.3 suites are not configurable", name)
+		}
+		id, ok := secureSuites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite: %s", name)
+		}
+		results = append(results, id)
+	}
+	return results, nil
+}
@@ -0,0 +1,177 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTLSPolicyMinVersion(t *testing.T) {
+	tests := []struct {
+		minVersion string
+		expected   uint16
+		valid      bool
+	}{
+		{"", tls.VersionTLS12, true},
+		{"1.2", tls.VersionTLS12, true},
+		{"1.3", tls.VersionTLS13, true},
+		{"1.0", 0, false},
+		{"1.1", 0, false},
+		{"TLS1.2", 0, false},
+	}
+	for _, test := range tests {
+		policy := &TLSPolicy{MinVersion: test.minVersion}
+		cfg, err := policy.TLSConfig()
+		if (err == nil) != test.valid || (policy.Validate() == nil) != test.valid {
+			t.Errorf("min version %q: expected valid %v, got %v", test.minVersion, test.valid, err)
+			continue
+		}
+		if test.valid && cfg.MinVersion != test.expected {
+			t.Errorf("min version %q: expected %x, got %x", test.minVersion, test.expected, cfg.MinVersion)
+		}
+	}
+}
+
+func TestTLSPolicyCipherSuites(t *testing.T) {
+	tests := []struct {
+		minVersion   string
+		cipherSuites []string
+		valid        bool
+	}{
+		{"", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, true},
+		{"1.2", []string{"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}, true},
+		{"", []string{"TLS_RSA_WITH_RC4_128_SHA"}, false},
+		{"", []string{"TLS_NOT_A_SUITE"}, false},
+		{"", []string{"TLS_AES_128_GCM_SHA256"}, false},
+		{"1.3", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, false},
+	}
+	for _, test := range tests {
+		policy := &TLSPolicy{MinVersion: test.minVersion, CipherSuites: test.cipherSuites}
+		cfg, err := policy.TLSConfig()
+		if (err == nil) != test.valid || (policy.Validate() == nil) != test.valid {
+			t.Errorf("suites %v min %q: expected valid %v, got %v", test.cipherSuites, test.minVersion, test.valid, err)
+			continue
+		}
+		if test.valid && len(cfg.CipherSuites) != len(test.cipherSuites) {
+			t.Errorf("suites %v: expected %d suites, got %d", test.cipherSuites, len(test.cipherSuites), len(cfg.CipherSuites))
+		}
+	}
+
+	//A nil policy uses the secure defaults
+	var policy *TLSPolicy
+	cfg, err := policy.TLSConfig()
+	if err != nil || cfg.MinVersion != tls.VersionTLS12 || len(cfg.CipherSuites) != len(defaultTLSCipherSuites) {
+		t.Errorf("Unexpected default TLS config %+v: %v", cfg, err)
+	}
+}
+
+// writeTestCertificate writes a self signed certificate for 127.0.0.1 and returns the cert and key file paths
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certFile, keyFile
+}
+
+func TestListenAndServeTLSWithOptions(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	//An invalid policy is reported before listening
+	if err := ListenAndServeTLSWithOptions(addr, certFile, keyFile, nil, &ServeOptions{TLSPolicy: &TLSPolicy{MinVersion: "1.0"}}); err == nil {
+		t.Fatal("Expected the insecure policy to be rejected")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- ListenAndServeTLSWithOptions(addr, certFile, keyFile, mux, &ServeOptions{
+			TLSPolicy: &TLSPolicy{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}},
+		})
+	}()
+
+	dial := func(cfg *tls.Config) error {
+		cfg.InsecureSkipVerify = true
+		conn, err := tls.Dial("tcp", addr, cfg)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err := dial(&tls.Config{})
+		if err == nil {
+			break
+		}
+		select {
+		case err := <-serveErr:
+			t.Fatalf("Server failed to start: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not start: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := dial(&tls.Config{MaxVersion: tls.VersionTLS11}); err == nil {
+		t.Error("Expected TLS 1.1 to be rejected")
+	}
+	if err := dial(&tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}}); err == nil {
+		t.Error("Expected a cipher suite outside of the policy to be rejected")
+	}
+	if err := dial(&tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}}); err != nil {
+		t.Errorf("Expected the allowed cipher suite to be accepted, got %v", err)
+	}
+
+	if err := StopWorkers(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Expected nil after a graceful shutdown, got %v", err)
+	}
+}
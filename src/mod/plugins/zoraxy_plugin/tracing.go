@@ -0,0 +1,80 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+	Tracing.go
+
+	This file provides helpers for reading the W3C Trace Context
+	headers (traceparent / tracestate) that Zoraxy forwards together
+	with the captured request, so plugins can attach their own spans
+	to the same trace across the proxy hop
+*/
+
+const (
+	TraceHeader_TraceParent = "traceparent" //W3C Trace Context parent header
+	TraceHeader_TraceState  = "tracestate"  //W3C Trace Context vendor state header
+)
+
+// TraceContext returns the trace ID and parent span ID from the traceparent header of the request
+// ok is false if the header is missing or malformed
+func TraceContext(r *http.Request) (traceID string, spanID string, ok bool) {
+	traceParent := strings.TrimSpace(r.Header.Get(TraceHeader_TraceParent))
+	if traceParent == "" {
+		return "", "", false
+	}
+
+	//Format: version-traceid-parentid-flags, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	chunks := strings.Split(traceParent, "-")
+	if len(chunks) < 4 {
+		return "", "", false
+	}
+	version, traceID, spanID, flags := chunks[0], chunks[1], chunks[2], chunks[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return "", "", false
+	}
+	if version == "00" && len(chunks) != 4 {
+		//Version 00 does not allow trailing fields
+		return "", "", false
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) || strings.Trim(traceID, "0") == "" {
+		return "", "", false
+	}
+	if len(spanID) != 16 || !isLowerHex(spanID) || strings.Trim(spanID, "0") == "" {
+		return "", "", false
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+// TraceContext returns the trace ID and parent span ID of the captured request, see TraceContext
+func (req DynamicCaptureRequest) TraceContext() (traceID string, spanID string, ok bool) {
+	return TraceContext(req.Request)
+}
+
+// CopyTraceHeaders copies the trace context headers from src to dst
+// Use this when forwarding a captured request to another upstream so the trace is not broken
+func CopyTraceHeaders(dst http.Header, src http.Header) {
+	for _, key := range []string{TraceHeader_TraceParent, TraceHeader_TraceState} {
+		if values := src.Values(key); len(values) > 0 {
+			dst.Del(key)
+			for _, v := range values {
+				dst.Add(key, v)
+			}
+		}
+	}
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
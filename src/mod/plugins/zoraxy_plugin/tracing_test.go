@@ -0,0 +1,84 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceContext(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name        string
+		traceParent string
+		valid       bool
+	}{
+		{"valid", "00-" + traceID + "-" + spanID + "-01", true},
+		{"valid with spaces", " 00-" + traceID + "-" + spanID + "-00 ", true},
+		{"future version with trailing fields", "01-" + traceID + "-" + spanID + "-01-extra", true},
+		{"missing header", "", false},
+		{"too few fields", "00-" + traceID + "-" + spanID, false},
+		{"version 00 with trailing fields", "00-" + traceID + "-" + spanID + "-01-extra", false},
+		{"invalid version ff", "ff-" + traceID + "-" + spanID + "-01", false},
+		{"uppercase version", "0A-" + traceID + "-" + spanID + "-01", false},
+		{"uppercase trace id", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", false},
+		{"short trace id", "00-4bf92f3577b34da6-" + spanID + "-01", false},
+		{"all zero trace id", "00-00000000000000000000000000000000-" + spanID + "-01", false},
+		{"all zero span id", "00-" + traceID + "-0000000000000000-01", false},
+		{"non hex span id", "00-" + traceID + "-00f067aa0ba902bz-01", false},
+		{"invalid flags", "00-" + traceID + "-" + spanID + "-1", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.traceParent != "" {
+			req.Header.Set(TraceHeader_TraceParent, test.traceParent)
+		}
+		gotTraceID, gotSpanID, ok := TraceContext(req)
+		if ok != test.valid {
+			t.Errorf("%s: expected ok %v, got %v", test.name, test.valid, ok)
+			continue
+		}
+		if ok && (gotTraceID != traceID || gotSpanID != spanID) {
+			t.Errorf("%s: unexpected trace context %q %q", test.name, gotTraceID, gotSpanID)
+		}
+		if !ok && (gotTraceID != "" || gotSpanID != "") {
+			t.Errorf("%s: expected empty IDs when not ok, got %q %q", test.name, gotTraceID, gotSpanID)
+		}
+	}
+}
+
+func TestDynamicCaptureRequestTraceContext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceHeader_TraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	traceID, spanID, ok := DynamicCaptureRequest{Request: req}.TraceContext()
+	if !ok || traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected trace context %q %q %v", traceID, spanID, ok)
+	}
+	if _, _, ok := (DynamicCaptureRequest{Request: httptest.NewRequest(http.MethodGet, "/", nil)}).TraceContext(); ok {
+		t.Error("Expected no trace context without the traceparent header")
+	}
+}
+
+func TestCopyTraceHeaders(t *testing.T) {
+	src := http.Header{}
+	src.Set(TraceHeader_TraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	src.Add(TraceHeader_TraceState, "a=1")
+	src.Add(TraceHeader_TraceState, "b=2")
+	dst := http.Header{}
+	dst.Set(TraceHeader_TraceState, "stale=1")
+	dst.Set("X-Other", "kept")
+
+	CopyTraceHeaders(dst, src)
+	if dst.Get(TraceHeader_TraceParent) != src.Get(TraceHeader_TraceParent) {
+		t.Errorf("Expected traceparent to be copied, got %q", dst.Get(TraceHeader_TraceParent))
+	}
+	if states := dst.Values(TraceHeader_TraceState); len(states) != 2 || states[0] != "a=1" || states[1] != "b=2" {
+		t.Errorf("Expected tracestate to be replaced, got %v", states)
+	}
+	if dst.Get("X-Other") != "kept" {
+		t.Error("Expected other headers to be kept")
+	}
+}
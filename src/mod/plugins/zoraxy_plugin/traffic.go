@@ -0,0 +1,50 @@
+package zoraxy_plugin
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+/*
+	Traffic.go
+
+	This file provides the traffic accounting of the UI router and
+	capture handlers. The bytes are counted by the UIMiddleware and
+	CaptureMiddleware of the MetricsRegistry, so they are also exported
+	at MetricsPath. Traffic of hijacked connections (e.g. WebSocket)
+	is not counted
+*/
+
+type TrafficStats struct {
+	UIBytesIn       int64 `json:"ui_bytes_in"`       //Request body bytes read by the UI router
+	UIBytesOut      int64 `json:"ui_bytes_out"`      //Response body bytes written by the UI router
+	CaptureBytesIn  int64 `json:"capture_bytes_in"`  //Request body bytes read by the capture handlers
+	CaptureBytesOut int64 `json:"capture_bytes_out"` //Response body bytes written by the capture handlers
+}
+
+// TrafficStats returns the traffic counted by the UIMiddleware and CaptureMiddleware of the registry
+func (m *MetricsRegistry) TrafficStats() TrafficStats {
+	in := map[string]string{"direction": "in"}
+	out := map[string]string{"direction": "out"}
+	return TrafficStats{
+		UIBytesIn:       int64(m.get(Metric_UIBytesTotal, in)),
+		UIBytesOut:      int64(m.get(Metric_UIBytesTotal, out)),
+		CaptureBytesIn:  int64(m.get(Metric_CaptureBytesTotal, in)),
+		CaptureBytesOut: int64(m.get(Metric_CaptureBytesTotal, out)),
+	}
+}
+
+// countingReadCloser counts the bytes read from the request body
+type countingReadCloser struct {
+	io.ReadCloser
+	bytes atomic.Int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	if c.ReadCloser == nil {
+		return 0, io.EOF
+	}
+	n, err := c.ReadCloser.Read(p)
+	c.bytes.Add(int64(n))
+	return n, err
+}
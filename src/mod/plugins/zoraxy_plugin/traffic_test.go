@@ -0,0 +1,34 @@
+package zoraxy_plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrafficStats(t *testing.T) {
+	metrics := NewMetricsRegistry("org.example.test")
+	capture := metrics.CaptureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("hello"))
+	}))
+	ui := metrics.UIMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ui page"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		capture.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/capture", strings.NewReader("0123456789")))
+	}
+	ui.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ui/", nil))
+
+	stats := metrics.TrafficStats()
+	expected := TrafficStats{UIBytesIn: 0, UIBytesOut: 7, CaptureBytesIn: 20, CaptureBytesOut: 10}
+	if stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+	if !strings.Contains(metrics.render(), Metric_CaptureBytesTotal+`{plugin_id="org.example.test",direction="in"} 20`) {
+		t.Errorf("Expected the capture traffic in the metrics output, got %q", metrics.render())
+	}
+}
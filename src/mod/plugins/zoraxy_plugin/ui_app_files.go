@@ -0,0 +1,138 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/*
+	Ui_app_files.go
+
+	This file serves the favicon and the web app manifest of the plugin UI
+	at the root of the handler prefix (e.g. /ui/favicon.ico and
+	/ui/manifest.webmanifest), so browsers stop logging 404s for them and
+	the plugin UI can be installed as a PWA.
+
+	Zoraxy mounts the plugin UI under its own prefix (/plugin.ui/<plugin id>/),
+	so a browser never requests the plugin's /favicon.ico directly. Link both
+	files with relative URLs in your pages so they resolve under the mount prefix:
+
+	<link rel="icon" href="favicon.ico">
+	<link rel="manifest" href="manifest.webmanifest">
+
+	The start_url and icon sources of the manifest should be relative for the
+	same reason, they are resolved against the URL of the manifest. When the
+	plugin is opened directly (e.g. during development), RegisterAppFileHandlers
+	also serves both files at the root of the plugin web server
+
+	Example:
+	uiRouter.WithFavicon(content, "www/favicon.ico").WithWebManifest(zoraxy_plugin.WebManifest{
+		Name:  "My Plugin",
+		Icons: []zoraxy_plugin.WebManifestIcon{{Src: "icon.png", Sizes: "192x192", Type: "image/png"}},
+	})
+*/
+
+const (
+	FaviconPath     = "/favicon.ico"
+	WebManifestPath = "/manifest.webmanifest"
+)
+
+type WebManifest struct {
+	Name            string            `json:"name"`                       //Full name of the plugin UI
+	ShortName       string            `json:"short_name,omitempty"`       //Name shown where space is limited, e.g. under the app icon
+	Description     string            `json:"description,omitempty"`      //Description of the plugin UI
+	StartURL        string            `json:"start_url"`                  //Page opened when the app is launched, relative to the manifest, default ./
+	Scope           string            `json:"scope,omitempty"`            //Navigation scope of the app, relative to the manifest, default ./
+	Display         string            `json:"display"`                    //Display mode (standalone, minimal-ui, browser), default standalone
+	ThemeColor      string            `json:"theme_color,omitempty"`      //Theme color, e.g. #1b1c1d
+	BackgroundColor string            `json:"background_color,omitempty"` //Background color of the splash screen
+	Icons           []WebManifestIcon `json:"icons,omitempty"`            //App icons, the sources are relative to the manifest
+}
+
+type WebManifestIcon struct {
+	Src     string `json:"src"`               //Icon URL relative to the manifest, e.g. icon.png
+	Sizes   string `json:"sizes,omitempty"`   //Icon sizes, e.g. 192x192
+	Type    string `json:"type,omitempty"`    //MIME type of the icon, e.g. image/png
+	Purpose string `json:"purpose,omitempty"` //Icon purpose, e.g. any maskable
+}
+
+// uiAppFile is a file served at the root of the handler prefix
+type uiAppFile struct {
+	contentType string
+	content     []byte
+	etag        string
+}
+
+// WithFavicon serves the named file of fsys as favicon.ico of the UI, call this before Handler()
+func (p *PluginUiRouter) WithFavicon(fsys fs.FS, name string) *PluginUiRouter {
+	content, err := fs.ReadFile(fsys, strings.TrimPrefix(name, "/"))
+	if err != nil {
+		fmt.Println("[" + p.PluginID + "] UI router failed to read favicon " + name + ": " + err.Error())
+		return p
+	}
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+	if strings.EqualFold(path.Ext(name), ".ico") || contentType == "" {
+		contentType = "image/x-icon"
+	}
+	p.setAppFile(FaviconPath, contentType, content)
+	return p
+}
+
+// WithWebManifest serves the web app manifest as manifest.webmanifest of the UI, call this before Handler()
+func (p *PluginUiRouter) WithWebManifest(manifest WebManifest) *PluginUiRouter {
+	if manifest.StartURL == "" {
+		manifest.StartURL = "./"
+	}
+	if manifest.Display == "" {
+		manifest.Display = "standalone"
+	}
+	content, _ := json.MarshalIndent(manifest, "", " ")
+	p.setAppFile(WebManifestPath, defaultMimeOverrides[".webmanifest"], content)
+	return p
+}
+
+// RegisterAppFileHandlers serves the favicon and the web manifest at the root of the plugin web server
+// for browsing the plugin directly. If mux is nil, http.DefaultServeMux is used
+func (p *PluginUiRouter) RegisterAppFileHandlers(mux *http.ServeMux) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	for filePath := range p.appFiles {
+		mux.HandleFunc(filePath, func(w http.ResponseWriter, r *http.Request) {
+			p.serveAppFile(w, r)
+		})
+	}
+}
+
+func (p *PluginUiRouter) setAppFile(filePath string, contentType string, content []byte) {
+	if p.appFiles == nil {
+		p.appFiles = map[string]uiAppFile{}
+	}
+	p.appFiles[filePath] = uiAppFile{contentType: contentType, content: content, etag: contentETag(content)}
+}
+
+// serveAppFile serves the app file at the request path, returns false if there is none
+func (p *PluginUiRouter) serveAppFile(w http.ResponseWriter, r *http.Request) bool {
+	file, ok := p.appFiles[r.URL.Path]
+	if !ok {
+		return false
+	}
+	w.Header().Set("Content-Type", file.contentType)
+	w.Header().Set("ETag", file.etag)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if match := r.Header.Get("If-None-Match"); match != "" && match == file.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(file.content)))
+	if r.Method != http.MethodHead {
+		w.Write(file.content)
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package zoraxy_plugin
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+)
+
+/*
+	Ui_basic_auth.go
+
+	This file provides optional HTTP Basic Auth for the PluginUiRouter
+	for quick internal tools that do not need sessions. The check runs
+	in front of the index redirects and the CSRF injecting file handler,
+	the terminate and config reload endpoints are not affected
+
+	Example:
+	uiRouter.WithBasicAuth("My Plugin", BasicAuthCredentials("admin", "secret"))
+*/
+
+// WithBasicAuth challenges requests to the UI without valid credentials with a 401
+// verify is called with the user and password of every request, use BasicAuthCredentials
+// or SecureCompare to avoid timing attacks. Call this before Handler()
+func (p *PluginUiRouter) WithBasicAuth(realm string, verify func(user, pass string) bool) *PluginUiRouter {
+	p.basicAuthRealm = realm
+	p.basicAuthVerify = verify
+	return p
+}
+
+// BasicAuthCredentials returns a verify function for WithBasicAuth that accepts a single user
+func BasicAuthCredentials(expectedUser string, expectedPass string) func(user string, pass string) bool {
+	return func(user string, pass string) bool {
+		//Check both values so the response time does not tell which one is wrong
+		userMatch := SecureCompare(user, expectedUser)
+		passMatch := SecureCompare(pass, expectedPass)
+		return userMatch && passMatch
+	}
+}
+
+// SecureCompare compares two strings in constant time
+// The values are hashed first so the comparison time does not leak their length either
+func SecureCompare(given string, expected string) bool {
+	givenHash := sha256.Sum256([]byte(given))
+	expectedHash := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(givenHash[:], expectedHash[:]) == 1
+}
+
+// basicAuthMiddleware rejects requests without valid basic auth credentials
+func (p *PluginUiRouter) basicAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || !p.basicAuthVerify(user, pass) {
+			w.Header().Set("WWW-Authenticate", "Basic realm="+strconv.Quote(p.basicAuthRealm)+", charset=\"UTF-8\"")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
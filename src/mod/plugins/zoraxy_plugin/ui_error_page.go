@@ -0,0 +1,89 @@
+package zoraxy_plugin
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/*
+	Ui_error_page.go
+
+	This file renders the HTML pages of the PluginUiRouter, including
+	the custom NotFoundFile / ErrorFile pages. Error pages get the same
+	CSRF, locale and CSP nonce substitution as normal pages and fall
+	back to a plain text response if the page is missing
+
+	Example:
+	uiRouter := NewPluginEmbedUIRouter(...)
+	uiRouter.NotFoundFile = "404.html"
+	uiRouter.ErrorFile = "50x.html"
+*/
+
+// templateHTML replaces the template placeholders of a HTML page and sets the related response headers
+func (p *PluginUiRouter) templateHTML(w http.ResponseWriter, r *http.Request, body string, csrfToken string) (string, error) {
+	body = strings.ReplaceAll(body, "{{.csrfToken}}", csrfToken)
+	if p.locales != nil {
+		body = p.localizeHTML(body, p.selectLocale(r))
+		w.Header().Add("Vary", "Accept-Language, Cookie")
+	}
+	if p.cspPolicy != "" {
+		//Use a new nonce for every response so injected scripts cannot guess it
+		nonce, err := generateCSPNonce()
+		if err != nil {
+			return "", err
+		}
+		body = strings.ReplaceAll(body, "{{.cspNonce}}", nonce)
+		w.Header().Set("Content-Security-Policy", p.withFrameAncestors(strings.ReplaceAll(p.cspPolicy, "{{.cspNonce}}", nonce)))
+	}
+	return body, nil
+}
+
+// serveErrorPage responds with the custom error page for the status code, or plain text if it is not set or missing
+func (p *PluginUiRouter) serveErrorPage(w http.ResponseWriter, r *http.Request, statusCode int, csrfToken string) {
+	errorFile := p.ErrorFile
+	if statusCode == http.StatusNotFound {
+		errorFile = p.NotFoundFile
+	}
+	if errorFile != "" && p.subFs != nil && isSafeFsRequestPath(errorFile) {
+		content, err := fs.ReadFile(p.subFs, strings.TrimPrefix(path.Clean("/"+errorFile), "/"))
+		if err == nil {
+			body, err := p.templateHTML(w, r, string(content), csrfToken)
+			if err == nil {
+				//Drop the headers set for the original file
+				w.Header().Del("ETag")
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("Cache-Control", "no-store")
+				//Set explicitly so HEAD responses carry the same Content-Length as GET
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				w.WriteHeader(statusCode)
+				if r.Method != http.MethodHead {
+					w.Write([]byte(body))
+				}
+				return
+			}
+		}
+		fmt.Println("[" + p.PluginID + "] UI router failed to serve error page " + errorFile)
+	}
+	if statusCode == http.StatusNotFound {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Internal Server Error", statusCode)
+}
+
+// fsPathExists checks if the request path exists in the UI filesystem
+func (p *PluginUiRouter) fsPathExists(requestPath string) bool {
+	if p.subFs == nil || !isSafeFsRequestPath(requestPath) {
+		return false
+	}
+	filePath := strings.TrimPrefix(path.Clean("/"+requestPath), "/")
+	if filePath == "" {
+		filePath = "."
+	}
+	_, err := fs.Stat(p.subFs, filePath)
+	return err == nil
+}
@@ -0,0 +1,81 @@
+package zoraxy_plugin
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+	Ui_frame_options.go
+
+	This file restricts who can frame the plugin UI, to prevent clickjacking
+	by a malicious page embedding it. The Zoraxy panel proxies the plugin UI
+	on its own origin (/plugin.ui/<plugin id>/), so the default 'self' keeps
+	the legitimate embedding working.
+
+	Every UI response carries a Content-Security-Policy frame-ancestors
+	directive, merged into the WithCSP policy on HTML pages, and the matching
+	X-Frame-Options header for older browsers ('self' is SAMEORIGIN and 'none'
+	is DENY, other origin lists cannot be expressed and omit the header).
+
+	Set HSTS to send Strict-Transport-Security when Zoraxy serves the UI over
+	HTTPS (X-Forwarded-Proto: https), e.g. "max-age=31536000"
+
+	Example:
+	uiRouter.FrameAncestors = []string{"'self'", "https://zoraxy.example.com"}
+	uiRouter.HSTS = "max-age=31536000"
+*/
+
+const (
+	FrameAncestors_Self = "'self'" //Only the Zoraxy panel origin may frame the UI (default)
+	FrameAncestors_None = "'none'" //The UI may not be framed at all, e.g. for plugins opened in a new tab
+)
+
+// frameAncestors returns the normalized frame-ancestors sources of the router
+func (p *PluginUiRouter) frameAncestors() []string {
+	if len(p.FrameAncestors) == 0 {
+		return []string{FrameAncestors_Self}
+	}
+	sources := make([]string, 0, len(p.FrameAncestors))
+	for _, source := range p.FrameAncestors {
+		//Accept the keywords with or without quotes
+		switch strings.Trim(strings.ToLower(source), "'") {
+		case "self":
+			source = FrameAncestors_Self
+		case "none":
+			source = FrameAncestors_None
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// frameAncestorsDirective returns the CSP frame-ancestors directive of the router
+func (p *PluginUiRouter) frameAncestorsDirective() string {
+	return "frame-ancestors " + strings.Join(p.frameAncestors(), " ")
+}
+
+// withFrameAncestors appends the frame-ancestors directive to the policy unless it sets its own
+func (p *PluginUiRouter) withFrameAncestors(policy string) string {
+	if strings.Contains(strings.ToLower(policy), "frame-ancestors") {
+		return policy
+	}
+	return strings.TrimSuffix(strings.TrimSpace(policy), ";") + "; " + p.frameAncestorsDirective()
+}
+
+// frameOptionsMiddleware sets the framing and transport security headers of UI responses
+func (p *PluginUiRouter) frameOptionsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ancestors := p.frameAncestors()
+		w.Header().Set("Content-Security-Policy", p.frameAncestorsDirective())
+		if len(ancestors) == 1 && ancestors[0] == FrameAncestors_Self {
+			w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		} else if len(ancestors) == 1 && ancestors[0] == FrameAncestors_None {
+			w.Header().Set("X-Frame-Options", "DENY")
+		}
+		if p.HSTS != "" && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+			w.Header().Set("Strict-Transport-Security", p.HSTS)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,135 @@
+package zoraxy_plugin
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Ui_locale.go
+
+	This file adds locale support to the HTML template injection of
+	PluginUiRouter. The locale of a request is selected from (in order)
+	the ?lang= query, the zoraxy_plugin_lang cookie and the
+	Accept-Language header, falling back to the fallback locale.
+
+	{{.i18n.key}} is replaced with the HTML escaped string of the
+	selected bundle (or the fallback bundle if the key is missing),
+	and {{.locale}} with the selected locale, e.g. <html lang="{{.locale}}">
+
+	Example:
+	uiRouter.WithLocales(map[string]map[string]string{
+		"en": {"title": "Settings"},
+		"zh-TW": {"title": "設定"},
+	}, "en")
+*/
+
+const (
+	LocaleQueryParam = "lang"
+	LocaleCookieName = "zoraxy_plugin_lang"
+)
+
+var i18nPlaceholderRegex = regexp.MustCompile(`{{\.i18n\.([A-Za-z0-9_.\-]+)}}`)
+
+// WithLocales sets the translation bundles keyed by locale (e.g. en, zh-TW) and the fallback locale
+// Call this before Handler()
+func (p *PluginUiRouter) WithLocales(bundles map[string]map[string]string, fallback string) *PluginUiRouter {
+	p.locales = bundles
+	p.localeFallback = fallback
+	return p
+}
+
+// selectLocale returns the locale of the bundle used for the request
+func (p *PluginUiRouter) selectLocale(r *http.Request) string {
+	if lang := r.URL.Query().Get(LocaleQueryParam); lang != "" {
+		if locale, ok := p.matchLocale(lang); ok {
+			return locale
+		}
+	}
+	if cookie, err := r.Cookie(LocaleCookieName); err == nil {
+		if locale, ok := p.matchLocale(cookie.Value); ok {
+			return locale
+		}
+	}
+	for _, lang := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if locale, ok := p.matchLocale(lang); ok {
+			return locale
+		}
+	}
+	return p.localeFallback
+}
+
+// matchLocale finds the bundle for the language tag, an exact match (case insensitive)
+// is preferred over a bundle with the same primary language (e.g. en-GB matches en)
+func (p *PluginUiRouter) matchLocale(lang string) (string, bool) {
+	primary, _, _ := strings.Cut(lang, "-")
+	partialMatch := ""
+	for locale := range p.locales {
+		if strings.EqualFold(locale, lang) {
+			return locale, true
+		}
+		localePrimary, _, _ := strings.Cut(locale, "-")
+		if strings.EqualFold(localePrimary, primary) && (partialMatch == "" || locale < partialMatch) {
+			partialMatch = locale
+		}
+	}
+	return partialMatch, partialMatch != ""
+}
+
+// localizeHTML replaces the i18n placeholders in the body with the strings of the locale
+func (p *PluginUiRouter) localizeHTML(body string, locale string) string {
+	body = strings.ReplaceAll(body, "{{.locale}}", html.EscapeString(locale))
+	return i18nPlaceholderRegex.ReplaceAllStringFunc(body, func(placeholder string) string {
+		key := i18nPlaceholderRegex.FindStringSubmatch(placeholder)[1]
+		if value, ok := p.locales[locale][key]; ok {
+			return html.EscapeString(value)
+		}
+		if value, ok := p.locales[p.localeFallback][key]; ok {
+			return html.EscapeString(value)
+		}
+		//Leave the key visible so missing translations are easy to spot
+		return html.EscapeString(key)
+	})
+}
+
+// parseAcceptLanguage returns the language tags of the Accept-Language header ordered by quality
+// Tags with a zero quality and the wildcard are dropped
+func parseAcceptLanguage(acceptLanguage string) []string {
+	type weightedTag struct {
+		tag     string
+		quality float64
+	}
+	tags := []weightedTag{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		params = strings.ReplaceAll(params, " ", "")
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+		tags = append(tags, weightedTag{tag: tag, quality: quality})
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+	results := make([]string, 0, len(tags))
+	for _, t := range tags {
+		results = append(results, t.tag)
+	}
+	return results
+}
@@ -0,0 +1,42 @@
+package zoraxy_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+/*
+	Ui_recover.go
+
+	This file provides the recover middleware of the UI router, so a
+	panic in the embedded file server, the CSRF token injection or a
+	custom middleware answers 500 Internal Server Error (or the ErrorFile)
+	instead of crashing the plugin process
+
+	The panic and its stack trace are printed to STDOUT, which Zoraxy
+	writes to its log. The middleware is always installed by Handler()
+*/
+
+func (p *PluginUiRouter) uiRecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				//Let net/http abort the connection as requested
+				panic(recovered)
+			}
+			fmt.Println("[" + p.PluginID + "] UI router panic while handling " + r.Method + " " + r.RequestURI + ": " + fmt.Sprint(recovered) + "\n" + string(debug.Stack()))
+			if sw.status != 0 {
+				//The response has been partially written, nothing else can be sent
+				return
+			}
+			p.serveErrorPage(sw, r, http.StatusInternalServerError, "")
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
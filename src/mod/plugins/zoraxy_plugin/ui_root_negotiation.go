@@ -0,0 +1,132 @@
+package zoraxy_plugin
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Ui_root_negotiation.go
+
+	This file lets the root of the UI router answer API clients and
+	browsers differently. Requests to the root are matched against the
+	Accept header, a media type registered with WithRootHandlers is
+	served by its handler, everything else (including browsers and
+	clients without an Accept header) gets the index.html as before
+
+	Example:
+	uiRouter.WithRootHandlers(map[string]http.Handler{
+		"application/json": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			zoraxy_plugin.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		}),
+	})
+*/
+
+// WithRootHandlers sets the handlers of the UI root keyed by media type (e.g. application/json)
+// A text/html handler replaces the index.html, call this before Handler()
+func (p *PluginUiRouter) WithRootHandlers(handlers map[string]http.Handler) *PluginUiRouter {
+	p.rootHandlers = map[string]http.Handler{}
+	for mediaType, handler := range handlers {
+		p.rootHandlers[strings.ToLower(mediaType)] = handler
+	}
+	return p
+}
+
+// serveNegotiatedRoot serves the root with the handler of the preferred media type, returns false to serve the index.html
+func (p *PluginUiRouter) serveNegotiatedRoot(w http.ResponseWriter, r *http.Request) bool {
+	if len(p.rootHandlers) == 0 || r.URL.Path != "/" {
+		return false
+	}
+	w.Header().Add("Vary", "Accept")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	mediaType := negotiateMediaType(r.Header.Get("Accept"), p.rootHandlers)
+	handler, ok := p.rootHandlers[mediaType]
+	if !ok {
+		return false
+	}
+	handler.ServeHTTP(w, r)
+	return true
+}
+
+// negotiateMediaType returns the media type preferred by the Accept header, text/html if the index.html is preferred
+func negotiateMediaType(accept string, handlers map[string]http.Handler) string {
+	offered := []string{"text/html"}
+	for mediaType := range handlers {
+		if mediaType != "text/html" {
+			offered = append(offered, mediaType)
+		}
+	}
+	//Keep the index.html first so it wins wildcards, then sort for a stable order
+	sort.Strings(offered[1:])
+
+	for _, mediaRange := range parseAccept(accept) {
+		for _, mediaType := range offered {
+			if matchesMediaRange(mediaRange, mediaType) {
+				return mediaType
+			}
+		}
+	}
+	return "text/html"
+}
+
+// parseAccept returns the media ranges of the Accept header ordered by quality, then by specificity
+// Ranges with a zero quality are dropped
+func parseAccept(accept string) []string {
+	type weightedRange struct {
+		mediaRange  string
+		quality     float64
+		specificity int
+	}
+	ranges := []weightedRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+		specificity := 2
+		if mediaRange == "*/*" {
+			specificity = 0
+		} else if strings.HasSuffix(mediaRange, "/*") {
+			specificity = 1
+		}
+		ranges = append(ranges, weightedRange{mediaRange: mediaRange, quality: quality, specificity: specificity})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].quality != ranges[j].quality {
+			return ranges[i].quality > ranges[j].quality
+		}
+		return ranges[i].specificity > ranges[j].specificity
+	})
+	results := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		results = append(results, r.mediaRange)
+	}
+	return results
+}
+
+// matchesMediaRange checks if the media type is matched by the media range, e.g. application/* matches application/json
+func matchesMediaRange(mediaRange string, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return false
+}
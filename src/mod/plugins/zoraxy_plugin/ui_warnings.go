@@ -0,0 +1,34 @@
+package zoraxy_plugin
+
+/*
+	Ui_warnings.go
+
+	This file defines the structured warnings returned by
+	IntroSpect.UIWarnings. Zoraxy renders them by severity before the
+	plugin is enabled, the code identifies the condition so the UI and
+	plugin authors agree on it regardless of the message wording
+*/
+
+type UIWarningSeverity string
+
+const (
+	UIWarningSeverity_Info    UIWarningSeverity = "info"    //Informational, e.g. a default behavior the user should know about
+	UIWarningSeverity_Warning UIWarningSeverity = "warning" //A likely misconfiguration or a broad capture scope
+	UIWarningSeverity_Danger  UIWarningSeverity = "danger"  //The plugin affects every request handled by Zoraxy
+)
+
+const (
+	UIWarningCode_GlobalCapture      = "global_capture"      //The plugin captures traffic of all HTTP proxy rules
+	UIWarningCode_CaptureAll         = "capture_all"         //A global capture rule matches every request
+	UIWarningCode_UndeclaredOutbound = "undeclared_outbound" //Outbound hosts are declared without the net.outbound permission
+	UIWarningCode_DefaultEnabled     = "default_enabled"     //The plugin is enabled on new HTTP proxy rules by default
+	UIWarningCode_NoCapturePath      = "no_capture_path"     //A router plugin without any capture mode
+	UIWarningCode_UnknownEvent       = "unknown_event"       //A subscription to an event Zoraxy never emits
+	UIWarningCode_IncompleteCapture  = "incomplete_capture"  //Capture paths without an ingress or the other way round
+)
+
+type UIWarning struct {
+	Code     string            `json:"code"`     //Machine readable condition, one of the UIWarningCode_* constants
+	Message  string            `json:"message"`  //Human readable message shown to the user
+	Severity UIWarningSeverity `json:"severity"` //Severity the warning is rendered with
+}
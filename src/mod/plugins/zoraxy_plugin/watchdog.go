@@ -0,0 +1,113 @@
+package zoraxy_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+	Watchdog.go
+
+	This file lets a plugin prove its liveness to Zoraxy beyond a TCP
+	connect. The watchdog prints a heartbeat JSON line to STDOUT on every
+	interval, Zoraxy keeps the time of the last heartbeat instead of
+	writing it to the log, so a hung-but-alive plugin can be detected.
+
+	With a HealthCheck, the heartbeat is only sent while the check passes,
+	and the plugin exits after MaxFailures consecutive failed checks so
+	Zoraxy can restart it
+
+	Example:
+	stop := zoraxy_plugin.StartWatchdog(10*time.Second, &zoraxy_plugin.WatchdogOptions{
+		HealthCheck: func() error { return db.Ping() },
+		MaxFailures: 3,
+	})
+	defer stop()
+*/
+
+// HeartbeatPrefix is the prefix of the heartbeat lines printed by the watchdog
+const HeartbeatPrefix = `{"zoraxy_heartbeat":`
+
+type Heartbeat struct {
+	PID       int   `json:"pid"`       //Process ID of the plugin
+	Timestamp int64 `json:"timestamp"` //Unix timestamp of the heartbeat in seconds
+}
+
+type WatchdogOptions struct {
+	HealthCheck func() error //Optional, the heartbeat is skipped if the check returns an error
+	MaxFailures int          //Consecutive failed health checks before the plugin exits, 0 to never exit
+}
+
+// StartWatchdog prints a heartbeat to STDOUT on every interval until the returned stop function is called
+// Pass nil opts to send the heartbeat unconditionally
+func StartWatchdog(interval time.Duration, opts *WatchdogOptions) (stop func()) {
+	if opts == nil {
+		opts = &WatchdogOptions{}
+	}
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			if opts.HealthCheck != nil {
+				if err := opts.HealthCheck(); err != nil {
+					failures++
+					fmt.Println("Watchdog health check failed (" + fmt.Sprint(failures) + " consecutive): " + err.Error())
+					if opts.MaxFailures > 0 && failures >= opts.MaxFailures {
+						fmt.Println("Watchdog health check failed too many times, exiting")
+						ExitFunc(1)
+						return
+					}
+				} else {
+					failures = 0
+				}
+			}
+			if failures == 0 {
+				printHeartbeat()
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		stopOnce.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// ParseHeartbeat parses a heartbeat line printed by the watchdog
+func ParseHeartbeat(line string) (*Heartbeat, bool) {
+	if len(line) < len(HeartbeatPrefix) || line[:len(HeartbeatPrefix)] != HeartbeatPrefix {
+		return nil, false
+	}
+	payload := struct {
+		Heartbeat *Heartbeat `json:"zoraxy_heartbeat"`
+	}{}
+	if err := json.Unmarshal([]byte(line), &payload); err != nil || payload.Heartbeat == nil {
+		return nil, false
+	}
+	return payload.Heartbeat, true
+}
+
+func printHeartbeat() {
+	js, _ := json.Marshal(Heartbeat{
+		PID:       os.Getpid(),
+		Timestamp: time.Now().Unix(),
+	})
+	fmt.Println(HeartbeatPrefix + string(js) + "}")
+}
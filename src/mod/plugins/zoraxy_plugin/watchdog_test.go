@@ -0,0 +1,44 @@
+package zoraxy_plugin
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseHeartbeat(t *testing.T) {
+	heartbeat, ok := ParseHeartbeat(HeartbeatPrefix + `{"pid":42,"timestamp":1700000000}}`)
+	if !ok || heartbeat.PID != 42 || heartbeat.Timestamp != 1700000000 {
+		t.Errorf("Unexpected heartbeat: %v %v", heartbeat, ok)
+	}
+	for _, line := range []string{"", "plugin started", HeartbeatPrefix + "broken"} {
+		if _, ok := ParseHeartbeat(line); ok {
+			t.Errorf("%q: expected not to be parsed as heartbeat", line)
+		}
+	}
+}
+
+func TestWatchdogExitsAfterMaxFailures(t *testing.T) {
+	originalExit := ExitFunc
+	defer func() {
+		ExitFunc = originalExit
+	}()
+	exitCode := make(chan int, 1)
+	ExitFunc = func(code int) {
+		exitCode <- code
+	}
+
+	stop := StartWatchdog(time.Millisecond, &WatchdogOptions{
+		HealthCheck: func() error { return errors.New("unhealthy") },
+		MaxFailures: 3,
+	})
+	defer stop()
+	select {
+	case code := <-exitCode:
+		if code != 1 {
+			t.Errorf("Expected exit code 1, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the watchdog to exit the plugin")
+	}
+}
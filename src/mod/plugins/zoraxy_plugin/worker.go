@@ -0,0 +1,145 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+	Worker.go
+
+	This file standardizes the lifecycle of background loops in
+	utility plugins (e.g. a sync loop or a process supervisor).
+
+	Register the workers before calling RecvConfigureSpec, the SDK
+	starts them in registration order once the configure spec is received,
+	and stops them in reverse order when Zoraxy calls the terminate handler
+	of the UI router. Call HandleSignals to also stop them and exit the
+	plugin on SIGTERM / SIGINT, the SDK does not install any signal handler
+	on its own so it never takes over the signals of the plugin
+
+	Start should return once the worker is running, long running loops
+	should be started in their own goroutine and end when the context passed
+	to Start is cancelled. Stop is called with a context that expires after
+	WorkerStopTimeout
+*/
+
+const WorkerStopTimeout = 10 * time.Second
+
+type Worker interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+var workerState struct {
+	mu      sync.Mutex
+	workers []Worker
+	started []Worker
+	running bool //The registered workers were started, servers added with addStartedWorker do not count
+	cancel  context.CancelFunc
+	signals sync.Once
+}
+
+// RegisterWorker registers a background worker, call this before RecvConfigureSpec
+func RegisterWorker(w Worker) {
+	workerState.mu.Lock()
+	defer workerState.mu.Unlock()
+	workerState.workers = append(workerState.workers, w)
+}
+
+// StopWorkers stops all started workers in reverse start order
+// It is called by the terminate handler of the UI router and on SIGTERM / SIGINT if HandleSignals was called
+func StopWorkers(ctx context.Context) error {
+	workerState.mu.Lock()
+	defer workerState.mu.Unlock()
+	return stopWorkersLocked(ctx)
+}
+
+// startWorkers starts the registered workers if they are not started yet
+func startWorkers() error {
+	workerState.mu.Lock()
+	defer workerState.mu.Unlock()
+	if len(workerState.workers) == 0 || workerState.running {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workerState.cancel = cancel
+	for _, w := range workerState.workers {
+		if err := w.Start(ctx); err != nil {
+			//Roll back the workers started so far
+			stopCtx, stopCancel := context.WithTimeout(context.Background(), WorkerStopTimeout)
+			stopWorkersLocked(stopCtx)
+			stopCancel()
+			return fmt.Errorf("failed to start worker: %w", err)
+		}
+		workerState.started = append(workerState.started, w)
+	}
+	workerState.running = true
+	return nil
+}
+
+// addStartedWorker registers a worker that is already running (e.g. the plugin web server)
+// so it is stopped together with the other workers
+func addStartedWorker(w Worker) {
+	workerState.mu.Lock()
+	workerState.started = append(workerState.started, w)
+	workerState.mu.Unlock()
+}
+
+// removeStartedWorker removes a worker added with addStartedWorker without stopping it
+func removeStartedWorker(w Worker) {
+	workerState.mu.Lock()
+	defer workerState.mu.Unlock()
+	for i, started := range workerState.started {
+		if started == w {
+			workerState.started = append(workerState.started[:i], workerState.started[i+1:]...)
+			return
+		}
+	}
+}
+
+// HandleSignals stops the workers and exits the plugin with ExitFunc(0) on SIGTERM / SIGINT
+// Calling it more than once has no effect. Plugins with their own signal handling should call StopWorkers instead
+func HandleSignals() {
+	workerState.signals.Do(func() {
+		go handleWorkerSignals()
+	})
+}
+
+func stopWorkersLocked(ctx context.Context) error {
+	var errs []error
+	for i := len(workerState.started) - 1; i >= 0; i-- {
+		if err := workerState.started[i].Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	workerState.started = nil
+	workerState.running = false
+	if workerState.cancel != nil {
+		workerState.cancel()
+		workerState.cancel = nil
+	}
+	return errors.Join(errs...)
+}
+
+// handleWorkerSignals stops the workers and exits the plugin on SIGTERM / SIGINT
+func handleWorkerSignals() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
+	<-sigChan
+	signal.Stop(sigChan)
+
+	ctx, cancel := context.WithTimeout(context.Background(), WorkerStopTimeout)
+	defer cancel()
+	if err := StopWorkers(ctx); err != nil {
+		fmt.Println("Failed to stop workers: " + err.Error())
+	}
+	ExitFunc(0)
+}
@@ -0,0 +1,88 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testWorker struct {
+	name     string
+	startErr error
+	events   *[]string
+}
+
+func (w *testWorker) Start(ctx context.Context) error {
+	if w.startErr != nil {
+		return w.startErr
+	}
+	*w.events = append(*w.events, "start "+w.name)
+	return nil
+}
+
+func (w *testWorker) Stop(ctx context.Context) error {
+	*w.events = append(*w.events, "stop "+w.name)
+	return nil
+}
+
+func TestWorkerLifecycle(t *testing.T) {
+	defer func() {
+		workerState.workers = nil
+	}()
+
+	events := []string{}
+	RegisterWorker(&testWorker{name: "a", events: &events})
+	RegisterWorker(&testWorker{name: "b", events: &events})
+	if err := startWorkers(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := StopWorkers(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"start a", "start b", "stop b", "stop a"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, events)
+			break
+		}
+	}
+
+	//A failing worker rolls back the workers started before it
+	events = []string{}
+	RegisterWorker(&testWorker{name: "c", startErr: errors.New("boom"), events: &events})
+	if err := startWorkers(); err == nil {
+		t.Fatal("Expected an error from the failing worker")
+	}
+	if len(events) != 4 || events[3] != "stop a" {
+		t.Errorf("Expected the started workers to be stopped, got %v", events)
+	}
+}
+
+func TestStartWorkersAfterStartedServer(t *testing.T) {
+	defer func() {
+		workerState.workers = nil
+	}()
+
+	//A server added with addStartedWorker must not keep the registered workers from starting
+	events := []string{}
+	addStartedWorker(&testWorker{name: "server", events: &events})
+	RegisterWorker(&testWorker{name: "a", events: &events})
+	if err := startWorkers(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	//Starting again is a no-op while the workers are running
+	if err := startWorkers(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := StopWorkers(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "start a,stop a,stop server"
+	if strings.Join(events, ",") != expected {
+		t.Errorf("Expected %s, got %v", expected, events)
+	}
+}
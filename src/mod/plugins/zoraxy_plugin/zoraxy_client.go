@@ -0,0 +1,214 @@
+package zoraxy_plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Zoraxy_client.go
+
+	This file provides the client of the plugin for calling Zoraxy. The
+	client authenticates with the EventSecret of the ConfigureSpec and
+	talks to the ZoraxyAPIURL, which is empty if Zoraxy does not offer a
+	plugin API.
+
+	SubscribeStream opens a long lived SSE stream to receive the
+	subscribed events continuously instead of one POST per event to the
+	SubscriptionPath. The stream is reconnected with exponential backoff
+	and jitter, resuming from the id of the last received event
+
+	Example:
+	client, err := zoraxy_plugin.NewZoraxyClient(configureSpec)
+	if err != nil {
+		fmt.Println("Event stream not available: " + err.Error())
+		return
+	}
+	go client.SubscribeStream(ctx, []string{zoraxy_plugin.EventName_CertRenewed}, func(event zoraxy_plugin.SubscriptionEvent) {
+		fmt.Println("Received " + event.EventName)
+	})
+*/
+
+// SubscriptionStreamPath is the path of the SSE event stream relative to ConfigureSpec.ZoraxyAPIURL
+const SubscriptionStreamPath = "/events/stream"
+
+type ZoraxyClient struct {
+	BaseURL           string        //Base URL of the Zoraxy plugin API, see ConfigureSpec.ZoraxyAPIURL
+	Secret            string        //Secret sent as bearer token, see ConfigureSpec.EventSecret
+	HTTPClient        *http.Client  //Client used for the requests, default a client without timeout for the streams
+	ReconnectDelay    time.Duration //Delay before the first reconnect, doubled on every failed attempt, default 1s
+	MaxReconnectDelay time.Duration //Max delay between reconnects, default 30s
+}
+
+// NewZoraxyClient creates a client for the Zoraxy plugin API announced in the ConfigureSpec
+func NewZoraxyClient(spec *ConfigureSpec) (*ZoraxyClient, error) {
+	if spec.ZoraxyAPIURL == "" {
+		return nil, errors.New("zoraxy did not announce a plugin API URL")
+	}
+	if _, err := url.Parse(spec.ZoraxyAPIURL); err != nil {
+		return nil, fmt.Errorf("invalid zoraxy API URL: %w", err)
+	}
+	return &ZoraxyClient{
+		BaseURL: strings.TrimSuffix(spec.ZoraxyAPIURL, "/"),
+		Secret:  spec.EventSecret,
+	}, nil
+}
+
+// SubscribeStream receives the events over SSE until ctx is cancelled, reconnecting on failures
+// onEvent is called from the stream goroutine. Returns ctx.Err() once cancelled, or an error if
+// Zoraxy rejects the subscription (e.g. an invalid secret or an unknown event)
+func (c *ZoraxyClient) SubscribeStream(ctx context.Context, events []string, onEvent func(event SubscriptionEvent)) error {
+	for _, event := range events {
+		if !IsValidEventName(event) {
+			return errors.New("unknown event name: " + event)
+		}
+	}
+
+	reconnectDelay := c.ReconnectDelay
+	if reconnectDelay <= 0 {
+		reconnectDelay = time.Second
+	}
+	maxReconnectDelay := c.MaxReconnectDelay
+	if maxReconnectDelay <= 0 {
+		maxReconnectDelay = 30 * time.Second
+	}
+
+	lastEventID := ""
+	failures := 0
+	for {
+		received, retry, err := c.readEventStream(ctx, events, &lastEventID, onEvent)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var rejected *streamRejectedError
+		if errors.As(err, &rejected) {
+			return err
+		}
+		if retry > 0 {
+			reconnectDelay = retry
+		}
+		if received {
+			//The stream was working, start the backoff over
+			failures = 0
+		}
+		failures++
+		delay := reconnectDelay << (failures - 1)
+		if delay <= 0 || delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+		//Jitter in [delay/2, delay] so plugins do not reconnect together after a Zoraxy restart
+		half := delay / 2
+		delay = half + time.Duration(rand.Int63n(int64(half)+1))
+		if err != nil {
+			fmt.Println("[events] Event stream disconnected, reconnecting in " + delay.Round(time.Millisecond).String() + ": " + err.Error())
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+type streamRejectedError struct {
+	status int
+}
+
+func (e *streamRejectedError) Error() string {
+	return "zoraxy rejected the event stream with status " + strconv.Itoa(e.status)
+}
+
+// readEventStream reads a single connection of the stream, returns if any event was received and the retry interval sent by Zoraxy
+func (c *ZoraxyClient) readEventStream(ctx context.Context, events []string, lastEventID *string, onEvent func(event SubscriptionEvent)) (bool, time.Duration, error) {
+	streamURL := c.BaseURL + SubscriptionStreamPath + "?events=" + url.QueryEscape(strings.Join(events, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if c.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Secret)
+	}
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return false, 0, &streamRejectedError{status: resp.StatusCode}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, 0, errors.New("unexpected status " + resp.Status)
+	}
+
+	received := false
+	var retry time.Duration
+	var data strings.Builder
+	eventID := ""
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return received, retry, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			//A blank line dispatches the event
+			if data.Len() > 0 {
+				var event SubscriptionEvent
+				if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+					fmt.Println("[events] Ignored malformed event: " + err.Error())
+				} else {
+					received = true
+					onEvent(event)
+				}
+			}
+			if eventID != "" {
+				*lastEventID = eventID
+			}
+			data.Reset()
+			eventID = ""
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			//Comment, used by Zoraxy as keep alive
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "id":
+			eventID = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
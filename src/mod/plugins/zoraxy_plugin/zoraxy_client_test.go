@@ -0,0 +1,67 @@
+package zoraxy_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubscribeStream(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != SubscriptionStreamPath || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch connections.Add(1) {
+		case 1:
+			//Close the stream after the first event to force a reconnect
+			w.Write([]byte(": keep alive\nretry: 10\nid: 1\ndata: {\"event_name\":\"cert_renewed\",\n"))
+			w.Write([]byte("data: \"event_source\":\"acme\"}\n\n"))
+		default:
+			if r.Header.Get("Last-Event-ID") != "1" {
+				t.Errorf("Expected the stream to resume from event 1, got %q", r.Header.Get("Last-Event-ID"))
+			}
+			w.Write([]byte("id: 2\ndata: {\"event_name\":\"proxy_rule_added\"}\n\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewZoraxyClient(&ConfigureSpec{ZoraxyAPIURL: server.URL + "/", EventSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := []SubscriptionEvent{}
+	err = client.SubscribeStream(ctx, []string{EventName_CertRenewed, EventName_ProxyRuleAdded}, func(event SubscriptionEvent) {
+		received = append(received, event)
+		if len(received) == 2 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected the stream to end with the cancelled context, got %v", err)
+	}
+	if len(received) != 2 || received[0].EventName != EventName_CertRenewed || received[0].EventSource != "acme" || received[1].EventName != EventName_ProxyRuleAdded {
+		t.Errorf("Unexpected events %+v", received)
+	}
+
+	//Rejected subscriptions are not retried
+	client.Secret = "wrong"
+	if err := client.SubscribeStream(context.Background(), []string{EventName_CertRenewed}, func(SubscriptionEvent) {}); err == nil {
+		t.Error("Expected the rejected stream to return an error")
+	}
+	if err := client.SubscribeStream(context.Background(), []string{"not_an_event"}, func(SubscriptionEvent) {}); err == nil {
+		t.Error("Expected an unknown event to be rejected")
+	}
+	if _, err := NewZoraxyClient(&ConfigureSpec{}); err == nil {
+		t.Error("Expected an error without the zoraxy API URL")
+	}
+}
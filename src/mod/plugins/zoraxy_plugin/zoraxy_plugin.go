@@ -1,10 +1,22 @@
 package zoraxy_plugin
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 /*
@@ -18,15 +30,50 @@ import (
 type PluginType int
 
 const (
-	PluginType_Router    PluginType = 0 //Router Plugin, used for handling / routing / forwarding traffic
-	PluginType_Utilities PluginType = 1 //Utilities Plugin, used for utilities like Zerotier or Static Web Server that do not require interception with the dpcore
+	PluginType_Router       PluginType = 0 //Router Plugin, used for handling / routing / forwarding traffic
+	PluginType_Utilities    PluginType = 1 //Utilities Plugin, used for utilities like Zerotier or Static Web Server that do not require interception with the dpcore
+	PluginType_TLSInspector PluginType = 2 //TLS Inspector Plugin, used for inspecting the SNI / ALPN of incoming TLS connections before they are routed
 )
 
 type CaptureRule struct {
-	CapturePath     string `json:"capture_path"`
-	IncludeSubPaths bool   `json:"include_sub_paths"`
+	CapturePath     string            `json:"capture_path"`
+	IncludeSubPaths bool              `json:"include_sub_paths"`
+	HeaderMatch     map[string]string `json:"header_match,omitempty"` //Optional header name to value pattern conditions, see MatchesHeaders
 }
 
+// Matches checks if the request path is captured by the rule
+// The match is case sensitive and a trailing slash on either side is ignored.
+// With IncludeSubPaths, sub paths only match on a path boundary (/api matches /api/v1 but not /apiv1)
+// HeaderMatch is not checked, use MatchesRequest to check both the path and the headers
+func (r CaptureRule) Matches(requestPath string) bool {
+	rulePath := "/" + strings.Trim(r.CapturePath, "/")
+	requestPath = "/" + strings.TrimPrefix(requestPath, "/")
+	if requestPath != "/" {
+		requestPath = strings.TrimSuffix(requestPath, "/")
+	}
+	if requestPath == rulePath {
+		return true
+	}
+	if !r.IncludeSubPaths {
+		return false
+	}
+	if rulePath == "/" {
+		return true
+	}
+	return strings.HasPrefix(requestPath, rulePath+"/")
+}
+
+/*
+Control Status Code
+
+The status code returned by the plugin capture handler tells Zoraxy how to
+continue with the request. For streaming responses (see CaptureStream) the
+CAPTURED status is sent before the body, and the connection stays open
+until the plugin handler returns or the client disconnects
+
+Every code must be unique on the wire, the codes of the other protocols
+are declared next to them: REWRITE (286), UPGRADE (287) and FILTER (288)
+*/
 type ControlStatusCode int
 
 const (
@@ -35,6 +82,54 @@ const (
 	ControlStatusCode_ERROR     ControlStatusCode = 580 //Error occurred while processing the traffic, ask Zoraxy to process the traffic and log the error
 )
 
+/*
+Capture Mode
+
+The capture modes a router plugin can declare, used by Zoraxy to
+present defaults and warnings when the plugin is enabled on a rule
+*/
+type CaptureMode string
+
+const (
+	CaptureMode_Global  CaptureMode = "global"  //Captures the traffic of all HTTP proxy rules, see GlobalCapturePaths
+	CaptureMode_Always  CaptureMode = "always"  //Captures the traffic of the HTTP proxy rules the plugin is enabled on, see AlwaysCapturePaths
+	CaptureMode_Dynamic CaptureMode = "dynamic" //Decides per request if the traffic is captured, see DynamicIngresses
+)
+
+type UITab struct {
+	Title string `json:"title"`          //Title of the sidebar link
+	Path  string `json:"path"`           //Path of the page relative to UIPath (e.g. /settings.html)
+	Icon  string `json:"icon,omitempty"` //Optional icon of the sidebar link, data URI or a path served by your plugin UI
+}
+
+const (
+	UIEmbedMode_IFrame = "iframe" //Plugin UI is embedded in the Zoraxy web UI (default)
+	UIEmbedMode_Tab    = "tab"    //Plugin UI is opened in a new browser tab
+)
+
+/*
+UICapabilities
+
+Presentation preferences of the plugin UI, Zoraxy decides how to present
+the plugin UI based on these. Leave it nil for the defaults, see GetUICapabilities
+*/
+type UICapabilities struct {
+	PrefersDarkModeSync bool   `json:"prefers_dark_mode_sync,omitempty"` //Plugin UI follows the dark mode setting of Zoraxy (e.g. via the prefers-color-scheme of the embedding page)
+	EmbedMode           string `json:"embed_mode,omitempty"`             //How the plugin UI is opened, UIEmbedMode_IFrame (default) or UIEmbedMode_Tab
+	MinWidth            int    `json:"min_width,omitempty"`              //Min width in pixels the plugin UI needs, Zoraxy opens it in a new tab if the viewport is narrower, 0 for no limit
+}
+
+// Validate checks if the UI capabilities are valid
+func (c *UICapabilities) Validate() error {
+	if c.EmbedMode != "" && c.EmbedMode != UIEmbedMode_IFrame && c.EmbedMode != UIEmbedMode_Tab {
+		return errors.New("invalid plugin UI embed mode: " + c.EmbedMode)
+	}
+	if c.MinWidth < 0 {
+		return errors.New("plugin UI min width must not be negative")
+	}
+	return nil
+}
+
 type SubscriptionEvent struct {
 	EventName   string `json:"event_name"`
 	EventSource string `json:"event_source"`
@@ -42,8 +137,9 @@ type SubscriptionEvent struct {
 }
 
 type RuntimeConstantValue struct {
-	ZoraxyVersion string `json:"zoraxy_version"`
-	ZoraxyUUID    string `json:"zoraxy_uuid"`
+	ZoraxyVersion   string `json:"zoraxy_version"`
+	ZoraxyUUID      string `json:"zoraxy_uuid"`
+	ExternalBaseURL string `json:"external_base_url,omitempty"` //Externally visible URL of this plugin UI mount (e.g. https://zoraxy.example.com:8000/plugin.ui/com.example.myplugin), empty if unknown
 }
 
 /*
@@ -60,10 +156,16 @@ type IntroSpect struct {
 	AuthorContact string     `json:"author_contact"` //Author contact of your plugin, like email
 	Description   string     `json:"description"`    //Description of your plugin
 	URL           string     `json:"url"`            //URL of your plugin
-	Type          PluginType `json:"type"`           //Type of your plugin, Router(0) or Utilities(1)
+	Type          PluginType `json:"type"`           //Type of your plugin, Router(0), Utilities(1) or TLSInspector(2)
 	VersionMajor  int        `json:"version_major"`  //Major version of your plugin
 	VersionMinor  int        `json:"version_minor"`  //Minor version of your plugin
 	VersionPatch  int        `json:"version_patch"`  //Patch version of your plugin
+	Icon          string     `json:"icon,omitempty"` //Icon of your plugin, either a data URI (see SetIconFromFS) or a path served by your plugin UI (e.g. /ui/icon.png)
+
+	ProtocolVersion int `json:"protocol_version,omitempty"` //Wire protocol version of the SDK, filled in by ServeIntroSpect if not set
+
+	License   string `json:"license,omitempty"`    //SPDX license identifier or expression of your plugin (e.g. MIT or Apache-2.0 OR MIT)
+	SourceURL string `json:"source_url,omitempty"` //URL of the source code of your plugin
 
 	/*
 
@@ -90,29 +192,477 @@ type IntroSpect struct {
 	AlwaysCapturePaths   []CaptureRule `json:"always_capture_path"`    //Always capture path of your plugin when enabled on a HTTP Proxy rule (e.g. /myapp)
 	AlwaysCaptureIngress string        `json:"always_capture_ingress"` //Always capture ingress path of your plugin when enabled on a HTTP Proxy rule (e.g. /a_handler)
 
+	/*
+		Capture Priority
+
+		When several plugins capture the same request, Zoraxy invokes
+		them by descending priority, plugins with the same priority are
+		ordered by ID. See CompareCapturePriority
+	*/
+	CapturePriority int `json:"capture_priority,omitempty"` //Capture priority of your plugin, CapturePriority_Default (0) if not set
+
+	/*
+		Default Capture Settings
+
+		Whether the plugin is enabled on newly created HTTP Proxy rules,
+		and which capture mode the Zoraxy UI should preselect for them
+	*/
+	DefaultEnabled     bool        `json:"default_enabled,omitempty"`      //Enable the plugin on new HTTP Proxy rules by default
+	DefaultCaptureMode CaptureMode `json:"default_capture_mode,omitempty"` //Capture mode preselected when the plugin is enabled on a rule, must be one of CaptureModes()
+
+	/*
+		Dynamic Capture Settings
+
+		Zoraxy asks the capture ingress of every pair whether to capture
+		the request, see DynamicIngress. Use DynamicIngresses to declare
+		several named pairs
+	*/
+	DynamicCaptureIngress string           `json:"dynamic_capture_ingress,omitempty"` //Dynamic capture (sniff) ingress path of your plugin (e.g. /d_sniff)
+	DynamicHandleIngress  string           `json:"dynamic_handle_ingress,omitempty"`  //Dynamic handle ingress path of your plugin (e.g. /d_handler)
+	DynamicIngresses      []DynamicIngress `json:"dynamic_ingresses,omitempty"`       //Additional named dynamic capture ingress pairs
+
+	/*
+		Response Filter Settings
+
+		Capture handlers answering ControlStatusCode_FILTER let Zoraxy
+		fetch the upstream response and send it to this ingress for
+		modification, see ResponseFilterRequest
+	*/
+	ResponseFilterIngress string `json:"response_filter_ingress,omitempty"` //Response filter ingress path of your plugin (e.g. /filter)
+
+	/*
+		SNI Inspection Settings
+
+		Only used by TLSInspector plugins. Zoraxy sends the SNI and ALPN
+		of every incoming TLS connection to this ingress before the
+		handshake completes, see SNIInspectRequest
+	*/
+	SNIInspectIngress string `json:"sni_inspect_ingress,omitempty"` //SNI inspection ingress path of your plugin (e.g. /sni_handler)
+
+	/*
+		Port Settings
+
+		By default Zoraxy assigns a random port to your plugin. Plugins
+		wrapping a backend that must bind a fixed port can request one,
+		check ConfigureSpec.PortGranted to see if the request was honored
+	*/
+	PreferredPort int     `json:"preferred_port,omitempty"` //Port your plugin prefers to listen on, 0 for no preference
+	PortRange     *[2]int `json:"port_range,omitempty"`     //Inclusive port range your plugin accepts (e.g. &[2]int{8100, 8200}), nil for no preference
+
 	/* UI Path for your plugin */
-	UIPath string `json:"ui_path"` //UI path of your plugin (e.g. /ui), will proxy the whole subpath tree to Zoraxy Web UI as plugin UI
+	UIPath string  `json:"ui_path"`           //UI path of your plugin (e.g. /ui), will proxy the whole subpath tree to Zoraxy Web UI as plugin UI
+	UITabs []UITab `json:"ui_tabs,omitempty"` //Optional named UI entry points shown as separate sidebar links, leave empty to show UIPath only
+
+	UICapabilities *UICapabilities `json:"ui_capabilities,omitempty"` //Optional presentation preferences of the plugin UI
+	OpenAPIPath    string          `json:"openapi_path,omitempty"`    //Optional path of the OpenAPI spec of your plugin API relative to UIPath (e.g. /openapi.json), see ServeOpenAPI
+
+	/* Plugin Settings, rendered by Zoraxy as a standard form and passed in ConfigureSpec.Options */
+	SettingsSchema []SettingField `json:"settings_schema,omitempty"` //Settings the user can configure for your plugin, see SettingField
 
 	/* Subscriptions Settings */
 	SubscriptionPath    string            `json:"subscription_path"`    //Subscription event path of your plugin (e.g. /notifyme), a POST request with SubscriptionEvent as body will be sent to this path when the event is triggered
-	SubscriptionsEvents map[string]string `json:"subscriptions_events"` //Subscriptions events of your plugin, see Zoraxy documentation for more details
+	SubscriptionsEvents map[string]string `json:"subscriptions_events"` //Subscriptions events of your plugin, keyed by event name (see EventName_*) with a description of why it is needed
+
+	/* Permissions */
+	Permissions   []string `json:"permissions,omitempty"`    //Permissions required by your plugin (e.g. net.outbound), shown to the user on install
+	OutboundHosts []string `json:"outbound_hosts,omitempty"` //External hosts your plugin contacts in host:port format (e.g. api.letsencrypt.org:443), *.example.com:443 matches subdomains
+	Conflicts     []string `json:"conflicts,omitempty"`      //IDs of incompatible plugins or capture path globs (e.g. /api/*) your plugin conflicts with, see ConflictsWith
+
+	/* TLS Settings, only needed if your plugin terminates TLS connections itself */
+	TLSPolicy *TLSPolicy `json:"tls_policy,omitempty"` //Minimum TLS version and cipher suites accepted by your plugin
+}
+
+/*
+Plugin Permissions
+
+Declare the permissions your plugin requires in IntroSpect.Permissions
+so Zoraxy can show a permission summary before the plugin is enabled
+*/
+const (
+	Permission_NetOutbound       = "net.outbound"       //Plugin makes outbound network connections
+	Permission_FsRead            = "fs.read"            //Plugin reads files outside of its own plugin folder
+	Permission_FsWrite           = "fs.write"           //Plugin writes files to disk
+	Permission_ZoraxyReadConfig  = "zoraxy.readconfig"  //Plugin reads Zoraxy configurations
+	Permission_ZoraxyWriteConfig = "zoraxy.writeconfig" //Plugin modifies Zoraxy configurations
+)
+
+// KnownPermissions returns all the permission strings that are recognized by this SDK version
+func KnownPermissions() []string {
+	return []string{
+		Permission_NetOutbound,
+		Permission_FsRead,
+		Permission_FsWrite,
+		Permission_ZoraxyReadConfig,
+		Permission_ZoraxyWriteConfig,
+	}
+}
+
+// Max size of the plugin icon after decoding
+const MaxIconSize = 256 * 1024
+
+/*
+SetIconFromFS Function
+
+This function reads an SVG / PNG icon from the given fs (e.g. your embed.FS)
+and sets it as the plugin icon in data URI form
+*/
+func (i *IntroSpect) SetIconFromFS(iconFs fs.FS, name string) error {
+	content, err := fs.ReadFile(iconFs, name)
+	if err != nil {
+		return err
+	}
+	if len(content) > MaxIconSize {
+		return fmt.Errorf("plugin icon is too large (%d bytes), max size is %d bytes", len(content), MaxIconSize)
+	}
+
+	var mimeType string
+	switch strings.ToLower(path.Ext(name)) {
+	case ".svg":
+		mimeType = "image/svg+xml"
+	case ".png":
+		mimeType = "image/png"
+	default:
+		return errors.New("plugin icon must be a .svg or .png file")
+	}
+	i.Icon = "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(content)
+	return nil
+}
+
+// DecodeIcon decodes the data URI icon and return its mime type and content
+func (i *IntroSpect) DecodeIcon() (string, []byte, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(i.Icon, "data:"), ",")
+	if !strings.HasPrefix(i.Icon, "data:") || !ok || !strings.HasSuffix(header, ";base64") {
+		return "", nil, errors.New("plugin icon is not a base64 data URI")
+	}
+	mimeType := strings.TrimSuffix(header, ";base64")
+	if mimeType != "image/svg+xml" && mimeType != "image/png" {
+		return "", nil, errors.New("plugin icon must be image/svg+xml or image/png")
+	}
+	if len(payload) > base64.StdEncoding.EncodedLen(MaxIconSize) {
+		return "", nil, errors.New("plugin icon is too large")
+	}
+	content, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(content) > MaxIconSize {
+		return "", nil, errors.New("plugin icon is too large")
+	}
+	return mimeType, content, nil
+}
+
+/*
+Validate Function
+
+This function checks if the IntroSpect contains all the
+required fields and only uses values known by Zoraxy
+*/
+func (i *IntroSpect) Validate() error {
+	if i.Name == "" {
+		return errors.New("plugin name is empty")
+	}
+	if i.Description == "" {
+		return errors.New("plugin description is empty")
+	}
+	if i.Author == "" {
+		return errors.New("plugin author is empty")
+	}
+	if i.UIPath == "" {
+		return errors.New("plugin UI path is empty")
+	}
+	if i.ID == "" {
+		return errors.New("plugin ID is empty")
+	}
+
+	knownPermissions := KnownPermissions()
+	for _, permission := range i.Permissions {
+		if !slices.Contains(knownPermissions, permission) {
+			return fmt.Errorf("unknown plugin permission: %s", permission)
+		}
+	}
+
+	if err := i.ValidateOutboundHosts(); err != nil {
+		return err
+	}
+
+	if err := i.validateConflicts(); err != nil {
+		return err
+	}
+
+	if err := i.validateLicense(); err != nil {
+		return err
+	}
+
+	for _, rule := range append(slices.Clone(i.GlobalCapturePaths), i.AlwaysCapturePaths...) {
+		if err := rule.validateHeaderMatch(); err != nil {
+			return err
+		}
+	}
+
+	if err := i.validateCapturePaths(); err != nil {
+		return err
+	}
+
+	if err := i.validateSettingsSchema(); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(i.Icon, "data:") {
+		if _, _, err := i.DecodeIcon(); err != nil {
+			return err
+		}
+	} else if i.Icon != "" && !strings.HasPrefix(i.Icon, "/") {
+		return errors.New("plugin icon must be a data URI or an absolute path (e.g. /ui/icon.png)")
+	}
+
+	tabPaths := map[string]bool{}
+	for _, tab := range i.UITabs {
+		if tab.Title == "" {
+			return errors.New("plugin UI tab title is empty")
+		}
+		if !strings.HasPrefix(tab.Path, "/") {
+			return errors.New("plugin UI tab path must start with /: " + tab.Path)
+		}
+		if tabPaths[tab.Path] {
+			return errors.New("duplicated plugin UI tab path: " + tab.Path)
+		}
+		tabPaths[tab.Path] = true
+	}
+
+	if i.ResponseFilterIngress != "" && !strings.HasPrefix(i.ResponseFilterIngress, "/") {
+		return errors.New("plugin response filter ingress must start with /: " + i.ResponseFilterIngress)
+	}
+
+	if i.OpenAPIPath != "" && (!strings.HasPrefix(i.OpenAPIPath, "/") || strings.ContainsAny(i.OpenAPIPath, "\"'<> \t\r\n")) {
+		return errors.New("plugin OpenAPI path must start with / and must not contain quotes, <, > or whitespace: " + i.OpenAPIPath)
+	}
+
+	if i.UICapabilities != nil {
+		if err := i.UICapabilities.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := i.validateDynamicIngresses(); err != nil {
+		return err
+	}
+
+	if i.DefaultCaptureMode != "" && !slices.Contains(i.CaptureModes(), i.DefaultCaptureMode) {
+		return errors.New("plugin default capture mode " + string(i.DefaultCaptureMode) + " is not declared by the plugin")
+	}
+
+	if err := i.validatePortPreference(); err != nil {
+		return err
+	}
+
+	if i.Type == PluginType_TLSInspector && !strings.HasPrefix(i.SNIInspectIngress, "/") {
+		return errors.New("TLS inspector plugin must declare an SNI inspect ingress starting with /")
+	}
+
+	if i.TLSPolicy != nil {
+		if err := i.TLSPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateOutboundHosts checks if every OutboundHosts entry is in host:port format
+func (i *IntroSpect) ValidateOutboundHosts() error {
+	for _, outboundHost := range i.OutboundHosts {
+		host, port, err := net.SplitHostPort(outboundHost)
+		if err != nil {
+			return fmt.Errorf("plugin outbound host %q is not in host:port format", outboundHost)
+		}
+		portNumber, err := strconv.Atoi(port)
+		if err != nil || portNumber < 1 || portNumber > 65535 {
+			return fmt.Errorf("plugin outbound host %q has an invalid port", outboundHost)
+		}
+		if _, err := netip.ParseAddr(host); err == nil {
+			continue
+		}
+		if !isValidOutboundHostname(strings.TrimPrefix(host, "*.")) {
+			return fmt.Errorf("plugin outbound host %q has an invalid hostname", outboundHost)
+		}
+	}
+	return nil
+}
+
+// isValidOutboundHostname checks if the hostname only contains valid DNS labels
+func isValidOutboundHostname(hostname string) bool {
+	if hostname == "" || len(hostname) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validatePortPreference checks if the PreferredPort and PortRange are valid ports
+func (i *IntroSpect) validatePortPreference() error {
+	if i.PreferredPort < 0 || i.PreferredPort > 65535 {
+		return fmt.Errorf("plugin preferred port %d is not a valid port", i.PreferredPort)
+	}
+	if i.PortRange == nil {
+		return nil
+	}
+	lo, hi := i.PortRange[0], i.PortRange[1]
+	if lo < 1 || hi > 65535 || lo > hi {
+		return fmt.Errorf("plugin port range [%d, %d] is not a valid range", lo, hi)
+	}
+	if i.PreferredPort != 0 && (i.PreferredPort < lo || i.PreferredPort > hi) {
+		return fmt.Errorf("plugin preferred port %d is outside of its port range [%d, %d]", i.PreferredPort, lo, hi)
+	}
+	return nil
+}
+
+// GetUICapabilities returns the UI capabilities of the plugin with the defaults filled in
+func (i *IntroSpect) GetUICapabilities() UICapabilities {
+	capabilities := UICapabilities{}
+	if i.UICapabilities != nil {
+		capabilities = *i.UICapabilities
+	}
+	if capabilities.EmbedMode == "" {
+		capabilities.EmbedMode = UIEmbedMode_IFrame
+	}
+	return capabilities
+}
+
+// CaptureModes returns the capture modes declared by the plugin
+func (i *IntroSpect) CaptureModes() []CaptureMode {
+	modes := []CaptureMode{}
+	if i.GlobalCaptureIngress != "" && len(i.GlobalCapturePaths) > 0 {
+		modes = append(modes, CaptureMode_Global)
+	}
+	if i.AlwaysCaptureIngress != "" && len(i.AlwaysCapturePaths) > 0 {
+		modes = append(modes, CaptureMode_Always)
+	}
+	if len(i.DynamicIngressList()) > 0 {
+		modes = append(modes, CaptureMode_Dynamic)
+	}
+	return modes
+}
+
+/*
+Warnings Function
+
+This function returns human readable warnings about the IntroSpect
+that Zoraxy shows to the user before the plugin is enabled. Unlike
+Validate, a spec with warnings can still be loaded. See UIWarnings
+for the structured form with a code and severity
+*/
+func (i *IntroSpect) Warnings() []string {
+	warnings := []string{}
+	for _, warning := range i.UIWarnings() {
+		warnings = append(warnings, warning.Message)
+	}
+	return warnings
+}
+
+// UIWarnings returns the structured warnings about the IntroSpect rendered by Zoraxy
+func (i *IntroSpect) UIWarnings() []UIWarning {
+	warnings := []UIWarning{}
+	if slices.Contains(i.CaptureModes(), CaptureMode_Global) {
+		warnings = append(warnings, UIWarning{UIWarningCode_GlobalCapture, "This plugin captures traffic of all HTTP proxy rules once enabled, no matter which rule it is enabled on", UIWarningSeverity_Warning})
+		for _, rule := range i.GlobalCapturePaths {
+			if rule.CapturePath == "/" && rule.IncludeSubPaths {
+				warnings = append(warnings, UIWarning{UIWarningCode_CaptureAll, "This plugin captures every request handled by Zoraxy", UIWarningSeverity_Danger})
+				break
+			}
+		}
+	}
+	if len(i.OutboundHosts) > 0 && !slices.Contains(i.Permissions, Permission_NetOutbound) {
+		warnings = append(warnings, UIWarning{UIWarningCode_UndeclaredOutbound, "This plugin declares outbound hosts without the " + Permission_NetOutbound + " permission", UIWarningSeverity_Warning})
+	}
+	if i.DefaultEnabled {
+		warnings = append(warnings, UIWarning{UIWarningCode_DefaultEnabled, "This plugin is enabled on new HTTP proxy rules by default", UIWarningSeverity_Info})
+	}
+	if i.Type == PluginType_Router && len(i.CaptureModes()) == 0 {
+		warnings = append(warnings, UIWarning{UIWarningCode_NoCapturePath, "This router plugin does not declare any capture path, it will not receive any traffic", UIWarningSeverity_Warning})
+	}
+	eventNames := make([]string, 0, len(i.SubscriptionsEvents))
+	for eventName := range i.SubscriptionsEvents {
+		eventNames = append(eventNames, eventName)
+	}
+	slices.Sort(eventNames)
+	for _, eventName := range eventNames {
+		if !IsValidEventName(eventName) {
+			warnings = append(warnings, UIWarning{UIWarningCode_UnknownEvent, "This plugin subscribes to the unknown event " + eventName + ", it will never be triggered", UIWarningSeverity_Warning})
+		}
+	}
+	if (i.GlobalCaptureIngress == "") != (len(i.GlobalCapturePaths) == 0) || (i.AlwaysCaptureIngress == "") != (len(i.AlwaysCapturePaths) == 0) {
+		warnings = append(warnings, UIWarning{UIWarningCode_IncompleteCapture, "This plugin declares capture paths without an ingress (or an ingress without capture paths), the incomplete capture settings are ignored", UIWarningSeverity_Warning})
+	}
+	return warnings
 }
 
+// ExitFunc is called by the SDK when the plugin process should exit
+// (e.g. after the introspect is served). Test harnesses can replace it
+// to run the plugin main function in-process
+var ExitFunc = os.Exit
+
 /*
 ServeIntroSpect Function
 
 This function will check if the plugin is initialized with -introspect flag,
-if so, it will print the intro spect and exit
+if so, it will print the intro spect and exit. With -introspect-compact
+the intro spect is printed as minified JSON instead, and with -manifest
+the packaging manifest of the plugin binary is printed (see WriteManifest)
 
 Place this function at the beginning of your plugin main function
 */
 func ServeIntroSpect(pluginSpect *IntroSpect) {
-	if len(os.Args) > 1 && os.Args[1] == "-introspect" {
-		//Print the intro spect and exit
+	if TryServeIntroSpect(pluginSpect) {
+		ExitFunc(0)
+	}
+}
+
+/*
+TryServeIntroSpect Function
+
+Same as ServeIntroSpect, but returns true instead of exiting if the
+intro spect is served, so the caller can run its own teardown and exit.
+The plugin must exit without printing anything else to STDOUT
+*/
+func TryServeIntroSpect(pluginSpect *IntroSpect) (served bool) {
+	if len(os.Args) < 2 {
+		return false
+	}
+	if pluginSpect.ProtocolVersion == 0 {
+		spec := *pluginSpect
+		spec.ProtocolVersion = ProtocolVersion
+		pluginSpect = &spec
+	}
+	if os.Args[1] == SimulateCaptureFlag || strings.HasPrefix(os.Args[1], SimulateCaptureFlag+"=") {
+		//Print which capture rule matches the given URL, see SimulateCapture
+		serveSimulateCapture(pluginSpect, os.Args[1:])
+		return true
+	}
+	switch os.Args[1] {
+	case "-introspect":
+		//Print the intro spect
 		jsonData, _ := json.MarshalIndent(pluginSpect, "", " ")
 		fmt.Println(string(jsonData))
-		os.Exit(0)
+		return true
+	case "-introspect-compact":
+		//Print the intro spect in a single line for machine parsing
+		jsonData, _ := json.Marshal(pluginSpect)
+		fmt.Println(string(jsonData))
+		return true
+	case "-manifest":
+		//Print the packaging manifest, see WriteManifest
+		serveManifest(pluginSpect)
+		return true
 	}
+	return false
 }
 
 /*
@@ -124,42 +674,231 @@ by the supplied values like starting a web server at given port
 that listens to 127.0.0.1:port
 */
 type ConfigureSpec struct {
-	Port         int                  `json:"port"`          //Port to listen
-	RuntimeConst RuntimeConstantValue `json:"runtime_const"` //Runtime constant values
+	Port         int                  `json:"port"`                   //Port to listen
+	RuntimeConst RuntimeConstantValue `json:"runtime_const"`          //Runtime constant values
+	Options      map[string]string    `json:"options,omitempty"`      //User defined plugin options set in Zoraxy, read with GetString / GetBool / GetInt
+	EventSecret  string               `json:"event_secret,omitempty"` //Shared secret Zoraxy signs the subscription events and its calls to the plugin with, see VerifyEventSignature and VerifyRequestSignature
+	PortGranted  bool                 `json:"port_granted,omitempty"` //True if Port honors the PreferredPort or PortRange of the IntroSpect
+
+	ProtocolVersion int    `json:"protocol_version,omitempty"` //Wire protocol version of Zoraxy, checked by RecvConfigureSpec
+	DataDir         string `json:"data_dir,omitempty"`         //Absolute path of the directory for the plugin to persist its data, see SaveJSON
+	ZoraxyAPIURL    string `json:"zoraxy_api_url,omitempty"`   //Base URL of the Zoraxy plugin API, empty if not offered, see NewZoraxyClient
 	//To be expanded
 }
 
+// GetString returns the option of the given key, or defaultValue if it is not set
+func (c *ConfigureSpec) GetString(key string, defaultValue string) string {
+	value, ok := c.Options[key]
+	if !ok {
+		return defaultValue
+	}
+	return value
+}
+
+// GetBool returns the option of the given key parsed as bool (e.g. true, 1, false, 0),
+// or defaultValue if it is not set or cannot be parsed
+func (c *ConfigureSpec) GetBool(key string, defaultValue bool) bool {
+	value, ok := c.Options[key]
+	if !ok {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// GetInt returns the option of the given key parsed as int,
+// or defaultValue if it is not set or cannot be parsed
+func (c *ConfigureSpec) GetInt(key string, defaultValue int) int {
+	value, ok := c.Options[key]
+	if !ok {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// PluginPublicURL returns the externally visible URL of the given subpath of the plugin UI
+// (e.g. PluginPublicURL("/oauth/callback") for an OAuth redirect URI)
+// Only works if Zoraxy is started with the -public_url flag
+func (c *ConfigureSpec) PluginPublicURL(subpath string) (string, error) {
+	if c.RuntimeConst.ExternalBaseURL == "" {
+		return "", errors.New("external base URL not provided by Zoraxy, start Zoraxy with the -public_url flag")
+	}
+	baseURL, err := url.Parse(c.RuntimeConst.ExternalBaseURL)
+	if err != nil {
+		return "", err
+	}
+	if baseURL.Scheme == "" || baseURL.Host == "" {
+		return "", errors.New("external base URL is not an absolute URL: " + c.RuntimeConst.ExternalBaseURL)
+	}
+	subURL, err := url.Parse(subpath)
+	if err != nil {
+		return "", err
+	}
+	if subURL.IsAbs() || subURL.Host != "" {
+		return "", errors.New("subpath must be a path, not an absolute URL: " + subpath)
+	}
+
+	baseURL.Path = strings.TrimSuffix(baseURL.Path, "/") + "/" + strings.TrimPrefix(subURL.Path, "/")
+	baseURL.RawPath = ""
+	baseURL.RawQuery = subURL.RawQuery
+	baseURL.Fragment = subURL.Fragment
+	return baseURL.String(), nil
+}
+
+// ErrConfigureSpecNotFound is returned when no configure spec is supplied by Zoraxy
+var ErrConfigureSpecNotFound = errors.New("No -configure flag found")
+
+// Environment variables checked by RecvConfigureSpec when the -configure flag is absent
+const (
+	ConfigureEnv     = "ZORAXY_PLUGIN_CONFIGURE"      //Environment variable holding the ConfigureSpec JSON
+	ConfigureFileEnv = "ZORAXY_PLUGIN_CONFIGURE_FILE" //Environment variable holding the path to a ConfigureSpec JSON file
+)
+
+const (
+	ConfigureStdinArg    = "-"       //Value of the -configure flag when the ConfigureSpec is piped to STDIN
+	ConfigureArgvMaxSize = 32 * 1024 //Max size of the ConfigureSpec JSON passed in argv, larger payloads are piped to STDIN
+)
+
 /*
 RecvExecuteConfigureSpec Function
 
 This function will read the configure spec from Zoraxy
 and return the ConfigureSpec object
 
+If the -configure flag is absent, the configure spec is read from
+the ZORAXY_PLUGIN_CONFIGURE environment variable, or from the file
+located at ZORAXY_PLUGIN_CONFIGURE_FILE. This keeps the payload out
+of the process argv (visible in ps) and avoids argv length limits.
+If the flag is -configure=-, the configure spec is read from STDIN
+
+Workers registered with RegisterWorker are started once the
+configure spec is received
+
 Place this function after ServeIntroSpect function in your plugin main function
 */
 func RecvConfigureSpec() (*ConfigureSpec, error) {
-	for i, arg := range os.Args {
-		if strings.HasPrefix(arg, "-configure=") {
-			var configSpec ConfigureSpec
-			if err := json.Unmarshal([]byte(arg[11:]), &configSpec); err != nil {
-				return nil, err
-			}
-			return &configSpec, nil
+	configSpec, err := recvConfigureSpec()
+	if err != nil {
+		return nil, err
+	}
+	return acceptConfigureSpec(configSpec)
+}
+
+// acceptConfigureSpec checks the protocol version of the received configure spec
+// and starts the workers registered with RegisterWorker
+func acceptConfigureSpec(configSpec *ConfigureSpec) (*ConfigureSpec, error) {
+	if err := CheckProtocolVersion(configSpec.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	setZoraxyEventSecret(configSpec.EventSecret)
+
+	//Start the workers registered with RegisterWorker
+	if err := startWorkers(); err != nil {
+		return nil, err
+	}
+	return configSpec, nil
+}
+
+/*
+RecvConfigureSpecReader Function
+
+This function decodes a single ConfigureSpec JSON document from the reader
+without loading the whole payload into memory first.
+
+Zoraxy passes the configure spec in argv as -configure={json} if the
+payload is smaller than ConfigureArgvMaxSize. Larger payloads are piped
+to the plugin STDIN and the plugin is started with -configure=- instead,
+RecvConfigureSpec handles both cases automatically
+*/
+func RecvConfigureSpecReader(r io.Reader) (*ConfigureSpec, error) {
+	configSpec, err := decodeConfigureSpec(r)
+	if err != nil {
+		return nil, err
+	}
+	return acceptConfigureSpec(configSpec)
+}
+
+func decodeConfigureSpec(r io.Reader) (*ConfigureSpec, error) {
+	var configSpec ConfigureSpec
+	if err := json.NewDecoder(r).Decode(&configSpec); err != nil {
+		return nil, fmt.Errorf("invalid configure spec: %w", err)
+	}
+	return &configSpec, nil
+}
+
+// decodeConfigureStdin decodes the ConfigureSpec piped to STDIN, keeping the bytes read past it for NewStdioChannel
+func decodeConfigureStdin(stdin io.Reader) (*ConfigureSpec, error) {
+	var configSpec ConfigureSpec
+	decoder := json.NewDecoder(stdin)
+	if err := decoder.Decode(&configSpec); err != nil {
+		return nil, fmt.Errorf("invalid configure spec: %w", err)
+	}
+	remainder, _ := io.ReadAll(decoder.Buffered())
+	stdinRemainderMu.Lock()
+	stdinRemainder = remainder
+	stdinRemainderMu.Unlock()
+	return &configSpec, nil
+}
+
+func recvConfigureSpec() (*ConfigureSpec, error) {
+	return recvConfigureSpecFrom(os.Args, os.Stdin)
+}
+
+// recvConfigureSpecFrom reads the configure spec from the given argv and STDIN, or the environment
+func recvConfigureSpecFrom(args []string, stdin io.Reader) (*ConfigureSpec, error) {
+	for i, arg := range args {
+		if arg == "-configure="+ConfigureStdinArg {
+			return decodeConfigureStdin(stdin)
+		} else if strings.HasPrefix(arg, "-configure=") {
+			return readConfigureArg(append([]string{arg[11:]}, args[i+1:]...))
 		} else if arg == "-configure" {
-			var configSpec ConfigureSpec
-			var nextArg string
-			if len(os.Args) > i+1 {
-				nextArg = os.Args[i+1]
-				if err := json.Unmarshal([]byte(nextArg), &configSpec); err != nil {
-					return nil, err
+			if len(args) > i+1 {
+				if args[i+1] == ConfigureStdinArg {
+					return decodeConfigureStdin(stdin)
 				}
-			} else {
-				return nil, fmt.Errorf("No port specified after -configure flag")
+				return readConfigureArg(args[i+1:])
 			}
-			return &configSpec, nil
+			return nil, fmt.Errorf("No port specified after -configure flag")
 		}
 	}
-	return nil, fmt.Errorf("No -configure flag found")
+
+	//Fallback to environment variables
+	if envPayload := os.Getenv(ConfigureEnv); envPayload != "" {
+		var configSpec ConfigureSpec
+		if err := json.Unmarshal([]byte(envPayload), &configSpec); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", ConfigureEnv, err)
+		}
+		return &configSpec, nil
+	}
+	if configFile := os.Getenv(ConfigureFileEnv); configFile != "" {
+		return readConfigureFile(configFile)
+	}
+	return nil, ErrConfigureSpecNotFound
+}
+
+// errConfigureFileEmpty is returned by readConfigureFile if the file exists but nothing has been written yet
+var errConfigureFileEmpty = errors.New("configure file is empty")
+
+func readConfigureFile(configFile string) (*ConfigureSpec, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return nil, fmt.Errorf("%w: %s", errConfigureFileEmpty, configFile)
+	}
+	var configSpec ConfigureSpec
+	if err := json.Unmarshal(content, &configSpec); err != nil {
+		return nil, fmt.Errorf("invalid configure file %s: %w", configFile, err)
+	}
+	return &configSpec, nil
 }
 
 /*
@@ -172,3 +911,91 @@ func ServeAndRecvSpec(pluginSpect *IntroSpect) (*ConfigureSpec, error) {
 	ServeIntroSpect(pluginSpect)
 	return RecvConfigureSpec()
 }
+
+// ErrIntroSpectServed is returned by TryServeAndRecvSpec when the plugin was started with -introspect
+var ErrIntroSpectServed = errors.New("intro spect served, the plugin should exit")
+
+/*
+TryServeAndRecvSpec Function
+
+Same as ServeAndRecvSpec, but returns ErrIntroSpectServed instead of
+exiting if the plugin was started with -introspect
+*/
+func TryServeAndRecvSpec(pluginSpect *IntroSpect) (*ConfigureSpec, error) {
+	if TryServeIntroSpect(pluginSpect) {
+		return nil, ErrIntroSpectServed
+	}
+	return RecvConfigureSpec()
+}
+
+/*
+ServeAndRecvSpecContext Function
+
+Same as ServeAndRecvSpec, but if the configure file located at
+ZORAXY_PLUGIN_CONFIGURE_FILE has not been written by Zoraxy yet, it polls
+the file every ConfigureFilePollInterval until the context is done and
+returns the context error (e.g. context.DeadlineExceeded)
+
+Only the configure file is polled, as argv and the environment do not
+change once the plugin is started. If the configure spec is not found
+there and ZORAXY_PLUGIN_CONFIGURE_FILE is not set, ErrConfigureSpecNotFound
+is returned right away. If the plugin is started with -configure=-, the
+wait for Zoraxy to pipe the spec to STDIN is bounded by the context too,
+the read keeps going in the background if the context is done first
+*/
+func ServeAndRecvSpecContext(ctx context.Context, pluginSpect *IntroSpect) (*ConfigureSpec, error) {
+	ServeIntroSpect(pluginSpect)
+
+	//Reading STDIN blocks until Zoraxy writes the spec, do not let it outlive the context
+	type recvResult struct {
+		configSpec *ConfigureSpec
+		err        error
+	}
+	resultChan := make(chan recvResult, 1)
+	args, stdin := os.Args, os.Stdin
+	go func() {
+		configSpec, err := recvConfigureSpecFrom(args, stdin)
+		resultChan <- recvResult{configSpec, err}
+	}()
+	var result recvResult
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result = <-resultChan:
+	}
+	configSpec, err := result.configSpec, result.err
+	if err == nil {
+		return acceptConfigureSpec(configSpec)
+	}
+	configFile := os.Getenv(ConfigureFileEnv)
+	if configFile == "" || !isConfigureFilePending(err) {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(ConfigureFilePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		configSpec, err := readConfigureFile(configFile)
+		if err == nil {
+			return acceptConfigureSpec(configSpec)
+		}
+		if !isConfigureFilePending(err) {
+			//Malformed payload, retrying will not help
+			return nil, err
+		}
+	}
+}
+
+// ConfigureFilePollInterval is the interval ServeAndRecvSpecContext polls the configure file with
+var ConfigureFilePollInterval = 100 * time.Millisecond
+
+// isConfigureFilePending returns true if the configure file has not been written yet
+func isConfigureFilePending(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, errConfigureFileEmpty)
+}
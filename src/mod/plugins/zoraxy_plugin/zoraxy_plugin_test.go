@@ -0,0 +1,994 @@
+package zoraxy_plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestIntroSpectSchemaMatchesStruct(t *testing.T) {
+	checkSchemaMatches(t, IntroSpectSchema(), &IntroSpect{
+		TLSPolicy:   &TLSPolicy{},
+		Permissions: []string{Permission_FsWrite},
+	})
+	checkSchemaMatches(t, ConfigureSpecSchema(), &ConfigureSpec{})
+}
+
+func checkSchemaMatches(t *testing.T, schemaJSON []byte, payload interface{}) {
+	var schema struct {
+		Properties map[string]interface{} `json:"properties"`
+		Required   []string               `json:"required"`
+	}
+	if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+
+	js, _ := json.Marshal(payload)
+	fields := map[string]interface{}{}
+	json.Unmarshal(js, &fields)
+	for field := range fields {
+		if _, ok := schema.Properties[field]; !ok {
+			t.Errorf("Field %s missing from schema", field)
+		}
+	}
+	for _, field := range schema.Required {
+		if _, ok := schema.Properties[field]; !ok {
+			t.Errorf("Required field %s is not a schema property", field)
+		}
+	}
+}
+
+func TestRecvConfigureSpecFromEnv(t *testing.T) {
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+	os.Args = []string{"plugin"}
+
+	t.Setenv(ConfigureEnv, `{"port":12345}`)
+	spec, err := RecvConfigureSpec()
+	if err != nil || spec.Port != 12345 {
+		t.Fatalf("Expected port 12345 from env, got %v, %v", spec, err)
+	}
+
+	//The flag still takes precedence over the environment
+	os.Args = []string{"plugin", `-configure={"port":23456}`}
+	spec, err = RecvConfigureSpec()
+	if err != nil || spec.Port != 23456 {
+		t.Fatalf("Expected port 23456 from flag, got %v, %v", spec, err)
+	}
+
+	os.Args = []string{"plugin"}
+	t.Setenv(ConfigureEnv, "")
+	configFile := filepath.Join(t.TempDir(), "configure.json")
+	os.WriteFile(configFile, []byte(`{"port":34567}`), 0644)
+	t.Setenv(ConfigureFileEnv, configFile)
+	spec, err = RecvConfigureSpec()
+	if err != nil || spec.Port != 34567 {
+		t.Fatalf("Expected port 34567 from file, got %v, %v", spec, err)
+	}
+}
+
+func TestServeAndRecvSpecContext(t *testing.T) {
+	originalArgs := os.Args
+	originalInterval := ConfigureFilePollInterval
+	defer func() {
+		os.Args = originalArgs
+		ConfigureFilePollInterval = originalInterval
+	}()
+	os.Args = []string{"plugin"}
+	ConfigureFilePollInterval = 10 * time.Millisecond
+	spec := &IntroSpect{ID: "org.example.test"}
+
+	//Without a configure file there is nothing to wait for
+	t.Setenv(ConfigureEnv, "")
+	t.Setenv(ConfigureFileEnv, "")
+	if _, err := ServeAndRecvSpecContext(context.Background(), spec); !errors.Is(err, ErrConfigureSpecNotFound) {
+		t.Errorf("Expected ErrConfigureSpecNotFound right away, got %v", err)
+	}
+
+	//The configure file is polled until Zoraxy writes it
+	configFile := filepath.Join(t.TempDir(), "configure.json")
+	t.Setenv(ConfigureFileEnv, configFile)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		//An empty file is still being written
+		os.WriteFile(configFile, nil, 0644)
+		time.Sleep(30 * time.Millisecond)
+		os.WriteFile(configFile, []byte(`{"port":45678}`), 0644)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	configSpec, err := ServeAndRecvSpecContext(ctx, spec)
+	if err != nil || configSpec.Port != 45678 {
+		t.Fatalf("Expected port 45678 from the polled file, got %v, %v", configSpec, err)
+	}
+
+	//A malformed file is not retried
+	os.WriteFile(configFile, []byte(`{"port":`), 0644)
+	if _, err := ServeAndRecvSpecContext(ctx, spec); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the malformed file to be reported, got %v", err)
+	}
+
+	//The context error is returned if the file never shows up
+	t.Setenv(ConfigureFileEnv, filepath.Join(t.TempDir(), "missing.json"))
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ServeAndRecvSpecContext(ctx, spec); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRecvConfigureSpecSplitArgv(t *testing.T) {
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+
+	tests := []struct {
+		args []string
+		port int
+		name string
+	}{
+		{[]string{"plugin", `-configure={"port": 12345, "options": {"name": "my`, `plugin"}}`}, 12345, "my plugin"},
+		{[]string{"plugin", "-configure", `{"port":`, `23456,`, `"options":{}}`}, 23456, ""},
+		{[]string{"plugin", `-configure={"port":34567}`, "-debug"}, 34567, ""},
+	}
+	for _, test := range tests {
+		os.Args = test.args
+		spec, err := recvConfigureSpec()
+		if err != nil || spec.Port != test.port || spec.GetString("name", "") != test.name {
+			t.Errorf("%v: expected port %d, got %+v, %v", test.args, test.port, spec, err)
+		}
+	}
+
+	//A payload that cannot be completed hints at the argv quoting
+	os.Args = []string{"plugin", `-configure={"port":`, "-debug"}
+	if _, err := recvConfigureSpec(); !errors.Is(err, ErrConfigureSpecTruncated) {
+		t.Errorf("Expected ErrConfigureSpecTruncated, got %v", err)
+	}
+	os.Args = []string{"plugin", "-configure", `{"port":"not a number"}`}
+	if _, err := recvConfigureSpec(); err == nil || errors.Is(err, ErrConfigureSpecTruncated) {
+		t.Errorf("Expected a decode error for an invalid payload, got %v", err)
+	}
+
+	//The chunks of the split payload are not passed to the plugin flags
+	args := []string{"-configure", `{"port":`, `12345}`, "-debug", `-configure={"a":`, `1}`, "-v"}
+	if got := filterPluginArgs(args); strings.Join(got, " ") != "-debug -v" {
+		t.Errorf("Expected the split payload to be removed, got %v", got)
+	}
+}
+
+func TestPluginPublicURL(t *testing.T) {
+	spec := &ConfigureSpec{RuntimeConst: RuntimeConstantValue{
+		ExternalBaseURL: "https://zoraxy.example.com:8000/plugin.ui/org.example.test/",
+	}}
+	tests := map[string]string{
+		"/oauth/callback":   "https://zoraxy.example.com:8000/plugin.ui/org.example.test/oauth/callback",
+		"share?id=1":        "https://zoraxy.example.com:8000/plugin.ui/org.example.test/share?id=1",
+		"/ui/index.html#ab": "https://zoraxy.example.com:8000/plugin.ui/org.example.test/ui/index.html#ab",
+	}
+	for subpath, expected := range tests {
+		got, err := spec.PluginPublicURL(subpath)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", subpath, err)
+		}
+		if got != expected {
+			t.Errorf("%s: expected %s, got %s", subpath, expected, got)
+		}
+	}
+
+	if _, err := spec.PluginPublicURL("https://evil.example.com/"); err == nil {
+		t.Error("Expected an error for an absolute subpath")
+	}
+	if _, err := (&ConfigureSpec{}).PluginPublicURL("/"); err == nil {
+		t.Error("Expected an error without an external base URL")
+	}
+}
+
+func TestIntroSpectWarnings(t *testing.T) {
+	spec := &IntroSpect{
+		ID:                   "org.example.test",
+		Name:                 "Test",
+		Author:               "foobar",
+		Description:          "Test plugin",
+		UIPath:               "/ui",
+		Type:                 PluginType_Router,
+		GlobalCaptureIngress: "/g_handler",
+		GlobalCapturePaths:   []CaptureRule{{CapturePath: "/", IncludeSubPaths: true}},
+		DefaultCaptureMode:   CaptureMode_Always,
+	}
+	if len(spec.Warnings()) != 2 {
+		t.Errorf("Expected 2 warnings for a catch-all global capture, got %v", spec.Warnings())
+	}
+	if err := spec.Validate(); err == nil {
+		t.Error("Expected an error for an undeclared default capture mode")
+	}
+
+	spec = &IntroSpect{Type: PluginType_Router}
+	if len(spec.Warnings()) != 1 {
+		t.Errorf("Expected a warning for a router without capture paths, got %v", spec.Warnings())
+	}
+}
+
+func TestUIWarnings(t *testing.T) {
+	spec := &IntroSpect{
+		Type:                 PluginType_Router,
+		GlobalCaptureIngress: "/g_handler",
+		GlobalCapturePaths:   []CaptureRule{{CapturePath: "/", IncludeSubPaths: true}},
+		DefaultEnabled:       true,
+	}
+	warnings := spec.UIWarnings()
+	expected := []UIWarning{
+		{Code: UIWarningCode_GlobalCapture, Severity: UIWarningSeverity_Warning},
+		{Code: UIWarningCode_CaptureAll, Severity: UIWarningSeverity_Danger},
+		{Code: UIWarningCode_DefaultEnabled, Severity: UIWarningSeverity_Info},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %v", len(expected), warnings)
+	}
+	for index, warning := range warnings {
+		if warning.Code != expected[index].Code || warning.Severity != expected[index].Severity || warning.Message == "" {
+			t.Errorf("Expected %+v, got %+v", expected[index], warning)
+		}
+		if spec.Warnings()[index] != warning.Message {
+			t.Errorf("Expected Warnings to return the UIWarnings messages, got %q", spec.Warnings()[index])
+		}
+	}
+}
+
+func TestConfigureSpecOptions(t *testing.T) {
+	spec := &ConfigureSpec{Options: map[string]string{
+		"name":    "demo",
+		"enabled": "true",
+		"workers": " 4 ",
+		"broken":  "yes please",
+	}}
+	if got := spec.GetString("name", ""); got != "demo" {
+		t.Errorf("Expected demo, got %q", got)
+	}
+	if got := spec.GetString("missing", "fallback"); got != "fallback" {
+		t.Errorf("Expected fallback, got %q", got)
+	}
+	if !spec.GetBool("enabled", false) || spec.GetBool("broken", false) {
+		t.Error("Unexpected bool option value")
+	}
+	if spec.GetInt("workers", 1) != 4 || spec.GetInt("broken", 1) != 1 {
+		t.Error("Unexpected int option value")
+	}
+
+	//A spec without options returns the defaults
+	if (&ConfigureSpec{}).GetInt("workers", 2) != 2 {
+		t.Error("Expected the default value for a spec without options")
+	}
+}
+
+func TestUnknownEventNameWarning(t *testing.T) {
+	spec := &IntroSpect{
+		Type: PluginType_Utilities,
+		SubscriptionsEvents: map[string]string{
+			EventName_CertRenewed: "Reload the certificate",
+			"cert_renewd":         "Misspelled",
+		},
+	}
+	warnings := spec.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "cert_renewd") {
+		t.Errorf("Expected a warning for the misspelled event, got %v", warnings)
+	}
+}
+
+func TestCaptureRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule     CaptureRule
+		path     string
+		expected bool
+	}{
+		{CaptureRule{CapturePath: "/api"}, "/api", true},
+		{CaptureRule{CapturePath: "/api"}, "/api/", true},
+		{CaptureRule{CapturePath: "/api/"}, "/api", true},
+		{CaptureRule{CapturePath: "/api"}, "/api/v1", false},
+		{CaptureRule{CapturePath: "/api"}, "/API", false},
+		{CaptureRule{CapturePath: "/api", IncludeSubPaths: true}, "/api/v1", true},
+		{CaptureRule{CapturePath: "/api/", IncludeSubPaths: true}, "/api/v1/", true},
+		{CaptureRule{CapturePath: "/api", IncludeSubPaths: true}, "/apiv1", false},
+		{CaptureRule{CapturePath: "/", IncludeSubPaths: true}, "/anything", true},
+		{CaptureRule{CapturePath: "/"}, "/", true},
+		{CaptureRule{CapturePath: "/"}, "/anything", false},
+	}
+	for _, test := range tests {
+		if got := test.rule.Matches(test.path); got != test.expected {
+			t.Errorf("%+v.Matches(%q): expected %v, got %v", test.rule, test.path, test.expected, got)
+		}
+	}
+}
+
+func TestCaptureRuleMatchesHeaders(t *testing.T) {
+	tests := []struct {
+		headerMatch map[string]string
+		header      http.Header
+		expected    bool
+	}{
+		{nil, http.Header{}, true},
+		{map[string]string{"Authorization": ""}, http.Header{}, true},
+		{map[string]string{"Authorization": ""}, http.Header{"Authorization": {"Bearer abc"}}, false},
+		{map[string]string{"authorization": "Bearer *"}, http.Header{"Authorization": {"Bearer abc"}}, true},
+		{map[string]string{"User-Agent": "curl/*"}, http.Header{"User-Agent": {"curl/8.5.0"}}, true},
+		{map[string]string{"User-Agent": "curl/*"}, http.Header{"User-Agent": {"Mozilla/5.0 curl/8.5.0"}}, false},
+		{map[string]string{"User-Agent": "re:(?i)bot"}, http.Header{"User-Agent": {"Googlebot/2.1"}}, true},
+		{map[string]string{"User-Agent": "re:(?i)bot"}, http.Header{}, false},
+		{map[string]string{"X-Version": "v?"}, http.Header{"X-Version": {"v1"}}, true},
+		{map[string]string{"X-Version": "v?"}, http.Header{"X-Version": {"v10"}}, false},
+		{map[string]string{"Accept": "application/json"}, http.Header{"Accept": {"text/html", "application/json"}}, true},
+		{map[string]string{"Authorization": "", "User-Agent": "curl/*"}, http.Header{"User-Agent": {"curl/8.5.0"}, "Authorization": {"Basic x"}}, false},
+	}
+	for _, test := range tests {
+		rule := CaptureRule{CapturePath: "/", IncludeSubPaths: true, HeaderMatch: test.headerMatch}
+		if got := rule.MatchesHeaders(test.header); got != test.expected {
+			t.Errorf("%v.MatchesHeaders(%v): expected %v, got %v", test.headerMatch, test.header, test.expected, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1", nil)
+	rule := CaptureRule{CapturePath: "/api", IncludeSubPaths: true, HeaderMatch: map[string]string{"Authorization": ""}}
+	if !rule.MatchesRequest(req) {
+		t.Error("Expected the unauthenticated request to be captured")
+	}
+	req.Header.Set("Authorization", "Bearer abc")
+	if rule.MatchesRequest(req) {
+		t.Error("Expected the authenticated request to pass")
+	}
+
+	spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui",
+		GlobalCapturePaths: []CaptureRule{{CapturePath: "/", HeaderMatch: map[string]string{"User-Agent": "re:("}}}}
+	if err := spec.Validate(); err == nil {
+		t.Error("Expected an invalid header match regex to fail validation")
+	}
+}
+
+func TestValidateCapturePaths(t *testing.T) {
+	tests := []struct {
+		global []CaptureRule
+		always []CaptureRule
+		valid  bool
+	}{
+		{[]CaptureRule{{CapturePath: "/api"}}, []CaptureRule{{CapturePath: "/app"}}, true},
+		{[]CaptureRule{{CapturePath: "/api"}, {CapturePath: "/api/"}}, nil, false},
+		{nil, []CaptureRule{{CapturePath: "/App"}, {CapturePath: "/app"}}, false},
+		{[]CaptureRule{{CapturePath: "/api"}, {CapturePath: "/api", HeaderMatch: map[string]string{"Authorization": ""}}}, nil, true},
+		{[]CaptureRule{{CapturePath: "/app"}}, []CaptureRule{{CapturePath: "/app/"}}, false},
+		{[]CaptureRule{{CapturePath: "/app", IncludeSubPaths: true}}, []CaptureRule{{CapturePath: "/APP/admin"}}, false},
+		{[]CaptureRule{{CapturePath: "/app/admin"}}, []CaptureRule{{CapturePath: "/app", IncludeSubPaths: true}}, false},
+		{[]CaptureRule{{CapturePath: "/app/admin"}}, []CaptureRule{{CapturePath: "/app"}}, true},
+	}
+	for _, test := range tests {
+		spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui",
+			GlobalCapturePaths: test.global, AlwaysCapturePaths: test.always}
+		if err := spec.Validate(); (err == nil) != test.valid {
+			t.Errorf("global %+v always %+v: expected valid %v, got %v", test.global, test.always, test.valid, err)
+		}
+	}
+}
+
+func TestValidateLicense(t *testing.T) {
+	tests := []struct {
+		license   string
+		sourceURL string
+		valid     bool
+	}{
+		{"", "", true},
+		{"MIT", "https://github.com/example/myplugin", true},
+		{"apache-2.0", "", true},
+		{"Apache-2.0 OR MIT", "", true},
+		{"(MIT AND BSD-3-Clause) OR GPL-2.0-or-later", "", true},
+		{"GPL-2.0-or-later WITH Classpath-exception-2.0", "", true},
+		{"LicenseRef-Proprietary", "", true},
+		{"MIT License", "", false},
+		{"NotALicense", "", false},
+		{"MIT OR", "", false},
+		{"MIT", "github.com/example/myplugin", false},
+		{"MIT", "ftp://example.com/src", false},
+		{"MIT", "javascript:alert(1)", false},
+		{"MIT", "https:///src", false},
+	}
+	for _, test := range tests {
+		spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui", License: test.license, SourceURL: test.sourceURL}
+		if err := spec.Validate(); (err == nil) != test.valid {
+			t.Errorf("license %q source %q: expected valid %v, got %v", test.license, test.sourceURL, test.valid, err)
+		}
+	}
+}
+
+func TestValidateOpenAPIPath(t *testing.T) {
+	tests := map[string]bool{
+		"":                          true,
+		"/openapi.json":             true,
+		"/api/v1/openapi.yaml":      true,
+		"openapi.json":              false,
+		`/openapi.json" onclick="x`: false,
+		"/openapi.json'":            false,
+		"/<script>":                 false,
+		"/open api.json":            false,
+		"/openapi.json\n":           false,
+		"/openapi.json\t":           false,
+	}
+	for openAPIPath, valid := range tests {
+		spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui", OpenAPIPath: openAPIPath}
+		if err := spec.Validate(); (err == nil) != valid {
+			t.Errorf("openapi path %q: expected valid %v, got %v", openAPIPath, valid, err)
+		}
+	}
+}
+
+func TestValidatePortPreference(t *testing.T) {
+	tests := []struct {
+		preferredPort int
+		portRange     [2]int
+		valid         bool
+	}{
+		{0, [2]int{}, true},
+		{8100, [2]int{}, true},
+		{8150, [2]int{8100, 8200}, true},
+		{0, [2]int{8100, 8100}, true},
+		{70000, [2]int{}, false},
+		{0, [2]int{8200, 8100}, false},
+		{0, [2]int{0, 8100}, false},
+		{8300, [2]int{8100, 8200}, false},
+	}
+	for _, test := range tests {
+		_, err := NewIntroSpect("org.example.test", "Test").
+			WithAuthor("foobar", "").
+			WithDescription("Test plugin").
+			WithUIPath("/ui").
+			WithPreferredPort(test.preferredPort, test.portRange).
+			Build()
+		if (err == nil) != test.valid {
+			t.Errorf("port %d range %v: expected valid=%v, got %v", test.preferredPort, test.portRange, test.valid, err)
+		}
+	}
+}
+
+func TestValidateTLSInspector(t *testing.T) {
+	tests := []struct {
+		pluginType PluginType
+		ingress    string
+		valid      bool
+	}{
+		{PluginType_TLSInspector, "/sni_inspect", true},
+		{PluginType_TLSInspector, "", false},
+		{PluginType_TLSInspector, "sni_inspect", false},
+		{PluginType_TLSInspector, "http://127.0.0.1/sni_inspect", false},
+		//The ingress is ignored by the other plugin types
+		{PluginType_Utilities, "", true},
+		{PluginType_Router, "sni_inspect", true},
+	}
+	for _, test := range tests {
+		spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui", Type: test.pluginType, SNIInspectIngress: test.ingress}
+		if err := spec.Validate(); (err == nil) != test.valid {
+			t.Errorf("type %d ingress %q: expected valid %v, got %v", test.pluginType, test.ingress, test.valid, err)
+		}
+	}
+}
+
+func TestRecvConfigureSpecReader(t *testing.T) {
+	payload := `{"port":12345,"runtime_const":{"zoraxy_version":"3.2.0"},"options":{"bundle":"` + strings.Repeat("x", ConfigureArgvMaxSize) + `"}}`
+	spec, err := RecvConfigureSpecReader(strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if spec.Port != 12345 || spec.RuntimeConst.ZoraxyVersion != "3.2.0" || len(spec.GetString("bundle", "")) != ConfigureArgvMaxSize {
+		t.Errorf("Unexpected configure spec: %+v", spec.Port)
+	}
+
+	if _, err := RecvConfigureSpecReader(strings.NewReader("{")); err == nil {
+		t.Error("Expected an error for a truncated payload")
+	}
+}
+
+func TestTryServeAndRecvSpec(t *testing.T) {
+	originalArgs := os.Args
+	originalStdout := os.Stdout
+	defer func() {
+		os.Args = originalArgs
+		os.Stdout = originalStdout
+	}()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer devNull.Close()
+	os.Stdout = devNull
+
+	spec := &IntroSpect{ID: "org.example.test"}
+	os.Args = []string{originalArgs[0], "-introspect"}
+	if _, err := TryServeAndRecvSpec(spec); !errors.Is(err, ErrIntroSpectServed) {
+		t.Errorf("Expected ErrIntroSpectServed, got %v", err)
+	}
+
+	os.Args = []string{originalArgs[0], `-configure={"port":12345}`}
+	if TryServeIntroSpect(spec) {
+		t.Error("Expected the intro spect not to be served")
+	}
+	configSpec, err := TryServeAndRecvSpec(spec)
+	if err != nil || configSpec.Port != 12345 {
+		t.Errorf("Expected the configure spec, got %v %v", configSpec, err)
+	}
+}
+
+func TestValidateOutboundHosts(t *testing.T) {
+	tests := map[string]bool{
+		"api.letsencrypt.org:443": true,
+		"*.example.com:443":       true,
+		"192.0.2.1:8080":          true,
+		"[2001:db8::1]:443":       true,
+		"api.letsencrypt.org":     false,
+		"example.com:0":           false,
+		"example.com:https":       false,
+		"-bad.example.com:443":    false,
+		"exa mple.com:443":        false,
+		"https://example.com:443": false,
+	}
+	for host, valid := range tests {
+		spec := &IntroSpect{OutboundHosts: []string{host}}
+		if err := spec.ValidateOutboundHosts(); (err == nil) != valid {
+			t.Errorf("%s: expected valid=%v, got %v", host, valid, err)
+		}
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	tests := []struct {
+		permissions []string
+		valid       bool
+	}{
+		{nil, true},
+		{KnownPermissions(), true},
+		{[]string{Permission_NetOutbound, Permission_FsRead}, true},
+		{[]string{"net.inbound"}, false},
+		{[]string{"NET.OUTBOUND"}, false},
+		{[]string{" fs.read"}, false},
+		{[]string{""}, false},
+		{[]string{Permission_FsWrite, "zoraxy.admin"}, false},
+	}
+	for _, test := range tests {
+		spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui", Permissions: test.permissions}
+		err := spec.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%q: expected valid %v, got %v", test.permissions, test.valid, err)
+			continue
+		}
+		if !test.valid && !strings.Contains(err.Error(), "unknown plugin permission") {
+			t.Errorf("%q: expected an unknown permission error, got %v", test.permissions, err)
+		}
+	}
+}
+
+func TestValidateUITabs(t *testing.T) {
+	tests := []struct {
+		name  string
+		tabs  []UITab
+		valid bool
+	}{
+		{"no tabs", nil, true},
+		{"single tab", []UITab{{Title: "Settings", Path: "/settings.html"}}, true},
+		{"multiple tabs", []UITab{{Title: "Settings", Path: "/settings.html"}, {Title: "Logs", Path: "/logs.html", Icon: "/ui/logs.png"}}, true},
+		{"empty title", []UITab{{Title: "", Path: "/settings.html"}}, false},
+		{"empty path", []UITab{{Title: "Settings", Path: ""}}, false},
+		{"relative path", []UITab{{Title: "Settings", Path: "settings.html"}}, false},
+		{"duplicated path", []UITab{{Title: "Settings", Path: "/settings.html"}, {Title: "Advanced", Path: "/settings.html"}}, false},
+	}
+	for _, test := range tests {
+		spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui", UITabs: test.tabs}
+		if err := spec.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %v, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestServeIntroSpectCompact(t *testing.T) {
+	originalArgs := os.Args
+	originalStdout := os.Stdout
+	defer func() {
+		os.Args = originalArgs
+		os.Stdout = originalStdout
+	}()
+
+	spec := &IntroSpect{ID: "org.example.test", Name: "Test", SubscriptionsEvents: map[string]string{EventName_CertRenewed: "Reload certs"}}
+	for _, flag := range []string{"-introspect", "-introspect-compact"} {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		os.Stdout = writer
+		os.Args = []string{originalArgs[0], flag}
+		served := TryServeIntroSpect(spec)
+		writer.Close()
+		output, _ := io.ReadAll(reader)
+		reader.Close()
+		os.Stdout = originalStdout
+
+		if !served {
+			t.Fatalf("%s: expected the intro spect to be served", flag)
+		}
+		var decoded IntroSpect
+		if err := json.Unmarshal(output, &decoded); err != nil || decoded.ID != spec.ID {
+			t.Errorf("%s: expected a valid intro spect, got %q (%v)", flag, output, err)
+		}
+		lines := strings.Count(strings.TrimSpace(string(output)), "\n")
+		if flag == "-introspect-compact" && lines != 0 {
+			t.Errorf("Expected a single line compact intro spect, got %d line breaks", lines)
+		}
+	}
+}
+
+func TestProtocolVersion(t *testing.T) {
+	if err := CheckProtocolVersion(0); err != nil {
+		t.Errorf("Expected an unversioned peer to be accepted, got %v", err)
+	}
+	if err := CheckProtocolVersion(ProtocolVersion); err != nil {
+		t.Errorf("Expected the current version to be accepted, got %v", err)
+	}
+
+	_, err := RecvConfigureSpecReader(strings.NewReader(`{"port":12345,"protocol_version":999}`))
+	if !errors.Is(err, ErrProtocolMismatch) {
+		t.Errorf("Expected ErrProtocolMismatch, got %v", err)
+	}
+
+	//The intro spect reports the protocol version without modifying the caller spec
+	originalArgs := os.Args
+	originalStdout := os.Stdout
+	defer func() {
+		os.Args = originalArgs
+		os.Stdout = originalStdout
+	}()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	os.Stdout = writer
+	os.Args = []string{originalArgs[0], "-introspect-compact"}
+	spec := &IntroSpect{ID: "org.example.test"}
+	TryServeIntroSpect(spec)
+	writer.Close()
+	output, _ := io.ReadAll(reader)
+	reader.Close()
+	var decoded IntroSpect
+	if err := json.Unmarshal(output, &decoded); err != nil || decoded.ProtocolVersion != ProtocolVersion {
+		t.Errorf("Expected protocol version %d in the intro spect, got %q", ProtocolVersion, output)
+	}
+	if spec.ProtocolVersion != 0 {
+		t.Error("Expected the caller spec to be unchanged")
+	}
+}
+
+func TestUICapabilities(t *testing.T) {
+	spec := IntroSpect{}
+	if capabilities := spec.GetUICapabilities(); capabilities.EmbedMode != UIEmbedMode_IFrame || capabilities.MinWidth != 0 {
+		t.Errorf("Expected the default UI capabilities, got %+v", capabilities)
+	}
+	spec.UICapabilities = &UICapabilities{EmbedMode: UIEmbedMode_Tab, MinWidth: 1024}
+	if capabilities := spec.GetUICapabilities(); capabilities.EmbedMode != UIEmbedMode_Tab || capabilities.MinWidth != 1024 {
+		t.Errorf("Expected the declared UI capabilities, got %+v", capabilities)
+	}
+
+	tests := map[UICapabilities]bool{
+		{}:                           true,
+		{EmbedMode: UIEmbedMode_Tab}: true,
+		{EmbedMode: "popup"}:         false,
+		{MinWidth: -1}:               false,
+	}
+	for capabilities, valid := range tests {
+		if err := capabilities.Validate(); (err == nil) != valid {
+			t.Errorf("%+v: expected valid %v, got %v", capabilities, valid, err)
+		}
+	}
+}
+
+func TestConflictsWith(t *testing.T) {
+	authPlugin := &IntroSpect{ID: "org.example.auth", Name: "Auth", GlobalCapturePaths: []CaptureRule{{CapturePath: "/login", IncludeSubPaths: true}}}
+	tests := []struct {
+		name     string
+		other    *IntroSpect
+		conflict bool
+	}{
+		{"unrelated", &IntroSpect{ID: "org.example.other", Name: "Other", GlobalCapturePaths: []CaptureRule{{CapturePath: "/api"}}}, false},
+		{"declared by id", &IntroSpect{ID: "org.example.other", Name: "Other", Conflicts: []string{"org.example.auth"}}, true},
+		{"declared by glob", &IntroSpect{ID: "org.example.other", Name: "Other", Conflicts: []string{"/log*"}}, true},
+		{"overlapping capture", &IntroSpect{ID: "org.example.other", Name: "Other", GlobalCapturePaths: []CaptureRule{{CapturePath: "/login/sso"}}}, true},
+		{"itself", authPlugin, false},
+	}
+	for _, tt := range tests {
+		conflict, reason := authPlugin.ConflictsWith(tt.other)
+		if conflict != tt.conflict {
+			t.Errorf("%s: expected conflict %v, got %v (%s)", tt.name, tt.conflict, conflict, reason)
+		}
+		if reverse, _ := tt.other.ConflictsWith(authPlugin); reverse != tt.conflict {
+			t.Errorf("%s: expected the check to be symmetric", tt.name)
+		}
+	}
+
+	spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test plugin", UIPath: "/ui", Conflicts: []string{"/api/[*"}}
+	if err := spec.Validate(); err == nil {
+		t.Error("Expected an error for an invalid capture path glob")
+	}
+}
+
+func TestFilterPluginArgs(t *testing.T) {
+	args := []string{"-configure", `{"port":12345}`, "-debug", "-zoraxy-simulate-capture=/login", "--verbose", "-introspect", "-level=2", "--", "-manifest"}
+	expected := []string{"-debug", "--verbose", "-level=2", "--", "-manifest"}
+	got := filterPluginArgs(args)
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	for arg, reserved := range map[string]bool{"-configure={}": true, "--configure=-": true, "-zoraxy-anything": true, "-introspect-compact": true, "-debug": false, "-introspection": false} {
+		if IsSDKFlag(arg) != reserved {
+			t.Errorf("%s: expected reserved %v", arg, reserved)
+		}
+	}
+}
+
+func TestSettingsSchema(t *testing.T) {
+	minPort, maxPort := 1, 65535
+	fields := []SettingField{
+		{Key: "greeting", Label: "Greeting", Type: SettingFieldType_String, Default: "Hello", Pattern: "[A-Za-z ]+"},
+		{Key: "verbose", Label: "Verbose", Type: SettingFieldType_Bool},
+		{Key: "port", Label: "Port", Type: SettingFieldType_Int, Min: &minPort, Max: &maxPort, Required: true},
+		{Key: "mode", Label: "Mode", Type: SettingFieldType_Select, Options: []string{"fast", "safe"}, Default: "safe"},
+	}
+	spec, err := NewIntroSpect("org.example.test", "Test").WithAuthor("foobar", "").WithDescription("Test").WithUIPath("/ui").WithSettings(fields...).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		options map[string]string
+		valid   bool
+	}{
+		{map[string]string{"port": "8080"}, true},
+		{map[string]string{"port": "8080", "greeting": "Hi there", "verbose": "true", "mode": "fast", "other": "kept"}, true},
+		{map[string]string{}, false},
+		{map[string]string{"port": "0"}, false},
+		{map[string]string{"port": "http"}, false},
+		{map[string]string{"port": "8080", "greeting": "Hi!"}, false},
+		{map[string]string{"port": "8080", "verbose": "maybe"}, false},
+		{map[string]string{"port": "8080", "mode": "slow"}, false},
+	}
+	for _, test := range tests {
+		if err := spec.ValidateSettings(test.options); (err == nil) != test.valid {
+			t.Errorf("options %v: expected valid %v, got %v", test.options, test.valid, err)
+		}
+	}
+
+	merged := spec.SettingsWithDefaults(map[string]string{"mode": "fast"})
+	if merged["greeting"] != "Hello" || merged["mode"] != "fast" {
+		t.Errorf("Unexpected merged settings %v", merged)
+	}
+
+	invalidSchemas := [][]SettingField{
+		{{Key: "", Type: SettingFieldType_String}},
+		{{Key: "a", Type: "color"}},
+		{{Key: "a", Type: SettingFieldType_Select}},
+		{{Key: "a", Type: SettingFieldType_String, Pattern: "("}},
+		{{Key: "a", Type: SettingFieldType_Bool, Pattern: "true"}},
+		{{Key: "a", Type: SettingFieldType_Int, Min: &maxPort, Max: &minPort}},
+		{{Key: "a", Type: SettingFieldType_Select, Options: []string{"x"}, Default: "y"}},
+		{{Key: "a", Type: SettingFieldType_Bool}, {Key: "a", Type: SettingFieldType_Int}},
+	}
+	for _, schema := range invalidSchemas {
+		spec.SettingsSchema = schema
+		if err := spec.Validate(); err == nil {
+			t.Errorf("Expected schema %+v to be rejected", schema)
+		}
+	}
+}
+
+func TestSortByCapturePriority(t *testing.T) {
+	specs := []*IntroSpect{
+		{ID: "org.example.logger", CapturePriority: CapturePriority_Low},
+		{ID: "org.example.b"},
+		{ID: "org.example.waf", CapturePriority: CapturePriority_High},
+		{ID: "org.example.a"},
+	}
+	SortByCapturePriority(specs)
+	order := []string{}
+	for _, spec := range specs {
+		order = append(order, spec.ID)
+	}
+	expected := "org.example.waf,org.example.a,org.example.b,org.example.logger"
+	if strings.Join(order, ",") != expected {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+	if CompareCapturePriority(specs[1], specs[1]) != 0 {
+		t.Error("Expected a plugin to compare equal to itself")
+	}
+}
+
+func TestControlStatusCodesDistinct(t *testing.T) {
+	codes := map[string]ControlStatusCode{
+		"CAPTURED":  ControlStatusCode_CAPTURED,
+		"UNHANDLED": ControlStatusCode_UNHANDLED,
+		"ERROR":     ControlStatusCode_ERROR,
+		"REWRITE":   ControlStatusCode_REWRITE,
+		"UPGRADE":   ControlStatusCode_UPGRADE,
+		"FILTER":    ControlStatusCode_FILTER,
+	}
+	seen := map[ControlStatusCode]string{}
+	for name, code := range codes {
+		if other, ok := seen[code]; ok {
+			t.Errorf("ControlStatusCode_%s and ControlStatusCode_%s share the code %d", name, other, code)
+		}
+		seen[code] = name
+	}
+}
+
+func TestServeAndRecvSpecContextStdin(t *testing.T) {
+	originalArgs, originalStdin := os.Args, os.Stdin
+	defer func() {
+		os.Args = originalArgs
+		os.Stdin = originalStdin
+	}()
+	os.Args = []string{"plugin", "-configure=" + ConfigureStdinArg}
+	spec := &IntroSpect{ID: "org.example.test"}
+
+	//The context bounds the wait for Zoraxy to pipe the spec
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	os.Stdin = reader
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := ServeAndRecvSpecContext(ctx, spec); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled while STDIN is blocked, got %v", err)
+	}
+	//Unblock the abandoned read
+	writer.Close()
+
+	reader, writer, err = os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	os.Stdin = reader
+	go func() {
+		writer.Write([]byte(`{"port":56789}`))
+		writer.Close()
+	}()
+	configSpec, err := ServeAndRecvSpecContext(context.Background(), spec)
+	if err != nil || configSpec.Port != 56789 {
+		t.Fatalf("Expected port 56789 from STDIN, got %v, %v", configSpec, err)
+	}
+}
+
+func TestPortRange(t *testing.T) {
+	//No range is omitted from the intro spect JSON
+	js, _ := json.Marshal(&IntroSpect{})
+	if strings.Contains(string(js), "port_range") {
+		t.Errorf("Expected port_range to be omitted, got %s", js)
+	}
+
+	tests := []struct {
+		preferredPort int
+		portRange     *[2]int
+		valid         bool
+	}{
+		{0, nil, true},
+		{8150, &[2]int{8100, 8200}, true},
+		{0, &[2]int{8200, 8100}, false},
+		{0, &[2]int{0, 0}, false},
+		{0, &[2]int{1, 65536}, false},
+		{9000, &[2]int{8100, 8200}, false},
+	}
+	for _, test := range tests {
+		spec := &IntroSpect{PreferredPort: test.preferredPort, PortRange: test.portRange}
+		if err := spec.validatePortPreference(); (err == nil) != test.valid {
+			t.Errorf("port %d range %v: expected valid %v, got %v", test.preferredPort, test.portRange, test.valid, err)
+		}
+	}
+
+	builder := NewIntroSpect("org.example.test", "Test")
+	if builder.WithPreferredPort(8150, [2]int{}).spec.PortRange != nil {
+		t.Error("Expected a zero range to mean no preference")
+	}
+	if r := builder.WithPreferredPort(8150, [2]int{8100, 8200}).spec.PortRange; r == nil || *r != [2]int{8100, 8200} {
+		t.Errorf("Expected the range to be set, got %v", r)
+	}
+}
+
+func TestSetIconFromFS(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`)
+	png := []byte("\x89PNG\r\n\x1a\nicon")
+	iconFs := fstest.MapFS{
+		"icon.svg":     {Data: svg},
+		"ICON.PNG":     {Data: png},
+		"max.png":      {Data: bytes.Repeat([]byte{1}, MaxIconSize)},
+		"too_big.png":  {Data: bytes.Repeat([]byte{1}, MaxIconSize+1)},
+		"icon.gif":     {Data: []byte("GIF89a")},
+		"icon.svg.txt": {Data: svg},
+	}
+	tests := []struct {
+		name     string
+		mimeType string
+		content  []byte
+	}{
+		{"icon.svg", "image/svg+xml", svg},
+		{"ICON.PNG", "image/png", png},
+		{"max.png", "image/png", iconFs["max.png"].Data},
+		{"too_big.png", "", nil},
+		{"icon.gif", "", nil},
+		{"icon.svg.txt", "", nil},
+		{"missing.png", "", nil},
+	}
+	for _, test := range tests {
+		spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui"}
+		err := spec.SetIconFromFS(iconFs, test.name)
+		if test.mimeType == "" {
+			if err == nil || spec.Icon != "" {
+				t.Errorf("%s: expected an error and no icon, got %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !strings.HasPrefix(spec.Icon, "data:"+test.mimeType+";base64,") {
+			t.Errorf("%s: unexpected data URI prefix %.40q", test.name, spec.Icon)
+		}
+		//The data URI round trips through DecodeIcon and passes Validate
+		mimeType, content, err := spec.DecodeIcon()
+		if err != nil || mimeType != test.mimeType || !bytes.Equal(content, test.content) {
+			t.Errorf("%s: round trip failed, got %q %d bytes %v", test.name, mimeType, len(content), err)
+		}
+		if err := spec.Validate(); err != nil {
+			t.Errorf("%s: expected the icon to be valid, got %v", test.name, err)
+		}
+	}
+}
+
+func TestDecodeIcon(t *testing.T) {
+	encode := func(content []byte) string { return base64.StdEncoding.EncodeToString(content) }
+	tests := []struct {
+		name  string
+		icon  string
+		valid bool
+	}{
+		{"png", "data:image/png;base64," + encode([]byte("png")), true},
+		{"svg", "data:image/svg+xml;base64," + encode([]byte("<svg/>")), true},
+		{"max size", "data:image/png;base64," + encode(bytes.Repeat([]byte{1}, MaxIconSize)), true},
+		{"too large", "data:image/png;base64," + encode(bytes.Repeat([]byte{1}, MaxIconSize+1)), false},
+		{"html mime type", "data:text/html;base64," + encode([]byte("<script>alert(1)</script>")), false},
+		{"gif mime type", "data:image/gif;base64," + encode([]byte("GIF89a")), false},
+		{"not base64 encoded", "data:image/svg+xml,<svg/>", false},
+		{"invalid base64", "data:image/png;base64,!!!", false},
+		{"missing payload", "data:image/png;base64", false},
+	}
+	for _, test := range tests {
+		spec := IntroSpect{Icon: test.icon}
+		_, _, err := spec.DecodeIcon()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %v, got %v", test.name, test.valid, err)
+		}
+		//Validate rejects the same data URIs
+		spec = IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui", Icon: test.icon}
+		if (spec.Validate() == nil) != test.valid {
+			t.Errorf("%s: expected Validate to match DecodeIcon", test.name)
+		}
+	}
+
+	if _, _, err := (&IntroSpect{Icon: "/ui/icon.png"}).DecodeIcon(); err == nil {
+		t.Error("Expected an error for an icon path")
+	}
+	//Icons that are neither a data URI nor an absolute path are rejected
+	spec := IntroSpect{ID: "org.example.test", Name: "Test", Author: "foobar", Description: "Test", UIPath: "/ui", Icon: "icon.png"}
+	if err := spec.Validate(); err == nil {
+		t.Error("Expected a relative icon path to be rejected")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"imuslab.com/zoraxy/mod/dynamicproxy/permissionpolicy"
 	"imuslab.com/zoraxy/mod/dynamicproxy/rewrite"
 	"imuslab.com/zoraxy/mod/netutils"
+	"imuslab.com/zoraxy/mod/plugins/zoraxy_plugin"
 	"imuslab.com/zoraxy/mod/uptime"
 	"imuslab.com/zoraxy/mod/utils"
 )
@@ -481,6 +482,9 @@ func ReverseProxyHandleAddEndpoint(w http.ResponseWriter, r *http.Request) {
 	//Update utm if exists
 	UpdateUptimeMonitorTargets()
 
+	//Notify the subscribed plugins
+	pluginManager.EmitEvent(zoraxy_plugin.EventName_ProxyRuleAdded, "proxy", proxyEndpointCreated.RootOrMatchingDomain)
+
 	utils.SendOK(w)
 }
 
@@ -610,6 +614,9 @@ func ReverseProxyHandleEditEndpoint(w http.ResponseWriter, r *http.Request) {
 	//Update uptime monitor targets
 	UpdateUptimeMonitorTargets()
 
+	//Notify the subscribed plugins
+	pluginManager.EmitEvent(zoraxy_plugin.EventName_ProxyRuleUpdated, "proxy", newProxyEndpoint.RootOrMatchingDomain)
+
 	utils.SendOK(w)
 }
 
@@ -693,6 +700,9 @@ func DeleteProxyEndpoint(w http.ResponseWriter, r *http.Request) {
 	//Update uptime monitor
 	UpdateUptimeMonitorTargets()
 
+	//Notify the subscribed plugins
+	pluginManager.EmitEvent(zoraxy_plugin.EventName_ProxyRuleRemoved, "proxy", ep)
+
 	utils.SendOK(w)
 }
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"runtime"
@@ -327,14 +328,16 @@ func startupSequence() {
 	pluginManager = plugins.NewPluginManager(&plugins.ManagerOptions{
 		PluginDir: "./plugins",
 		SystemConst: &zoraxy_plugin.RuntimeConstantValue{
-			ZoraxyVersion: SYSTEM_VERSION,
-			ZoraxyUUID:    nodeUUID,
+			ZoraxyVersion:   SYSTEM_VERSION,
+			ZoraxyUUID:      nodeUUID,
+			ExternalBaseURL: *webUIPublicURL,
 		},
 		Database: sysdb,
 		Logger:   SystemWideLogger,
 		CSRFTokenGen: func(r *http.Request) string {
 			return csrf.Token(r)
 		},
+		ZoraxyAPIURL: getPluginAPIURL(*webUIPort),
 	})
 
 	err = pluginManager.LoadPluginsFromDisk()
@@ -405,3 +408,16 @@ func ShutdownSeq() {
 	SystemWideLogger.Println("Closing system wide logger")
 	SystemWideLogger.Close()
 }
+
+// getPluginAPIURL returns the loopback URL of the plugin API served by the management interface
+func getPluginAPIURL(listeningAddress string) string {
+	host, port, err := net.SplitHostPort(listeningAddress)
+	if err != nil {
+		return ""
+	}
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		//Listening on all interfaces, the plugins can reach it on loopback
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/api/plugins"
+}